@@ -1,5 +1,15 @@
 package v1
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultUpgradeRollbackDeadline is the time components have to become available after an upgrade
+// before it is rolled back, when upgrade rollback is enabled without a deadline
+const DefaultUpgradeRollbackDeadline = time.Hour
+
 const (
 	Search             string = "search"
 	ManagementIngress  string = "management-ingress"
@@ -28,6 +38,7 @@ const (
 var allComponents = []string{
 	// MCH
 	Repo,
+	MultiClusterEngine,
 	Search,
 	ManagementIngress,
 	Console,
@@ -37,7 +48,6 @@ var allComponents = []string{
 	ClusterBackup,
 	ClusterProxyAddon,
 	Volsync,
-	MultiClusterEngine,
 	// MCE
 	MCEAssistedService,
 	MCEClusterLifecycle,
@@ -79,6 +89,141 @@ var DefaultDisabledComponents = []string{
 	ClusterBackup,
 }
 
+// ChartComponents are the components installed from a chart of the helm repo, which take Helm values
+var ChartComponents = []string{
+	Search,
+	ManagementIngress,
+	Console,
+	Insights,
+	GRC,
+	ClusterLifecycle,
+	ClusterBackup,
+	ClusterProxyAddon,
+	Volsync,
+}
+
+// ComponentDependencies lists, for each component, the components that must be enabled for it
+// to work. The console routes through management-ingress via its cfcRouterUrl, every chart
+// subscription pulls from the multiclusterhub-repo Channel, and cluster-lifecycle builds on
+// the multicluster-engine.
+var ComponentDependencies = map[string][]string{
+	ManagementIngress: {Repo},
+	Console:           {Repo, ManagementIngress},
+	Insights:          {Repo},
+	GRC:               {Repo},
+	ClusterLifecycle:  {Repo, MultiClusterEngine},
+	Volsync:           {Repo},
+	Search:            {Repo},
+	ClusterBackup:     {Repo},
+	ClusterProxyAddon: {Repo},
+}
+
+// DefaultRolloutWaves are the waves components roll out in during an upgrade when the spec sets none: the
+// foundation the other components build on, then the components other components depend on, then the
+// others, and the console last, so that it is upgraded once the services behind it are.
+var DefaultRolloutWaves = [][]string{
+	{Repo, MultiClusterEngine},
+	{ManagementIngress, ClusterLifecycle},
+	{GRC, Search, Insights, Volsync, ClusterBackup, ClusterProxyAddon},
+	{Console},
+}
+
+// DisabledDependencies returns the dependencies of a component that are not enabled
+func (mch *MultiClusterHub) DisabledDependencies(s string) []string {
+	disabled := []string{}
+	for _, d := range ComponentDependencies[s] {
+		if !mch.Enabled(d) {
+			disabled = append(disabled, d)
+		}
+	}
+	return disabled
+}
+
+// ComponentInstallOrder returns the components managed directly by the multiclusterhub sorted so
+// that every component comes after its dependencies. Components without an ordering constraint
+// keep their relative position in allComponents. Removal should happen in the reverse order.
+func ComponentInstallOrder() []string {
+	mchComponents := []string{}
+	for _, c := range allComponents {
+		if !isMCEComponent(c) {
+			mchComponents = append(mchComponents, c)
+		}
+	}
+
+	ordered := []string{}
+	placed := map[string]bool{}
+	for len(ordered) < len(mchComponents) {
+		progress := false
+		for _, c := range mchComponents {
+			if placed[c] {
+				continue
+			}
+			ready := true
+			for _, d := range ComponentDependencies[c] {
+				if !placed[d] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, c)
+				placed[c] = true
+				progress = true
+			}
+		}
+		if !progress {
+			panic("component dependency graph contains a cycle")
+		}
+	}
+	return ordered
+}
+
+// RolloutWaves returns the components of each rollout wave, in install order. Components no wave lists
+// roll out with the last wave, and a component listed before one of its dependencies rolls out with it.
+func (mch *MultiClusterHub) RolloutWaves() [][]string {
+	configured := DefaultRolloutWaves
+	if mch.Spec.Rollout != nil && len(mch.Spec.Rollout.Waves) > 0 {
+		configured = [][]string{}
+		for _, w := range mch.Spec.Rollout.Waves {
+			configured = append(configured, w.Components)
+		}
+	}
+	listed := map[string]int{}
+	for i, w := range configured {
+		for _, c := range w {
+			if _, ok := listed[c]; !ok {
+				listed[c] = i
+			}
+		}
+	}
+
+	waves := make([][]string, len(configured))
+	placed := map[string]int{}
+	for _, c := range ComponentInstallOrder() {
+		wave, ok := listed[c]
+		if !ok {
+			wave = len(configured) - 1
+		}
+		for _, d := range ComponentDependencies[c] {
+			if placed[d] > wave {
+				wave = placed[d]
+			}
+		}
+		placed[c] = wave
+		waves[wave] = append(waves[wave], c)
+	}
+	return waves
+}
+
+func isMCEComponent(s string) bool {
+	for _, c := range MCEComponents {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (mch *MultiClusterHub) ComponentPresent(s string) bool {
 	if mch.Spec.Overrides == nil {
 		return false
@@ -91,19 +236,41 @@ func (mch *MultiClusterHub) ComponentPresent(s string) bool {
 	return false
 }
 
+// Enabled returns true if the component is enabled and not marked as removed
 func (mch *MultiClusterHub) Enabled(s string) bool {
 	if mch.Spec.Overrides == nil {
 		return false
 	}
 	for _, c := range mch.Spec.Overrides.Components {
 		if c.Name == s {
-			return c.Enabled
+			return c.Enabled && c.ManagementState != ManagementStateRemoved
 		}
 	}
 
 	return false
 }
 
+// ManagementState returns how the operator manages the component, which defaults to Managed
+func (mch *MultiClusterHub) ManagementState(s string) ManagementState {
+	if mch.Spec.Overrides == nil {
+		return ManagementStateManaged
+	}
+	for _, c := range mch.Spec.Overrides.Components {
+		if c.Name == s {
+			if c.ManagementState == "" {
+				return ManagementStateManaged
+			}
+			return c.ManagementState
+		}
+	}
+	return ManagementStateManaged
+}
+
+// Unmanaged returns true if the component is paused, left as it is by the operator
+func (mch *MultiClusterHub) Unmanaged(s string) bool {
+	return mch.ManagementState(s) == ManagementStateUnmanaged
+}
+
 func (mch *MultiClusterHub) Enable(s string) {
 	if mch.Spec.Overrides == nil {
 		mch.Spec.Overrides = &Overrides{}
@@ -145,3 +312,78 @@ func ValidComponent(c ComponentConfig) bool {
 	}
 	return false
 }
+
+// ChartSourceType returns the type of the chart source, which defaults to the bundled helm repo
+func (mch *MultiClusterHub) ChartSourceType() ChartSourceType {
+	if mch.Spec.ChartSource == nil || mch.Spec.ChartSource.Type == "" {
+		return ChartSourceHelmRepo
+	}
+	return mch.Spec.ChartSource.Type
+}
+
+// InstallEngine returns the engine installing component charts, which defaults to the subscription operator
+func (mch *MultiClusterHub) InstallEngine() InstallEngineType {
+	if mch.Spec.InstallEngine == "" {
+		return InstallEngineSubscription
+	}
+	return mch.Spec.InstallEngine
+}
+
+// ComponentValues returns the Helm values set for a component, or nil if it has none
+func (mch *MultiClusterHub) ComponentValues(s string) (map[string]interface{}, error) {
+	if mch.Spec.Overrides == nil {
+		return nil, nil
+	}
+	for _, c := range mch.Spec.Overrides.Components {
+		if c.Name != s {
+			continue
+		}
+		if c.Values == nil || len(c.Values.Raw) == 0 {
+			return nil, nil
+		}
+		values := map[string]interface{}{}
+		if err := json.Unmarshal(c.Values.Raw, &values); err != nil {
+			return nil, fmt.Errorf("values of component %s must be an object: %w", s, err)
+		}
+		return values, nil
+	}
+	return nil, nil
+}
+
+// SteadyReconcileRate returns the reconcile rate of the chart channel once the hub is upgraded
+func (mch *MultiClusterHub) SteadyReconcileRate() ReconcileRate {
+	if mch.Spec.ChartReconcile == nil || mch.Spec.ChartReconcile.SteadyRate == "" {
+		return ReconcileRateLow
+	}
+	return mch.Spec.ChartReconcile.SteadyRate
+}
+
+// UpgradeReconcileRate returns the reconcile rate of the chart channel during upgrades
+func (mch *MultiClusterHub) UpgradeReconcileRate() ReconcileRate {
+	if mch.Spec.ChartReconcile == nil || mch.Spec.ChartReconcile.UpgradeRate == "" {
+		return ReconcileRateHigh
+	}
+	return mch.Spec.ChartReconcile.UpgradeRate
+}
+
+// SubscriptionReconcileRate returns the reconcile rate set on component subscriptions, or "" if they
+// follow the channel
+func (mch *MultiClusterHub) SubscriptionReconcileRate() ReconcileRate {
+	if mch.Spec.ChartReconcile == nil {
+		return ""
+	}
+	return mch.Spec.ChartReconcile.SubscriptionRate
+}
+
+// UpgradeRollbackEnabled returns true if failed upgrades are rolled back
+func (mch *MultiClusterHub) UpgradeRollbackEnabled() bool {
+	return mch.Spec.UpgradeRollback != nil && mch.Spec.UpgradeRollback.Enabled
+}
+
+// UpgradeRollbackDeadline returns the time components have to become available after an upgrade
+func (mch *MultiClusterHub) UpgradeRollbackDeadline() time.Duration {
+	if mch.Spec.UpgradeRollback == nil || mch.Spec.UpgradeRollback.Deadline == nil {
+		return DefaultUpgradeRollbackDeadline
+	}
+	return mch.Spec.UpgradeRollback.Deadline.Duration
+}
@@ -0,0 +1,102 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package v1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComponentInstallOrder(t *testing.T) {
+	order := ComponentInstallOrder()
+
+	position := map[string]int{}
+	for i, c := range order {
+		if _, ok := position[c]; ok {
+			t.Fatalf("ComponentInstallOrder() lists %s more than once", c)
+		}
+		position[c] = i
+	}
+
+	for c, deps := range ComponentDependencies {
+		if _, ok := position[c]; !ok {
+			t.Errorf("ComponentInstallOrder() is missing %s", c)
+			continue
+		}
+		for _, d := range deps {
+			if position[d] > position[c] {
+				t.Errorf("ComponentInstallOrder() places %s before its dependency %s", c, d)
+			}
+		}
+	}
+
+	for _, c := range MCEComponents {
+		if _, ok := position[c]; ok {
+			t.Errorf("ComponentInstallOrder() should not include MCE component %s", c)
+		}
+	}
+}
+
+func TestDisabledDependencies(t *testing.T) {
+	mch := &MultiClusterHub{}
+	mch.Enable(Repo)
+	mch.Enable(Console)
+	mch.Disable(ManagementIngress)
+
+	if got := mch.DisabledDependencies(Console); !reflect.DeepEqual(got, []string{ManagementIngress}) {
+		t.Errorf("DisabledDependencies(%s) = %v, want %v", Console, got, []string{ManagementIngress})
+	}
+	if got := mch.DisabledDependencies(GRC); len(got) != 0 {
+		t.Errorf("DisabledDependencies(%s) = %v, want none", GRC, got)
+	}
+	if got := mch.DisabledDependencies(ClusterLifecycle); !reflect.DeepEqual(got, []string{MultiClusterEngine}) {
+		t.Errorf("DisabledDependencies(%s) = %v, want %v", ClusterLifecycle, got, []string{MultiClusterEngine})
+	}
+}
+
+func TestManagementState(t *testing.T) {
+	mch := &MultiClusterHub{Spec: MultiClusterHubSpec{Overrides: &Overrides{Components: []ComponentConfig{
+		{Name: Search, Enabled: true, ManagementState: ManagementStateUnmanaged},
+		{Name: GRC, Enabled: true, ManagementState: ManagementStateRemoved},
+		{Name: Console, Enabled: true},
+	}}}}
+
+	if !mch.Unmanaged(Search) || !mch.Enabled(Search) {
+		t.Errorf("Search should be enabled and unmanaged")
+	}
+	if mch.Enabled(GRC) || mch.ManagementState(GRC) != ManagementStateRemoved {
+		t.Errorf("Enabled(%s) = true, want a removed component to count as disabled", GRC)
+	}
+	if got := mch.ManagementState(Console); got != ManagementStateManaged {
+		t.Errorf("ManagementState(%s) = %s, want the Managed default", Console, got)
+	}
+	if got := mch.ManagementState(Insights); got != ManagementStateManaged {
+		t.Errorf("ManagementState(%s) = %s, want the Managed default", Insights, got)
+	}
+}
+
+func TestRolloutWaves(t *testing.T) {
+	mch := &MultiClusterHub{}
+	want := [][]string{
+		{Repo, MultiClusterEngine},
+		{ManagementIngress, ClusterLifecycle},
+		{Search, Insights, GRC, ClusterBackup, ClusterProxyAddon, Volsync},
+		{Console},
+	}
+	if got := mch.RolloutWaves(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RolloutWaves() = %v, want %v", got, want)
+	}
+
+	// Unlisted components roll out last, and the console waits for management-ingress
+	mch.Spec.Rollout = &Rollout{Waves: []RolloutWave{
+		{Components: []string{Repo, GRC, Console}},
+		{Components: []string{Search}},
+	}}
+	want = [][]string{
+		{Repo, GRC},
+		{MultiClusterEngine, Search, ManagementIngress, Console, Insights, ClusterLifecycle, ClusterBackup, ClusterProxyAddon, Volsync},
+	}
+	if got := mch.RolloutWaves(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RolloutWaves() = %v, want %v", got, want)
+	}
+}
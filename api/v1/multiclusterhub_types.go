@@ -20,6 +20,7 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -33,6 +34,66 @@ const (
 	HAHigh AvailabilityType = "High"
 )
 
+// ImageDigestPolicyType selects how image references that are not pinned by digest are handled
+type ImageDigestPolicyType string
+
+const (
+	// ImageDigestAllow rolls out tag references as they are
+	ImageDigestAllow ImageDigestPolicyType = "Allow"
+	// ImageDigestReject holds back the rollout while any image reference is not pinned by digest
+	ImageDigestReject ImageDigestPolicyType = "Reject"
+	// ImageDigestResolve replaces tag references with the digest their registry reports
+	ImageDigestResolve ImageDigestPolicyType = "Resolve"
+)
+
+// ChartSourceType selects where the subscription operator pulls component charts from
+type ChartSourceType string
+
+const (
+	// ChartSourceHelmRepo is the helm repo bundled with the operator
+	ChartSourceHelmRepo ChartSourceType = "HelmRepo"
+	// ChartSourceOCI is an OCI registry holding the charts
+	ChartSourceOCI ChartSourceType = "OCI"
+	// ChartSourceGit is a Git repository holding a directory per chart
+	ChartSourceGit ChartSourceType = "Git"
+)
+
+// InstallEngineType selects how component charts are installed
+type InstallEngineType string
+
+const (
+	// InstallEngineSubscription installs each chart through an appsub reconciled by the subscription operator
+	InstallEngineSubscription InstallEngineType = "Subscription"
+	// InstallEngineHelm renders each chart in the operator with the Helm library and applies the result
+	InstallEngineHelm InstallEngineType = "Helm"
+)
+
+// ManagementState selects how the operator manages a component
+type ManagementState string
+
+const (
+	// ManagementStateManaged installs or removes the component as its enabled setting says
+	ManagementStateManaged ManagementState = "Managed"
+	// ManagementStateUnmanaged leaves the component as it is, neither applying nor removing it
+	ManagementStateUnmanaged ManagementState = "Unmanaged"
+	// ManagementStateRemoved removes the component, whatever its enabled setting says
+	ManagementStateRemoved ManagementState = "Removed"
+)
+
+// ReconcileRate is how often the subscription operator reconciles charts pulled from the channel
+type ReconcileRate string
+
+const (
+	// ReconcileRateOff stops charts from being reconciled
+	ReconcileRateOff ReconcileRate = "off"
+	// ReconcileRateLow reconciles charts every hour
+	ReconcileRateLow ReconcileRate = "low"
+	// ReconcileRateMedium reconciles charts every 15 minutes, the subscription operator default
+	ReconcileRateMedium ReconcileRate = "medium"
+	// ReconcileRateHigh reconciles charts every 2 minutes
+	ReconcileRateHigh ReconcileRate = "high"
+)
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
@@ -90,6 +151,129 @@ type MultiClusterHubSpec struct {
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Enable Cluster Backup",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
 	// +optional
 	EnableClusterBackup bool `json:"enableClusterBackup"`
+
+	// Rules that rewrite image references to pull from registry mirrors, similar to an
+	// ImageContentSourcePolicy. Supersedes the mch-imageRepository annotation.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Registry Mirrors",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	// +optional
+	RegistryMirrors []RegistryMirror `json:"registryMirrors,omitempty"`
+
+	// Handling of image references that are not pinned by digest. Options are: Allow (default), Reject
+	// and Resolve, which looks up the digest of each tag in its registry with the image pull secret.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Image Digest Policy",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced","urn:alm:descriptor:com.tectonic.ui:select:Allow","urn:alm:descriptor:com.tectonic.ui:select:Reject","urn:alm:descriptor:com.tectonic.ui:select:Resolve"}
+	// +optional
+	ImageDigestPolicy ImageDigestPolicyType `json:"imageDigestPolicy,omitempty"`
+
+	// Location of the charts installed for each component. Defaults to the helm repo bundled with the operator.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chart Source",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	// +optional
+	ChartSource *ChartSource `json:"chartSource,omitempty"`
+
+	// How component charts are installed. Options are: Subscription (default), through the application
+	// subscription operator, and Helm, which renders the charts of the bundled helm repo in the operator.
+	// Cannot be changed after installation.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Install Engine",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced","urn:alm:descriptor:com.tectonic.ui:select:Subscription","urn:alm:descriptor:com.tectonic.ui:select:Helm"}
+	// +optional
+	InstallEngine InstallEngineType `json:"installEngine,omitempty"`
+
+	// How often the subscription operator reconciles component charts. Does not apply to the Helm install engine.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chart Reconcile",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	// +optional
+	ChartReconcile *ChartReconcile `json:"chartReconcile,omitempty"`
+
+	// Waves in which components roll out during an upgrade. Each wave waits for the components of the previous
+	// waves to be available. Defaults to waves following the component dependencies.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Rollout",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	// +optional
+	Rollout *Rollout `json:"rollout,omitempty"`
+
+	// Roll components back to the previous release when they fail to become available after an upgrade.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upgrade Rollback",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	// +optional
+	UpgradeRollback *UpgradeRollback `json:"upgradeRollback,omitempty"`
+}
+
+// ChartSource points the channel that component subscriptions watch at a chart repository
+type ChartSource struct {
+	// Type of the chart source. Options are: HelmRepo (default), OCI and Git
+	Type ChartSourceType `json:"type,omitempty"`
+
+	// URL of the OCI registry (oci://host/path) or Git repository. Not set for the bundled helm repo.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Name of a secret in the hub namespace with the credentials of the source. OCI registries read
+	// the user and password keys, Git repositories the user and accessToken keys.
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+
+	// Name of a configmap in the hub namespace with the CA certificates of the source under the caCerts key
+	// +optional
+	ConfigMapRef string `json:"configMapRef,omitempty"`
+
+	// Git branch to subscribe to. Defaults to the default branch of the repository.
+	// +optional
+	Branch string `json:"branch,omitempty"`
+
+	// Directory of the Git repository holding a directory per chart. Defaults to the repository root.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// ChartReconcile sets the reconcile rates of the channel component subscriptions watch and of the
+// subscriptions themselves
+type ChartReconcile struct {
+	// Reconcile rate of the channel while the hub runs the operator version. Options are: off, low
+	// (default), medium and high.
+	// +optional
+	SteadyRate ReconcileRate `json:"steadyRate,omitempty"`
+
+	// Reconcile rate of the channel while the hub upgrades to the operator version, so that upgraded charts
+	// roll out promptly. Options are: off, low, medium and high (default).
+	// +optional
+	UpgradeRate ReconcileRate `json:"upgradeRate,omitempty"`
+
+	// Reconcile rate of every component subscription, overriding the channel. The subscription operator only
+	// honors off, which stops charts from being reconciled, for instance to keep a manual fix in place during
+	// an incident. Leave unset to follow the channel.
+	// +optional
+	SubscriptionRate ReconcileRate `json:"subscriptionRate,omitempty"`
+}
+
+// Rollout groups components into waves rolled out one after the other during an upgrade
+type Rollout struct {
+	// Waves in rollout order. Components left out of every wave roll out with the last wave, and a component
+	// cannot roll out before its dependencies.
+	// +optional
+	Waves []RolloutWave `json:"waves,omitempty"`
+}
+
+// RolloutWave is a set of components rolled out together
+type RolloutWave struct {
+	// Names of the components in the wave
+	Components []string `json:"components"`
+}
+
+// UpgradeRollback reverts components to the last release the hub ran successfully when an upgrade does
+// not become available in time
+type UpgradeRollback struct {
+	// Roll back upgrades whose components are not all available by the deadline. Disabling it resumes an
+	// upgrade that was rolled back.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Time the components have to become available once an upgrade starts. Defaults to 1h.
+	// +optional
+	Deadline *metav1.Duration `json:"deadline,omitempty"`
+}
+
+// RegistryMirror maps a source registry and repository prefix to the mirror that serves it
+type RegistryMirror struct {
+	// Source is the registry and optional repository path prefix to match, e.g. quay.io/stolostron
+	Source string `json:"source"`
+
+	// Mirror is the registry and optional repository path that replaces the matched source prefix
+	Mirror string `json:"mirror"`
 }
 
 // Overrides provides developer overrides for MCH installation
@@ -107,6 +291,18 @@ type Overrides struct {
 type ComponentConfig struct {
 	Name    string `json:"name"`
 	Enabled bool   `json:"enabled"`
+
+	// Helm values passed to the chart of the component, deep-merged over the values the operator sets.
+	// Values the operator derives from the MultiClusterHub spec cannot be set here.
+	// +kubebuilder:validation:Type=object
+	// +optional
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+
+	// How the operator manages the component. Options are: Managed (default); Unmanaged, which pauses the
+	// component so that manual changes are kept, for instance while debugging it; and Removed, which
+	// removes the component whatever enabled says.
+	// +optional
+	ManagementState ManagementState `json:"managementState,omitempty"`
 }
 
 type HiveConfigSpec struct {
@@ -218,12 +414,13 @@ type IngressSpec struct {
 type HubPhaseType string
 
 const (
-	HubPending         HubPhaseType = "Pending"
-	HubRunning         HubPhaseType = "Running"
-	HubInstalling      HubPhaseType = "Installing"
-	HubUpdating        HubPhaseType = "Updating"
-	HubUninstalling    HubPhaseType = "Uninstalling"
-	HubUpdatingBlocked HubPhaseType = "UpdatingBlocked"
+	HubPending           HubPhaseType = "Pending"
+	HubRunning           HubPhaseType = "Running"
+	HubInstalling        HubPhaseType = "Installing"
+	HubUpdating          HubPhaseType = "Updating"
+	HubUninstalling      HubPhaseType = "Uninstalling"
+	HubUpdatingBlocked   HubPhaseType = "UpdatingBlocked"
+	HubUpgradeRolledBack HubPhaseType = "UpgradeRolledBack"
 )
 
 // MultiClusterHubStatus defines the observed state of MultiClusterHub
@@ -239,6 +436,10 @@ type MultiClusterHubStatus struct {
 	// DesiredVersion indicates the desired version
 	DesiredVersion string `json:"desiredVersion,omitempty"`
 
+	// RolledBackVersion is the version whose upgrade was rolled back to CurrentVersion, if any
+	// +optional
+	RolledBackVersion string `json:"rolledBackVersion,omitempty"`
+
 	// Conditions contains the different condition statuses for the MultiClusterHub
 	HubConditions []HubCondition `json:"conditions,omitempty"`
 
@@ -291,6 +492,24 @@ const (
 
 	// Bocked means there is something preventing an update from occurring
 	Blocked HubConditionType = "Blocked"
+
+	// DeprecatedFieldsInUse means the multiclusterhub sets deprecated fields or annotations
+	DeprecatedFieldsInUse HubConditionType = "DeprecatedFieldsInUse"
+
+	// ImageOverridesInvalid means image overrides were supplied that could not be applied as intended
+	ImageOverridesInvalid HubConditionType = "ImageOverridesInvalid"
+
+	// UpgradeRolledBack means an upgrade failed to become available in time and components were reverted
+	// to the previous release
+	UpgradeRolledBack HubConditionType = "UpgradeRolledBack"
+
+	// RegistryMirrorsUnsupported means the registry mirror rules could not be passed on to the
+	// multicluster engine
+	RegistryMirrorsUnsupported HubConditionType = "RegistryMirrorsUnsupported"
+
+	// ChartDigestsUnverified means the charts served by the helm repo cannot be checked against the digests
+	// of the release
+	ChartDigestsUnverified HubConditionType = "ChartDigestsUnverified"
 )
 
 // StatusCondition contains condition information.
@@ -23,6 +23,8 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -47,9 +49,44 @@ func (in *BackupConfig) DeepCopy() *BackupConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChartReconcile) DeepCopyInto(out *ChartReconcile) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartReconcile.
+func (in *ChartReconcile) DeepCopy() *ChartReconcile {
+	if in == nil {
+		return nil
+	}
+	out := new(ChartReconcile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChartSource) DeepCopyInto(out *ChartSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartSource.
+func (in *ChartSource) DeepCopy() *ChartSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ChartSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComponentConfig) DeepCopyInto(out *ComponentConfig) {
 	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentConfig.
@@ -310,6 +347,31 @@ func (in *MultiClusterHubSpec) DeepCopyInto(out *MultiClusterHubSpec) {
 		*out = new(Overrides)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RegistryMirrors != nil {
+		in, out := &in.RegistryMirrors, &out.RegistryMirrors
+		*out = make([]RegistryMirror, len(*in))
+		copy(*out, *in)
+	}
+	if in.ChartSource != nil {
+		in, out := &in.ChartSource, &out.ChartSource
+		*out = new(ChartSource)
+		**out = **in
+	}
+	if in.ChartReconcile != nil {
+		in, out := &in.ChartReconcile, &out.ChartReconcile
+		*out = new(ChartReconcile)
+		**out = **in
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(Rollout)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpgradeRollback != nil {
+		in, out := &in.UpgradeRollback, &out.UpgradeRollback
+		*out = new(UpgradeRollback)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiClusterHubSpec.
@@ -357,7 +419,9 @@ func (in *Overrides) DeepCopyInto(out *Overrides) {
 	if in.Components != nil {
 		in, out := &in.Components, &out.Components
 		*out = make([]ComponentConfig, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -371,6 +435,63 @@ func (in *Overrides) DeepCopy() *Overrides {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryMirror) DeepCopyInto(out *RegistryMirror) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryMirror.
+func (in *RegistryMirror) DeepCopy() *RegistryMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Rollout) DeepCopyInto(out *Rollout) {
+	*out = *in
+	if in.Waves != nil {
+		in, out := &in.Waves, &out.Waves
+		*out = make([]RolloutWave, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Rollout.
+func (in *Rollout) DeepCopy() *Rollout {
+	if in == nil {
+		return nil
+	}
+	out := new(Rollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutWave) DeepCopyInto(out *RolloutWave) {
+	*out = *in
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutWave.
+func (in *RolloutWave) DeepCopy() *RolloutWave {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutWave)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StatusCondition) DeepCopyInto(out *StatusCondition) {
 	*out = *in
@@ -388,6 +509,26 @@ func (in *StatusCondition) DeepCopy() *StatusCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeRollback) DeepCopyInto(out *UpgradeRollback) {
+	*out = *in
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeRollback.
+func (in *UpgradeRollback) DeepCopy() *UpgradeRollback {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeRollback)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VeleroBackupConfig) DeepCopyInto(out *VeleroBackupConfig) {
 	*out = *in
@@ -0,0 +1,87 @@
+// Copyright Contributors to the Open Cluster Management project
+
+// chart-manifest writes the chart manifest of a release from the packaged charts of the helm repo image,
+// recording the name, version and sha256 digest of every chart archive.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+func main() {
+	charts := flag.String("charts", "", "directory holding the packaged charts of the helm repo (required)")
+	output := flag.String("output", "", "file to write the chart manifest to, standard output if unset")
+	flag.Parse()
+
+	if err := run(*charts, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "chart-manifest: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(charts, output string) error {
+	if charts == "" {
+		return fmt.Errorf("--charts is required")
+	}
+	m, err := chartManifest(charts)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	// The written manifest must read back with the rules the operator applies
+	if _, err := manifest.ParseChartManifest(data); err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(output, data, 0644)
+}
+
+// chartManifest lists the chart archives in dir, sorted by file name
+func chartManifest(dir string) (*manifest.ChartManifest, error) {
+	archives, err := filepath.Glob(filepath.Join(dir, "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) == 0 {
+		return nil, fmt.Errorf("no packaged charts found in %s", dir)
+	}
+	sort.Strings(archives)
+
+	m := &manifest.ChartManifest{SchemaVersion: manifest.SchemaVersion, Charts: []manifest.ManifestChart{}}
+	for _, path := range archives {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c, err := loader.LoadArchive(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load chart %s: %w", path, err)
+		}
+		sum := sha256.Sum256(data)
+		m.Charts = append(m.Charts, manifest.ManifestChart{
+			Name:    c.Metadata.Name,
+			Version: c.Metadata.Version,
+			Digest:  "sha256:" + hex.EncodeToString(sum[:]),
+		})
+	}
+	return m, nil
+}
@@ -0,0 +1,63 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func Test_run(t *testing.T) {
+	dir := t.TempDir()
+	digests := map[string]string{}
+	for _, name := range []string{"search-prod", "grc"} {
+		path, err := chartutil.Save(&chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: "2.5.0"}}, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		digests[name] = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.yaml"), []byte("entries: {}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "2.5.0"+manifest.ChartsFileSuffix)
+	if err := run(dir, output); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	charts, err := manifest.ParseChartManifest(data)
+	if err != nil {
+		t.Fatalf("run() wrote a chart manifest that does not parse: %v", err)
+	}
+	if len(charts) != 2 || charts[0].Name != "grc" || charts[1].Name != "search-prod" {
+		t.Fatalf("run() charts = %v, want grc and search-prod", charts)
+	}
+	for _, c := range charts {
+		if c.Version != "2.5.0" || c.Digest != digests[c.Name] {
+			t.Errorf("run() chart %s = %s %s, want 2.5.0 %s", c.Name, c.Version, c.Digest, digests[c.Name])
+		}
+	}
+
+	if err := run(t.TempDir(), output); err == nil {
+		t.Error("run() wrote a chart manifest for a directory without charts")
+	}
+	if err := run("", output); err == nil {
+		t.Error("run() accepted a missing --charts")
+	}
+}
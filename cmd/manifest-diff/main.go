@@ -0,0 +1,143 @@
+// Copyright Contributors to the Open Cluster Management project
+
+// manifest-diff compares the images of two image manifests, or of an image manifest and the image
+// manifest configmap a running operator maintains, to show what an upgrade changes.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/imageoverrides"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// Image keys of the oauth proxy image the operator selects for the cluster version
+const (
+	oauthKey    = "oauth_proxy"
+	oauthKeyOld = "oauth_proxy_48"
+	oauthKeyNew = "oauth_proxy_49_and_up"
+)
+
+func main() {
+	from := flag.String("from", "", "image manifest file to compare from")
+	to := flag.String("to", "", "image manifest file to compare to (required)")
+	live := flag.Bool("live", false, "compare from the image manifest configmap of the running hub instead of --from")
+	namespace := flag.String("namespace", "open-cluster-management", "namespace of the multiclusterhub, used with --live")
+	liveVersion := flag.String("live-version", "", "release version of the image manifest configmap, used with --live when several exist")
+	output := flag.String("output", "table", "output format: table or json")
+	flag.Parse()
+
+	if err := run(*from, *to, *live, *namespace, *liveVersion, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "manifest-diff: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(from, to string, live bool, namespace, liveVersion, output string) error {
+	if to == "" || (from == "") == !live {
+		return fmt.Errorf("--to and exactly one of --from or --live are required")
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+
+	toImages, err := readManifest(to)
+	if err != nil {
+		return err
+	}
+
+	var diff manifest.ManifestDiff
+	if live {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		cm, err := manifest.LiveImageManifest(context.Background(), c, namespace, liveVersion)
+		if err != nil {
+			return err
+		}
+		toRefs, err := hubImageReferences(context.Background(), c, namespace, manifest.ImageReferences(toImages), cm.Data)
+		if err != nil {
+			return err
+		}
+		diff = manifest.DiffImages(cm.Data, toRefs, manifest.ImageComponents(toImages))
+		diff.From = fmt.Sprintf("configmap %s/%s", cm.Namespace, cm.Name)
+	} else {
+		fromImages, err := readManifest(from)
+		if err != nil {
+			return err
+		}
+		diff = manifest.DiffImages(manifest.ImageReferences(fromImages), manifest.ImageReferences(toImages),
+			manifest.ImageComponents(fromImages, toImages))
+		diff.From = from
+	}
+	diff.To = to
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	return diff.WriteTable(os.Stdout)
+}
+
+func readManifest(path string) ([]manifest.ManifestImage, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	images, err := manifest.ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return images, nil
+}
+
+// hubImageReferences returns the references the hub in the namespace would roll out for the manifest
+// images, so that they compare with those of its image manifest configmap: rewritten by its registry mirror
+// rules or image repository, with the oauth proxy image the operator selected for the cluster, and replaced
+// by its image overrides configmaps. Digests resolved under the image digest policy are not looked up.
+func hubImageReferences(ctx context.Context, c client.Client, namespace string, refs, live map[string]string) (map[string]string, error) {
+	hubs := &operatorsv1.MultiClusterHubList{}
+	if err := c.List(ctx, hubs, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	if len(hubs.Items) != 1 {
+		return nil, fmt.Errorf("found %d multiclusterhubs in namespace %s, want 1", len(hubs.Items), namespace)
+	}
+	hub := &hubs.Items[0]
+
+	refs = utils.RewriteImageReferences(hub, refs)
+	for _, key := range []string{oauthKeyOld, oauthKeyNew} {
+		if ref, ok := live[oauthKey]; ok && ref == live[key] {
+			refs[oauthKey] = refs[key]
+		}
+	}
+	return imageoverrides.ApplyConfigmaps(ctx, c, hub, refs)
+}
+
+func newClient() (client.Client, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := operatorsv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
@@ -0,0 +1,71 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_hubImageReferences(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = operatorsv1.AddToScheme(scheme)
+	mch := &operatorsv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "multiclusterhub",
+			Namespace:   "open-cluster-management",
+			Annotations: map[string]string{utils.AnnotationImageOverridesCM: "overrides"},
+		},
+		Spec: operatorsv1.MultiClusterHubSpec{
+			RegistryMirrors: []operatorsv1.RegistryMirror{{Source: "quay.io/stolostron", Mirror: "mirror.example.com/acm"}},
+		},
+	}
+	overrides := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "overrides", Namespace: "open-cluster-management"},
+		Data: map[string]string{"overrides.json": `[
+			{"image-key":"search","image-name":"search","image-remote":"quay.io/dev","image-digest":"sha256:dev"}
+		]`},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mch, overrides).Build()
+
+	// The live configmap of a hub running an older release, with the oauth proxy image of OCP 4.9+
+	live := map[string]string{
+		"console":               "mirror.example.com/acm/console@sha256:old",
+		"search":                "quay.io/dev/search@sha256:dev",
+		"oauth_proxy_48":        "mirror.example.com/acm/oauth-proxy@sha256:48",
+		"oauth_proxy_49_and_up": "mirror.example.com/acm/oauth-proxy@sha256:49",
+		"oauth_proxy":           "mirror.example.com/acm/oauth-proxy@sha256:49",
+	}
+	to := []manifest.ManifestImage{
+		{ImageKey: "console", ImageName: "console", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:new"},
+		{ImageKey: "search", ImageName: "search", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:new"},
+		{ImageKey: "oauth_proxy_48", ImageName: "oauth-proxy", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:48"},
+		{ImageKey: "oauth_proxy_49_and_up", ImageName: "oauth-proxy", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:49"},
+	}
+
+	got, err := hubImageReferences(context.TODO(), c, "open-cluster-management", manifest.ImageReferences(to), live)
+	if err != nil {
+		t.Fatalf("hubImageReferences() error = %v", err)
+	}
+	diff := manifest.DiffImages(live, got, nil)
+	want := []manifest.ImageChange{{ImageKey: "console", Change: manifest.ImageChanged,
+		From: "mirror.example.com/acm/console@sha256:old", To: "mirror.example.com/acm/console@sha256:new"}}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("diff against the live manifest = %+v, want only the console image changed", diff.Changes)
+	}
+
+	if _, err := hubImageReferences(context.TODO(), c, "default", manifest.ImageReferences(to), live); err == nil {
+		t.Error("hubImageReferences() should error without a multiclusterhub in the namespace")
+	}
+}
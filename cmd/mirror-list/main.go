@@ -0,0 +1,138 @@
+// Copyright Contributors to the Open Cluster Management project
+
+// mirror-list generates what is needed to mirror the images of an image manifest for a disconnected
+// install: the image list, a mapping file for `oc image mirror`, an ImageContentSourcePolicy or
+// ImageDigestMirrorSet, and the matching spec.registryMirrors for the MultiClusterHub. Images are
+// rewritten with the same mirror rules the operator applies at runtime.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	"sigs.k8s.io/yaml"
+)
+
+// mirrorFlags collects repeated --mirror source=mirror flags
+type mirrorFlags []operatorsv1.RegistryMirror
+
+func (m *mirrorFlags) String() string {
+	rules := []string{}
+	for _, r := range *m {
+		rules = append(rules, r.Source+"="+r.Mirror)
+	}
+	return strings.Join(rules, ",")
+}
+
+func (m *mirrorFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("mirror rule must be source=mirror")
+	}
+	*m = append(*m, operatorsv1.RegistryMirror{Source: parts[0], Mirror: parts[1]})
+	return nil
+}
+
+func main() {
+	var mirrors mirrorFlags
+	manifestPath := flag.String("manifest", "", "image manifest file, such as bin/image-manifests/<version>.json (required)")
+	target := flag.String("target-registry", "", "registry to mirror every image remote to, keeping the repository path")
+	flag.Var(&mirrors, "mirror", "mirror rule source=mirror, may be repeated; replaces --target-registry")
+	output := flag.String("output", "mapping", "output: images, mapping, icsp, idms or registry-mirrors")
+	name := flag.String("name", "multiclusterhub", "name of the generated ImageContentSourcePolicy or ImageDigestMirrorSet")
+	flag.Parse()
+
+	if err := run(os.Stdout, os.Stderr, *manifestPath, *target, mirrors, *output, *name); err != nil {
+		fmt.Fprintf(os.Stderr, "mirror-list: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(out, warn io.Writer, manifestPath, target string, rules []operatorsv1.RegistryMirror, output, name string) error {
+	if manifestPath == "" {
+		return fmt.Errorf("--manifest is required")
+	}
+	data, err := os.ReadFile(filepath.Clean(manifestPath))
+	if err != nil {
+		return err
+	}
+	images, err := manifest.ParseManifest(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", manifestPath, err)
+	}
+
+	if output == "images" {
+		// Several image keys may share an image, which only needs to be mirrored once
+		listed := map[string]bool{}
+		for _, img := range images {
+			if ref := img.Reference(); !listed[ref] {
+				listed[ref] = true
+				fmt.Fprintln(out, ref)
+			}
+		}
+		return nil
+	}
+
+	if len(rules) == 0 {
+		if target == "" {
+			return fmt.Errorf("--target-registry or --mirror is required for output %s", output)
+		}
+		rules = manifest.MirrorRulesForRegistry(images, target)
+	}
+	mirrored, unmatched := manifest.MirrorImages(images, rules)
+	if len(unmatched) > 0 {
+		fmt.Fprintf(warn, "mirror-list: no mirror rule matches images %s\n", strings.Join(unmatched, ", "))
+	}
+
+	switch output {
+	case "mapping":
+		listed := map[string]bool{}
+		for _, m := range mirrored {
+			// Mirroring tools push to a repository or tag; the digest is preserved either way
+			dest, _, _ := utils.SplitImageReference(m.Mirror)
+			if m.Tag != "" {
+				dest += ":" + m.Tag
+			}
+			if line := m.Source + "=" + dest; !listed[line] {
+				listed[line] = true
+				fmt.Fprintln(out, line)
+			}
+		}
+		return nil
+	case "icsp":
+		return writeYAML(out, map[string]interface{}{
+			"apiVersion": "operator.openshift.io/v1alpha1",
+			"kind":       "ImageContentSourcePolicy",
+			"metadata":   map[string]interface{}{"name": name},
+			"spec":       map[string]interface{}{"repositoryDigestMirrors": manifest.RepositoryMirrors(mirrored)},
+		})
+	case "idms":
+		return writeYAML(out, map[string]interface{}{
+			"apiVersion": "config.openshift.io/v1",
+			"kind":       "ImageDigestMirrorSet",
+			"metadata":   map[string]interface{}{"name": name},
+			"spec":       map[string]interface{}{"imageDigestMirrors": manifest.RepositoryMirrors(mirrored)},
+		})
+	case "registry-mirrors":
+		return writeYAML(out, map[string]interface{}{
+			"spec": map[string]interface{}{"registryMirrors": rules},
+		})
+	}
+	return fmt.Errorf("unsupported output %q", output)
+}
+
+func writeYAML(out io.Writer, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
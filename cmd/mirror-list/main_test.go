@@ -0,0 +1,123 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+)
+
+func Test_run(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "2.5.0.json")
+	// search_api and search_collector share an image, which is listed and mirrored once
+	data := `{"schemaVersion":1,"images":[
+		{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron","image-digest":"sha256:0123"},
+		{"image-key":"search_api","image-name":"search","image-remote":"quay.io/stolostron","image-tag":"2.5.0","image-digest":"sha256:4567"},
+		{"image-key":"search_collector","image-name":"search","image-remote":"quay.io/stolostron","image-tag":"2.5.0","image-digest":"sha256:4567"},
+		{"image-key":"oauth_proxy","image-name":"oauth-proxy","image-remote":"registry.redhat.io/openshift4","image-digest":"sha256:89ab"}
+	]}`
+	if err := os.WriteFile(manifestPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		rules    []operatorsv1.RegistryMirror
+		output   string
+		want     string
+		wantWarn string
+		wantErr  bool
+	}{
+		{
+			name:   "Image list",
+			output: "images",
+			want: "quay.io/stolostron/console@sha256:0123\n" +
+				"quay.io/stolostron/search@sha256:4567\n" +
+				"registry.redhat.io/openshift4/oauth-proxy@sha256:89ab\n",
+		},
+		{
+			name:   "Mapping to a target registry",
+			target: "mirror.example.com:5000/",
+			output: "mapping",
+			want: "quay.io/stolostron/console@sha256:0123=mirror.example.com:5000/stolostron/console\n" +
+				"registry.redhat.io/openshift4/oauth-proxy@sha256:89ab=mirror.example.com:5000/openshift4/oauth-proxy\n" +
+				"quay.io/stolostron/search@sha256:4567=mirror.example.com:5000/stolostron/search:2.5.0\n",
+		},
+		{
+			name:   "Mapping with mirror rules",
+			rules:  []operatorsv1.RegistryMirror{{Source: "quay.io/stolostron", Mirror: "mirror.example.com/acm"}},
+			output: "mapping",
+			want: "quay.io/stolostron/console@sha256:0123=mirror.example.com/acm/console\n" +
+				"quay.io/stolostron/search@sha256:4567=mirror.example.com/acm/search:2.5.0\n",
+			wantWarn: "mirror-list: no mirror rule matches images oauth_proxy\n",
+		},
+		{
+			name: "Most specific mirror rule",
+			rules: []operatorsv1.RegistryMirror{
+				{Source: "quay.io", Mirror: "mirror.example.com/quay"},
+				{Source: "quay.io/stolostron/search", Mirror: "mirror.example.com/search"},
+			},
+			output: "icsp",
+			want: `apiVersion: operator.openshift.io/v1alpha1
+kind: ImageContentSourcePolicy
+metadata:
+  name: multiclusterhub
+spec:
+  repositoryDigestMirrors:
+  - mirrors:
+    - mirror.example.com/quay/stolostron/console
+    source: quay.io/stolostron/console
+  - mirrors:
+    - mirror.example.com/search
+    source: quay.io/stolostron/search
+`,
+			wantWarn: "mirror-list: no mirror rule matches images oauth_proxy\n",
+		},
+		{
+			name:   "Registry mirrors for a target registry",
+			target: "mirror.example.com",
+			output: "registry-mirrors",
+			want: `spec:
+  registryMirrors:
+  - mirror: mirror.example.com/stolostron
+    source: quay.io/stolostron
+  - mirror: mirror.example.com/openshift4
+    source: registry.redhat.io/openshift4
+`,
+		},
+		{
+			name:    "Mirror output without a target",
+			output:  "idms",
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported output",
+			target:  "mirror.example.com",
+			output:  "catalog",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, warn bytes.Buffer
+			err := run(&out, &warn, manifestPath, tt.target, tt.rules, tt.output, "multiclusterhub")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if out.String() != tt.want {
+				t.Errorf("run() output = %q, want %q", out.String(), tt.want)
+			}
+			if warn.String() != tt.wantWarn {
+				t.Errorf("run() warnings = %q, want %q", warn.String(), tt.wantWarn)
+			}
+		})
+	}
+}
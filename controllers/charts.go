@@ -0,0 +1,328 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/rendering"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// chartVerificationTimeout bounds the time spent fetching the helm repo index
+	chartVerificationTimeout = 5 * time.Second
+	// helmRepoIndexRetryInterval is the time waited before fetching the index again once the helm repo,
+	// although available, could not serve it
+	helmRepoIndexRetryInterval = time.Minute
+)
+
+// subscribedChart is the chart an enabled component subscribes to
+type subscribedChart struct {
+	name    string
+	version string
+}
+
+// verifyCharts checks that the helm repo serves the chart of every enabled component at the version its
+// subscription pins, with the digest listed in the chart manifest of the release, and that the values set
+// for the component match the values schema of the chart. Components failing either check are recorded in
+// CacheSpec.ChartProblems and are not subscribed. Components whose charts cannot be checked yet, because
+// the helm repo is not available or does not serve its index, are recorded in CacheSpec.PendingCharts and
+// wait for it, with a requeue. Charts pulled from another chart source are not verified.
+func (r *MultiClusterHubReconciler) verifyCharts(ctx context.Context, m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+	r.CacheSpec.ChartProblems = nil
+	r.CacheSpec.PendingCharts = nil
+	if utils.IsUnitTest() || !m.Enabled(operatorv1.Repo) || m.ChartSourceType() != operatorv1.ChartSourceHelmRepo {
+		RemoveHubCondition(&m.Status, operatorv1.ChartDigestsUnverified)
+		return ctrl.Result{}, nil
+	}
+	result, err := r.verifyReleasedCharts(ctx, m)
+	if err != nil {
+		return result, err
+	}
+	r.verifyComponentValues(m)
+	return result, nil
+}
+
+// verifyReleasedCharts checks the subscribed charts against the helm repo index and the chart manifest.
+// Charts found as released are not checked again. The index is only fetched once the helm repo is
+// available, and not again within helmRepoIndexRetryInterval of a failed fetch, so that reconciles do not
+// wait on a repo that cannot answer.
+func (r *MultiClusterHubReconciler) verifyReleasedCharts(ctx context.Context, m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+	charts := r.subscribedCharts(m)
+	repoImage := helmrepo.Image(r.CacheSpec.ImageOverrides)
+	if r.CacheSpec.VerifiedCharts == nil {
+		r.CacheSpec.VerifiedCharts = map[string]bool{}
+	}
+	unverified := map[string]subscribedChart{}
+	for component, chart := range charts {
+		if !r.CacheSpec.VerifiedCharts[chartKey(repoImage, chart)] {
+			unverified[component] = chart
+		}
+	}
+	if len(unverified) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	released, err := manifest.GetManifestCharts()
+	if manifest.IsManifestError(err) {
+		r.CacheSpec.ChartProblems = holdAll(unverified, fmt.Sprintf("invalid chart manifest: %s", err))
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	updateChartManifestCondition(m, released)
+	if rolledBack(m) {
+		// The chart manifest lists the charts of the operator version, not those of the release rolled back to
+		released = nil
+	}
+
+	if available, err := r.helmRepoAvailable(ctx, m); err != nil {
+		return ctrl.Result{}, err
+	} else if !available {
+		r.CacheSpec.PendingCharts = holdAll(unverified, "waiting for the helm repo to be available")
+		return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+	}
+	if retry := r.CacheSpec.HelmRepoIndexRetry; retry != nil && time.Now().Before(retry.at) {
+		r.CacheSpec.PendingCharts = holdAll(unverified, retry.problem)
+		return ctrl.Result{RequeueAfter: time.Until(retry.at)}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, chartVerificationTimeout)
+	defer cancel()
+	index, err := r.fetchHelmRepoIndex(ctx, m)
+	if err != nil {
+		r.Log.Info("Helm repo index unavailable, waiting to verify charts", "error", err.Error())
+		problem := fmt.Sprintf("helm repo index unavailable: %s", err)
+		r.CacheSpec.HelmRepoIndexRetry = &helmRepoIndexRetry{at: time.Now().Add(helmRepoIndexRetryInterval), problem: problem}
+		r.CacheSpec.PendingCharts = holdAll(unverified, problem)
+		return ctrl.Result{RequeueAfter: helmRepoIndexRetryInterval}, nil
+	}
+	r.CacheSpec.HelmRepoIndexRetry = nil
+
+	problems := checkCharts(index, unverified, released)
+	for component, chart := range unverified {
+		if _, failed := problems[component]; !failed {
+			r.CacheSpec.VerifiedCharts[chartKey(repoImage, chart)] = true
+		}
+	}
+	if len(problems) > 0 {
+		r.CacheSpec.ChartProblems = problems
+	}
+	return ctrl.Result{}, nil
+}
+
+// updateChartManifestCondition reports a release shipped without a chart manifest, whose charts are only
+// checked for their version
+func updateChartManifestCondition(m *operatorv1.MultiClusterHub, released map[string]manifest.ManifestChart) {
+	if released != nil {
+		RemoveHubCondition(&m.Status, operatorv1.ChartDigestsUnverified)
+		return
+	}
+	condition := NewHubCondition(operatorv1.ChartDigestsUnverified, metav1.ConditionTrue, ChartManifestMissingReason,
+		fmt.Sprintf("The operator ships no chart manifest %s; charts served by the helm repo are checked for their version only, not for the digest of the release",
+			version.Version+manifest.ChartsFileSuffix))
+	SetHubCondition(&m.Status, *condition)
+}
+
+// releasedChartVersion returns the index entry of a chart with the digest the chart manifest of the release
+// lists for it, so that the archive served by the helm repo is checked against what the release shipped
+// rather than against the index of the same repo. Charts the manifest does not list keep the index digest.
+func releasedChartVersion(m *operatorv1.MultiClusterHub, cv *helmrepo.ChartVersion) (*helmrepo.ChartVersion, error) {
+	if rolledBack(m) {
+		return cv, nil
+	}
+	released, err := manifest.GetManifestCharts()
+	if err != nil {
+		return nil, err
+	}
+	rc, ok := released[cv.Name]
+	if !ok {
+		return cv, nil
+	}
+	if rc.Version != cv.Version {
+		return nil, fmt.Errorf("chart %s is released at version %s, not %s", cv.Name, rc.Version, cv.Version)
+	}
+	pinned := *cv
+	pinned.Digest = rc.Digest
+	return &pinned, nil
+}
+
+// helmRepoIndexRetry records a failed fetch of the helm repo index
+type helmRepoIndexRetry struct {
+	at      time.Time
+	problem string
+}
+
+// helmRepoAvailable returns true once a pod of the helm repo is available to serve the index
+func (r *MultiClusterHubReconciler) helmRepoAvailable(ctx context.Context, m *operatorv1.MultiClusterHub) (bool, error) {
+	dep := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: helmrepo.HelmRepoName, Namespace: m.Namespace}, dep)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return dep.Status.AvailableReplicas > 0, nil
+}
+
+// verifyComponentValues checks the values set for each enabled component, merged into the values of its
+// appsub, against the values schema of its chart
+func (r *MultiClusterHubReconciler) verifyComponentValues(m *operatorv1.MultiClusterHub) {
+	for component, a := range r.componentActions() {
+		if a.subscription == nil || !m.Enabled(component) {
+			continue
+		}
+		if _, held := r.CacheSpec.ChartProblems[component]; held {
+			continue
+		}
+		if _, pending := r.CacheSpec.PendingCharts[component]; pending {
+			continue
+		}
+		values, err := m.ComponentValues(component)
+		if err != nil {
+			r.holdChart(component, err.Error())
+			continue
+		} else if len(values) == 0 {
+			continue
+		}
+
+		rel, err := rendering.FromSubscription(a.subscription(m))
+		if err != nil {
+			r.holdChart(component, err.Error())
+			continue
+		}
+		archive, err := r.fetchChart(m, rel)
+		if err != nil {
+			r.holdChart(component, fmt.Sprintf("chart unavailable to check component values: %s", err))
+			continue
+		}
+		if err := rendering.ValidateValues(archive, rel); err != nil {
+			r.holdChart(component, fmt.Sprintf("component values do not match the chart values schema: %s", err))
+		}
+	}
+}
+
+// holdChart records a chart problem holding back the component
+func (r *MultiClusterHubReconciler) holdChart(component, problem string) {
+	if r.CacheSpec.ChartProblems == nil {
+		r.CacheSpec.ChartProblems = map[string]string{}
+	}
+	r.CacheSpec.ChartProblems[component] = problem
+}
+
+// checkCharts returns, by component, the charts the index does not serve as released. Charts missing
+// from the release chart manifest are only checked for their version.
+func checkCharts(index *helmrepo.IndexFile, charts map[string]subscribedChart, released map[string]manifest.ManifestChart) map[string]string {
+	problems := map[string]string{}
+	for component, chart := range charts {
+		digest := ""
+		if rc, ok := released[chart.name]; ok {
+			if rc.Version != chart.version {
+				problems[component] = fmt.Sprintf("chart %s is released at version %s, subscription pins %s", chart.name, rc.Version, chart.version)
+				continue
+			}
+			digest = rc.Digest
+		}
+		if err := index.CheckChart(chart.name, chart.version, digest); err != nil {
+			problems[component] = err.Error()
+		}
+	}
+	return problems
+}
+
+// subscribedCharts returns the chart name and version subscribed to by each enabled component
+func (r *MultiClusterHubReconciler) subscribedCharts(m *operatorv1.MultiClusterHub) map[string]subscribedChart {
+	charts := map[string]subscribedChart{}
+	for component, a := range r.componentActions() {
+		if a.subscription == nil || !m.Enabled(component) {
+			continue
+		}
+		sub := a.subscription(m)
+		name, _, _ := unstructured.NestedString(sub.Object, "spec", "name")
+		version, _, _ := unstructured.NestedString(sub.Object, "spec", "packageFilter", "version")
+		charts[component] = subscribedChart{name: name, version: version}
+	}
+	return charts
+}
+
+// helmRepoAccess holds what the operator needs to read from the helm repo
+type helmRepoAccess struct {
+	client   *http.Client
+	url      string
+	user     string
+	password string
+}
+
+// helmRepoAccess returns access to the helm repo the way the channel reaches it, trusting the repo CA and
+// authenticating with the repo credentials
+func (r *MultiClusterHubReconciler) helmRepoAccess(ctx context.Context, m *operatorv1.MultiClusterHub) (*helmRepoAccess, error) {
+	auth := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: helmrepo.AuthSecretName, Namespace: m.Namespace}, auth); err != nil {
+		return nil, err
+	}
+
+	httpClient := r.HelmRepoClient
+	if httpClient == nil {
+		ca := &corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: helmrepo.CAConfigMapName, Namespace: m.Namespace}, ca); err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(ca.Data[helmrepo.CAKey])) {
+			return nil, fmt.Errorf("no certificates found in configmap %s", helmrepo.CAConfigMapName)
+		}
+		httpClient = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}}
+	}
+
+	repoURL := r.HelmRepoURL
+	if repoURL == "" {
+		repoURL = helmrepo.URL(m)
+	}
+	return &helmRepoAccess{
+		client:   httpClient,
+		url:      repoURL,
+		user:     string(auth.Data["user"]),
+		password: string(auth.Data["password"]),
+	}, nil
+}
+
+// fetchHelmRepoIndex reads the helm repo index
+func (r *MultiClusterHubReconciler) fetchHelmRepoIndex(ctx context.Context, m *operatorv1.MultiClusterHub) (*helmrepo.IndexFile, error) {
+	repo, err := r.helmRepoAccess(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return helmrepo.FetchIndex(ctx, repo.client, repo.url, repo.user, repo.password)
+}
+
+// holdAll reports the same problem for every chart
+func holdAll(charts map[string]subscribedChart, problem string) map[string]string {
+	problems := map[string]string{}
+	for component := range charts {
+		problems[component] = problem
+	}
+	return problems
+}
+
+func chartKey(repoImage string, chart subscribedChart) string {
+	return repoImage + "|" + chart.name + "|" + chart.version
+}
@@ -0,0 +1,226 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_verifyCharts(t *testing.T) {
+	t.Setenv(utils.UnitTestEnvVar, "false")
+	dir := t.TempDir()
+	t.Setenv(manifest.ManifestsPathEnvVar, dir)
+
+	var fetches int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if user, pass, ok := r.BasicAuth(); !ok || user != helmrepo.HelmRepoName || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/charts/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "entries:\n  grc:\n  - name: grc\n    version: %s\n    digest: 0f1e2d\n  policyreport:\n  - name: policyreport\n    version: %s\n    digest: aabbcc\n", version.Version, version.Version)
+	}))
+	defer server.Close()
+
+	mch := &operatorv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"},
+		Spec: operatorv1.MultiClusterHubSpec{Overrides: &operatorv1.Overrides{Components: []operatorv1.ComponentConfig{
+			{Name: operatorv1.Repo, Enabled: true},
+			{Name: operatorv1.GRC, Enabled: true},
+			{Name: operatorv1.Insights, Enabled: true},
+			{Name: operatorv1.Search, Enabled: true},
+		}}},
+	}
+	auth := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: helmrepo.AuthSecretName, Namespace: "test"},
+		Data:       map[string][]byte{"user": []byte(helmrepo.HelmRepoName), "password": []byte("secret")},
+	}
+	chartManifest := fmt.Sprintf(`{"schemaVersion":1,"charts":[{"name":"grc","version":"%s","digest":"sha256:0f1e2d"},{"name":"policyreport","version":"%s","digest":"sha256:ffffff"}]}`, version.Version, version.Version)
+	if err := os.WriteFile(filepath.Join(dir, version.Version+manifest.ChartsFileSuffix), []byte(chartManifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: helmrepo.HelmRepoName, Namespace: "test"},
+		Status:     appsv1.DeploymentStatus{AvailableReplicas: 1},
+	}
+
+	r := &MultiClusterHubReconciler{
+		Client:         fake.NewFakeClient(auth, repo),
+		Log:            zap.New(),
+		HelmRepoClient: server.Client(),
+		HelmRepoURL:    server.URL + "/charts",
+	}
+	if result, err := r.verifyCharts(context.TODO(), mch); err != nil || result != (ctrl.Result{}) {
+		t.Fatalf("verifyCharts() = %v, %v, want no requeue", result, err)
+	}
+
+	problems := r.CacheSpec.ChartProblems
+	if _, ok := problems[operatorv1.GRC]; ok || len(problems) != 2 {
+		t.Errorf("verifyCharts() problems = %v, want insights and search", problems)
+	}
+	if !strings.Contains(problems[operatorv1.Insights], "expected sha256:ffffff") {
+		t.Errorf("verifyCharts() insights = %q, want a digest mismatch", problems[operatorv1.Insights])
+	}
+	if !strings.Contains(problems[operatorv1.Search], "not served by the helm repo") {
+		t.Errorf("verifyCharts() search = %q, want a missing chart", problems[operatorv1.Search])
+	}
+	status := getComponentStatuses(mch, nil, nil, nil, nil, r.heldComponents())
+	if search := status[operatorv1.Search]; search.Available || search.Reason != ChartVerificationFailedReason {
+		t.Errorf("getComponentStatuses() search = %+v, want reason %s", search, ChartVerificationFailedReason)
+	}
+
+	// Verified charts are not fetched again
+	mch.Disable(operatorv1.Insights)
+	mch.Disable(operatorv1.Search)
+	before := atomic.LoadInt32(&fetches)
+	if _, err := r.verifyCharts(context.TODO(), mch); err != nil || len(r.CacheSpec.ChartProblems) != 0 {
+		t.Errorf("verifyCharts() = %v, %v, want no problems", r.CacheSpec.ChartProblems, err)
+	}
+	if atomic.LoadInt32(&fetches) != before {
+		t.Errorf("verifyCharts() fetched the index again for verified charts")
+	}
+
+	// Subscriptions wait while the index cannot be read, and the index is not fetched again before the
+	// retry interval
+	mch.Enable(operatorv1.Search)
+	r.HelmRepoURL = server.URL + "/missing"
+	for i := 0; i < 2; i++ {
+		result, err := r.verifyCharts(context.TODO(), mch)
+		if err != nil || result.RequeueAfter == 0 || len(r.CacheSpec.ChartProblems) != 0 {
+			t.Fatalf("verifyCharts() = %v, %v, %v, want a requeue without problems", result, err, r.CacheSpec.ChartProblems)
+		}
+		if !strings.Contains(r.CacheSpec.PendingCharts[operatorv1.Search], "helm repo index unavailable") {
+			t.Errorf("verifyCharts() search = %q, want the index to be unavailable", r.CacheSpec.PendingCharts[operatorv1.Search])
+		}
+	}
+	if got := atomic.LoadInt32(&fetches) - before; got != 1 {
+		t.Errorf("verifyCharts() fetched the unavailable index %d times, want 1", got)
+	}
+	status = getComponentStatuses(mch, nil, nil, nil, nil, r.heldComponents())
+	if search := status[operatorv1.Search]; search.Available || search.Reason != ChartVerificationPendingReason {
+		t.Errorf("getComponentStatuses() search = %+v, want reason %s", search, ChartVerificationPendingReason)
+	}
+
+	// The index is not fetched before the helm repo is available
+	r.CacheSpec.HelmRepoIndexRetry = nil
+	r.Client = fake.NewFakeClient(auth)
+	before = atomic.LoadInt32(&fetches)
+	if result, err := r.verifyCharts(context.TODO(), mch); err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("verifyCharts() = %v, %v, want a requeue", result, err)
+	}
+	if !strings.Contains(r.CacheSpec.PendingCharts[operatorv1.Search], "helm repo to be available") || atomic.LoadInt32(&fetches) != before {
+		t.Errorf("verifyCharts() search = %q, want to wait for the helm repo without fetching", r.CacheSpec.PendingCharts[operatorv1.Search])
+	}
+}
+
+func Test_verifyCharts_chartManifestMissing(t *testing.T) {
+	t.Setenv(utils.UnitTestEnvVar, "false")
+	dir := t.TempDir()
+	t.Setenv(manifest.ManifestsPathEnvVar, dir)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "entries:\n  grc:\n  - name: grc\n    version: %s\n    digest: 0f1e2d\n", version.Version)
+	}))
+	defer server.Close()
+
+	mch := &operatorv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"},
+		Spec: operatorv1.MultiClusterHubSpec{Overrides: &operatorv1.Overrides{Components: []operatorv1.ComponentConfig{
+			{Name: operatorv1.Repo, Enabled: true},
+			{Name: operatorv1.GRC, Enabled: true},
+		}}},
+	}
+	auth := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: helmrepo.AuthSecretName, Namespace: "test"},
+		Data:       map[string][]byte{"user": []byte(helmrepo.HelmRepoName), "password": []byte("secret")},
+	}
+	repo := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: helmrepo.HelmRepoName, Namespace: "test"},
+		Status:     appsv1.DeploymentStatus{AvailableReplicas: 1},
+	}
+	r := &MultiClusterHubReconciler{
+		Client:         fake.NewFakeClient(auth, repo),
+		Log:            zap.New(),
+		HelmRepoClient: server.Client(),
+		HelmRepoURL:    server.URL + "/charts",
+	}
+
+	// Without a chart manifest the chart is checked for its version only, which is reported
+	if _, err := r.verifyCharts(context.TODO(), mch); err != nil || len(r.CacheSpec.ChartProblems) != 0 {
+		t.Fatalf("verifyCharts() = %v, %v, want the chart served at its version to pass", r.CacheSpec.ChartProblems, err)
+	}
+	if c := GetHubCondition(mch.Status, operatorv1.ChartDigestsUnverified); c == nil || c.Reason != ChartManifestMissingReason {
+		t.Errorf("verifyCharts() condition = %+v, want reason %s", c, ChartManifestMissingReason)
+	}
+
+	// The condition is cleared once the release ships its chart manifest
+	chartManifest := fmt.Sprintf(`{"schemaVersion":1,"charts":[{"name":"grc","version":"%s","digest":"sha256:0f1e2d"}]}`, version.Version)
+	if err := os.WriteFile(filepath.Join(dir, version.Version+manifest.ChartsFileSuffix), []byte(chartManifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r.CacheSpec.VerifiedCharts = nil
+	if _, err := r.verifyCharts(context.TODO(), mch); err != nil || len(r.CacheSpec.ChartProblems) != 0 {
+		t.Fatalf("verifyCharts() = %v, %v, want no problems", r.CacheSpec.ChartProblems, err)
+	}
+	if HubConditionPresent(mch.Status, operatorv1.ChartDigestsUnverified) {
+		t.Errorf("verifyCharts() kept the %s condition with a chart manifest", operatorv1.ChartDigestsUnverified)
+	}
+}
+
+func Test_verifyComponentValues(t *testing.T) {
+	mch := &operatorv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"},
+		Spec: operatorv1.MultiClusterHubSpec{Overrides: &operatorv1.Overrides{Components: []operatorv1.ComponentConfig{
+			{Name: operatorv1.GRC, Enabled: true, Values: &apiextensionsv1.JSON{Raw: []byte(`{"hubconfig":{"logLevel":"verbose"}}`)}},
+			{Name: operatorv1.Search, Enabled: true},
+		}}},
+	}
+	r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(), Log: zap.New()}
+
+	schema := `{"properties":{"hubconfig":{"properties":{"logLevel":{"enum":["info","debug"]}}}}}`
+	grc := subscribedChart{name: "grc", version: version.Version}
+	r.CacheSpec.ChartArchives = map[string][]byte{
+		chartKey(helmrepo.Image(r.CacheSpec.ImageOverrides), grc): packageChart(t, grc.name, grc.version, schema, nil),
+	}
+
+	r.verifyComponentValues(mch)
+	if !strings.Contains(r.CacheSpec.ChartProblems[operatorv1.GRC], "do not match the chart values schema") {
+		t.Errorf("verifyComponentValues() grc = %q, want a schema violation", r.CacheSpec.ChartProblems[operatorv1.GRC])
+	}
+	if _, ok := r.CacheSpec.ChartProblems[operatorv1.Search]; ok {
+		t.Errorf("verifyComponentValues() checked search, which sets no values")
+	}
+
+	r.CacheSpec.ChartProblems = nil
+	mch.Spec.Overrides.Components[0].Values.Raw = []byte(`{"hubconfig":{"logLevel":"debug"}}`)
+	r.verifyComponentValues(mch)
+	if len(r.CacheSpec.ChartProblems) != 0 {
+		t.Errorf("verifyComponentValues() problems = %v, want none", r.CacheSpec.ChartProblems)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	olmv1 "github.com/operator-framework/api/pkg/operators/v1"
 
 	mcev1 "github.com/stolostron/backplane-operator/api/v1"
+	"helm.sh/helm/v3/pkg/chartutil"
 
 	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
@@ -33,12 +34,14 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
+	"github.com/stolostron/multiclusterhub-operator/pkg/imageoverrides"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/sbom"
 	utils "github.com/stolostron/multiclusterhub-operator/pkg/utils"
 
 	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
 	"github.com/stolostron/multiclusterhub-operator/pkg/channel"
 	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
-	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
 	"github.com/stolostron/multiclusterhub-operator/pkg/multiclusterengine"
 	"github.com/stolostron/multiclusterhub-operator/pkg/subscription"
 	"github.com/stolostron/multiclusterhub-operator/pkg/version"
@@ -52,6 +55,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// sbomConfigmapKey is the data key holding the bill of materials in the sbom configmap
+const sbomConfigmapKey = "sbom.cdx.json"
+
 // CacheSpec ...
 type CacheSpec struct {
 	IngressDomain    string
@@ -59,6 +65,38 @@ type CacheSpec struct {
 	ImageRepository  string
 	ManifestVersion  string
 	ImageOverridesCM string
+	ImageProvenance  *imageoverrides.Provenance
+	// UnavailableImages lists, by component, the images the image preflight could not find
+	UnavailableImages map[string][]string
+	// ImagePreflight holds the image preflight lookups of the manifest version, so that images are
+	// looked up once
+	ImagePreflight *imagePreflightResults
+	// ResolvedDigests holds the tag references resolved under the Resolve digest policy for the manifest
+	// version. A tag is resolved once and stays pinned until the manifest version changes.
+	ResolvedDigests *resolvedDigests
+	// IncompatibleImages describes, by component, why its image cannot be rolled out by this operator
+	IncompatibleImages map[string]string
+	// UnpinnedImages lists, by component, the images it deploys that are not pinned by digest as the image
+	// digest policy requires, and that the operator cannot rewrite
+	UnpinnedImages map[string][]string
+	// ChartProblems describes, by component, why its chart failed verification against the helm repo index
+	ChartProblems map[string]string
+	// PendingCharts describes, by component, why its chart cannot be verified yet. The component waits for
+	// the helm repo without being reported as failed.
+	PendingCharts map[string]string
+	// HelmRepoIndexRetry records the last failed fetch of the helm repo index, to wait before the next one
+	HelmRepoIndexRetry *helmRepoIndexRetry
+	// VerifiedCharts holds the charts found as released in the helm repo, keyed by repo image, chart name
+	// and version
+	VerifiedCharts map[string]bool
+	// ChartArchives holds the packaged charts downloaded from the helm repo, to render them with the Helm
+	// install engine or check component values, keyed like VerifiedCharts
+	ChartArchives map[string][]byte
+	// Capabilities holds the cluster capabilities charts are rendered against, discovered once per reconcile
+	Capabilities *chartutil.Capabilities
+	// ManifestImages holds the images of the image manifest file of the operator version, once it has
+	// been read
+	ManifestImages []manifest.ManifestImage
 }
 
 func (r *MultiClusterHubReconciler) ensureDeployment(m *operatorv1.MultiClusterHub, dep *appsv1.Deployment) (ctrl.Result, error) {
@@ -154,6 +192,14 @@ func (r *MultiClusterHubReconciler) ensureService(m *operatorv1.MultiClusterHub,
 	modified := resourcemerge.BoolPtr(false)
 	existingCopy := found.DeepCopy()
 	resourcemerge.EnsureObjectMeta(modified, &existingCopy.ObjectMeta, s.ObjectMeta)
+	// EnsureObjectMeta only adds annotations. Once the service has its own certificate, the service CA must
+	// stop issuing one into the secret name.
+	if _, ok := s.Annotations[helmrepo.AnnotationServingCertSecret]; !ok {
+		if _, found := existingCopy.Annotations[helmrepo.AnnotationServingCertSecret]; found {
+			delete(existingCopy.Annotations, helmrepo.AnnotationServingCertSecret)
+			*modified = true
+		}
+	}
 	selectorSame := equality.Semantic.DeepEqual(existingCopy.Spec.Selector, s.Spec.Selector)
 
 	typeSame := false
@@ -232,6 +278,7 @@ func (r *MultiClusterHubReconciler) ensureSubscription(m *operatorv1.MultiCluste
 	if utils.ProxyEnvVarsAreSet() {
 		u = addProxyEnvVarsToSub(u)
 	}
+	subscription.AddSubscriptionAnnotations(m, u)
 
 	found := &unstructured.Unstructured{}
 	found.SetGroupVersionKind(schema.GroupVersionKind{
@@ -683,8 +730,11 @@ func (r *MultiClusterHubReconciler) ensurePullSecretCreated(m *operatorv1.MultiC
 	return ctrl.Result{}, nil
 }
 
-// OverrideImagesFromConfigmap ...
-func (r *MultiClusterHubReconciler) OverrideImagesFromConfigmap(imageOverrides map[string]string, namespace, configmapName string) (map[string]string, error) {
+// OverrideImagesFromConfigmap replaces images with those listed in the configmap, rewritten by the registry
+// mirror rules. A missing configmap or invalid entries are returned as issues and leave the images as they
+// are; only failures to read or verify the configmap are returned as an error.
+func (r *MultiClusterHubReconciler) OverrideImagesFromConfigmap(imageOverrides map[string]string, namespace, configmapName string,
+	mirrors []operatorv1.RegistryMirror) (map[string]string, []string, error) {
 	r.Log.Info(fmt.Sprintf("Overriding images from configmap: %s/%s", namespace, configmapName))
 
 	configmap := &corev1.ConfigMap{}
@@ -692,33 +742,21 @@ func (r *MultiClusterHubReconciler) OverrideImagesFromConfigmap(imageOverrides m
 		Name:      configmapName,
 		Namespace: namespace,
 	}, configmap)
-	if err != nil && errors.IsNotFound(err) {
-		return imageOverrides, err
+	if errors.IsNotFound(err) {
+		return imageOverrides, []string{fmt.Sprintf("configmap %s/%s not found", namespace, configmapName)}, nil
+	} else if err != nil {
+		return nil, nil, err
 	}
 
-	if len(configmap.Data) != 1 {
-		return imageOverrides, fmt.Errorf(fmt.Sprintf("Unexpected number of keys in configmap: %s", configmapName))
+	if err := imageoverrides.VerifyConfigmap(configmap, r.VerificationPolicy); err != nil {
+		return nil, nil, err
 	}
 
-	for _, v := range configmap.Data {
-
-		var manifestImages []manifest.ManifestImage
-		err = json.Unmarshal([]byte(v), &manifestImages)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, manifestImage := range manifestImages {
-			if manifestImage.ImageDigest != "" {
-				imageOverrides[manifestImage.ImageKey] = fmt.Sprintf("%s/%s@%s", manifestImage.ImageRemote, manifestImage.ImageName, manifestImage.ImageDigest)
-			} else if manifestImage.ImageTag != "" {
-				imageOverrides[manifestImage.ImageKey] = fmt.Sprintf("%s/%s:%s", manifestImage.ImageRemote, manifestImage.ImageName, manifestImage.ImageTag)
-			}
-
-		}
+	overrides, issues := imageoverrides.ConfigmapOverrides(configmap, mirrors)
+	for k, v := range overrides {
+		imageOverrides[k] = v
 	}
-
-	return imageOverrides, nil
+	return imageOverrides, issues, nil
 }
 
 // Select oauth proxy image to use. If OCP 4.8 use old version. If OCP 4.9+ use new version. Set with key oauth_proxy
@@ -774,14 +812,20 @@ func (r *MultiClusterHubReconciler) maintainImageManifestConfigmap(mch *operator
 
 	configmap.SetLabels(labels)
 
+	provenance, err := r.imageProvenanceAnnotation()
+	if err != nil {
+		return err
+	}
+
 	// Get Configmap if it exists
-	err := r.Client.Get(context.TODO(), types.NamespacedName{
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
 		Name:      configmap.Name,
 		Namespace: configmap.Namespace,
 	}, configmap)
 	if err != nil && errors.IsNotFound(err) {
 		// If configmap does not exist, create and return
 		configmap.Data = r.CacheSpec.ImageOverrides
+		configmap.SetAnnotations(map[string]string{utils.AnnotationImageProvenance: provenance})
 		err = r.Client.Create(context.TODO(), configmap)
 		if err != nil {
 			return err
@@ -790,8 +834,15 @@ func (r *MultiClusterHubReconciler) maintainImageManifestConfigmap(mch *operator
 	}
 
 	// If cached image overrides are not equal to the configmap data, update configmap and return
-	if !reflect.DeepEqual(configmap.Data, r.CacheSpec.ImageOverrides) {
+	if !reflect.DeepEqual(configmap.Data, r.CacheSpec.ImageOverrides) ||
+		configmap.GetAnnotations()[utils.AnnotationImageProvenance] != provenance {
 		configmap.Data = r.CacheSpec.ImageOverrides
+		annotations := configmap.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[utils.AnnotationImageProvenance] = provenance
+		configmap.SetAnnotations(annotations)
 		err = r.Client.Update(context.TODO(), configmap)
 		if err != nil {
 			return err
@@ -801,6 +852,94 @@ func (r *MultiClusterHubReconciler) maintainImageManifestConfigmap(mch *operator
 	return nil
 }
 
+// maintainSBOMConfigmap stores a CycloneDX bill of materials of the cached image overrides next to the
+// image manifest configmap
+// manifestImages returns the images of the image manifest file of the operator version. The reconcile
+// does not read the manifest when images are set from the environment, so it is then read once here.
+func (r *MultiClusterHubReconciler) manifestImages() []manifest.ManifestImage {
+	if r.CacheSpec.ManifestImages == nil {
+		manifestImages, err := manifest.GetManifestImages()
+		if err != nil {
+			r.Log.Info(fmt.Sprintf("Image manifest unavailable, image sources and components are not known: %s", err))
+			manifestImages = []manifest.ManifestImage{}
+		}
+		r.CacheSpec.ManifestImages = manifestImages
+	}
+	return r.CacheSpec.ManifestImages
+}
+
+func (r *MultiClusterHubReconciler) maintainSBOMConfigmap(mch *operatorv1.MultiClusterHub) error {
+	images := []sbom.Image{}
+	for key, ref := range r.CacheSpec.ImageOverrides {
+		img := sbom.Image{Key: key, Reference: ref}
+		if r.CacheSpec.ImageProvenance != nil {
+			img.Source = r.CacheSpec.ImageProvenance.Images[key].Source
+		}
+		images = append(images, img)
+	}
+
+	// Build metadata is only known from the manifest file; images set by other layers are listed without it
+	build := map[string]manifest.ManifestImage{}
+	for _, mi := range r.manifestImages() {
+		build[mi.ImageKey] = mi
+	}
+	for i := range images {
+		mi, ok := build[images[i].Key]
+		// Only attribute the build to images with the digest the manifest records for it
+		if ok && mi.ImageDigest != "" && strings.HasSuffix(images[i].Reference, "@"+mi.ImageDigest) {
+			images[i].GitRepository = mi.GitRepository
+			images[i].GitSha = mi.GitSha256
+		}
+	}
+
+	bom, err := sbom.CycloneDX(r.CacheSpec.ManifestVersion, images)
+	if err != nil {
+		return err
+	}
+
+	configmap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("mch-sbom-%s", r.CacheSpec.ManifestVersion),
+			Namespace: mch.Namespace,
+		},
+	}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: configmap.Name, Namespace: configmap.Namespace}, configmap)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if exists && configmap.Data[sbomConfigmapKey] == string(bom) {
+		return nil
+	}
+
+	configmap.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(mch, mch.GetObjectKind().GroupVersionKind()),
+	})
+	configmap.SetLabels(map[string]string{
+		"ocm-configmap-type":  "sbom",
+		"ocm-release-version": r.CacheSpec.ManifestVersion,
+	})
+	configmap.SetAnnotations(map[string]string{"installer.open-cluster-management.io/media-type": sbom.MediaType})
+	configmap.Data = map[string]string{sbomConfigmapKey: string(bom)}
+
+	if exists {
+		return r.Client.Update(context.TODO(), configmap)
+	}
+	return r.Client.Create(context.TODO(), configmap)
+}
+
+// imageProvenanceAnnotation returns the JSON encoded provenance of the cached image overrides
+func (r *MultiClusterHubReconciler) imageProvenanceAnnotation() (string, error) {
+	if r.CacheSpec.ImageProvenance == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(r.CacheSpec.ImageProvenance)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode image provenance: %w", err)
+	}
+	return string(data), nil
+}
+
 // listDeployments gets all deployments in the given namespaces
 func (r *MultiClusterHubReconciler) listDeployments(namespaces []string) ([]*appsv1.Deployment, error) {
 	var ret []*appsv1.Deployment
@@ -856,7 +995,7 @@ func (r *MultiClusterHubReconciler) listCustomResources(m *operatorv1.MultiClust
 		mceCSV = nil
 	}
 
-	mce, err := r.GetMultiClusterEngine(multiclusterengine.MultiClusterEngine(m))
+	mce, err := r.GetMultiClusterEngine(multiclusterengine.MultiClusterEngine(m, nil))
 	if err != nil {
 		mce = nil
 	}
@@ -966,6 +1105,11 @@ func (r *MultiClusterHubReconciler) ensureSubscriptionOperatorIsRunning(mch *ope
 		return ctrl.Result{}, nil
 	}
 
+	// skip check if charts are not installed through appsubs
+	if mch.InstallEngine() == operatorv1.InstallEngineHelm {
+		return ctrl.Result{}, nil
+	}
+
 	selfDeployment, exists := getDeploymentByName(allDeps, utils.MCHOperatorName)
 	if !exists {
 		// Deployment doesn't exist so this is either being run locally or with unit tests
@@ -1097,12 +1241,20 @@ func (r *MultiClusterHubReconciler) ensureMultiClusterEngine(multiClusterHub *op
 		r.Log.Info(fmt.Sprintf("Overridding MultiClusterEngine Subscription: %s", mceAnnotationOverrides))
 	}
 
-	result, err = r.ensureOLMSubscription(multiClusterHub, multiclusterengine.Subscription(multiClusterHub, subConfig))
+	mceSub := multiclusterengine.Subscription(multiClusterHub, subConfig)
+	result, err = r.ensureOLMSubscription(multiClusterHub, mceSub)
 	if result != (ctrl.Result{}) {
 		return result, err
 	}
 
-	result, err = r.ensureMultiClusterEngineCR(multiClusterHub, multiclusterengine.MultiClusterEngine(multiClusterHub))
+	// Registry mirror rules are checked against the images of the installed multiclusterengine
+	mceImages := map[string]string{}
+	if csv, err := r.GetCSVFromSubscription(mceSub); err == nil {
+		mceImages = multiClusterEngineImages([]*unstructured.Unstructured{csv})
+	}
+	updateRegistryMirrorsCondition(multiClusterHub, mceImages)
+
+	result, err = r.ensureMultiClusterEngineCR(multiClusterHub, multiclusterengine.MultiClusterEngine(multiClusterHub, mceImages))
 	if result != (ctrl.Result{}) {
 		return result, err
 	}
@@ -1110,6 +1262,30 @@ func (r *MultiClusterHubReconciler) ensureMultiClusterEngine(multiClusterHub *op
 	return ctrl.Result{}, nil
 }
 
+// updateRegistryMirrorsCondition reports registry mirror rules the multiclusterengine cannot be given for
+// its images
+func updateRegistryMirrorsCondition(m *operatorv1.MultiClusterHub, mceImages map[string]string) {
+	mirrors := utils.GetRegistryMirrors(m)
+	if len(mirrors) == 0 {
+		RemoveHubCondition(&m.Status, operatorv1.RegistryMirrorsUnsupported)
+		return
+	}
+	if len(mceImages) == 0 {
+		condition := NewHubCondition(operatorv1.RegistryMirrorsUnsupported, metav1.ConditionTrue, MCEImagesUnknownReason,
+			"Registry mirror rules are passed to the multicluster engine once its ClusterServiceVersion lists its images")
+		SetHubCondition(&m.Status, *condition)
+		return
+	}
+	if _, ok := multiclusterengine.MirrorImageRepository(mirrors, mceImages); !ok {
+		condition := NewHubCondition(operatorv1.RegistryMirrorsUnsupported, metav1.ConditionTrue, MCEMirrorsUnsupportedReason,
+			"The multicluster engine accepts a single image repository for all of its images, which the registry mirror rules "+
+				"do not reduce to. Mirror its images with an ImageContentSourcePolicy instead.")
+		SetHubCondition(&m.Status, *condition)
+		return
+	}
+	RemoveHubCondition(&m.Status, operatorv1.RegistryMirrorsUnsupported)
+}
+
 func (r *MultiClusterHubReconciler) prepareForMultiClusterEngineInstall(multiClusterHub *operatorv1.MultiClusterHub) (ctrl.Result, error) {
 	ctx := context.Background()
 
@@ -0,0 +1,373 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/channel"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/subscription"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// componentActions holds the functions that install and remove a single component
+type componentActions struct {
+	install func(m *operatorv1.MultiClusterHub) (ctrl.Result, error)
+	remove  func(m *operatorv1.MultiClusterHub) (ctrl.Result, error)
+	// subscription builds the appsub of a component installed from a chart of the helm repo
+	subscription func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured
+}
+
+// componentActions returns the install and remove steps for each component managed by the hub
+func (r *MultiClusterHubReconciler) componentActions() map[string]componentActions {
+	clusterBackup := withReleaseVersion(r.withComponentValues(operatorv1.ClusterBackup, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+		return subscription.ClusterBackup(m, r.CacheSpec.ImageOverrides)
+	}))
+	return map[string]componentActions{
+		operatorv1.Repo: {
+			install: func(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+				result, err := r.ensureHelmRepoAuth(m)
+				if result != (ctrl.Result{}) || err != nil {
+					return result, err
+				}
+				dep, err := r.helmRepoDeployment(m)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+				result, err = r.ensureDeployment(m, dep)
+				if result != (ctrl.Result{}) {
+					return result, err
+				}
+				result, err = r.ensureService(m, helmrepo.Service(m))
+				if result != (ctrl.Result{}) {
+					return result, err
+				}
+				result, err = r.ensureHelmRepoCA(m)
+				if result != (ctrl.Result{}) || err != nil {
+					return result, err
+				}
+				// The Helm install engine reads charts from the repo itself, without a channel
+				if m.InstallEngine() == operatorv1.InstallEngineHelm {
+					return ctrl.Result{}, nil
+				}
+				return r.ensureChannel(m, channel.Channel(m))
+			},
+			remove: func(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+				result, err := r.ensureNoDeployment(m, helmrepo.Deployment(m, r.CacheSpec.ImageOverrides))
+				if result != (ctrl.Result{}) {
+					return result, err
+				}
+				result, err = r.ensureNoService(m, helmrepo.Service(m))
+				if result != (ctrl.Result{}) {
+					return result, err
+				}
+				if m.InstallEngine() != operatorv1.InstallEngineHelm {
+					result, err = r.ensureNoUnstructured(m, channel.Channel(m))
+					if result != (ctrl.Result{}) || err != nil {
+						return result, err
+					}
+				}
+				return r.ensureNoHelmRepoCredentials(m)
+			},
+		},
+		// The multicluster-engine has no removal step; it is uninstalled only by the finalizer
+		operatorv1.MultiClusterEngine: {
+			install: r.ensureMultiClusterEngine,
+		},
+		operatorv1.ManagementIngress: r.subscriptionActions(operatorv1.ManagementIngress, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+			return subscription.ManagementIngress(m, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain)
+		}),
+		operatorv1.Console: r.subscriptionActions(operatorv1.Console, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+			return subscription.Console(m, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain)
+		}),
+		operatorv1.Insights: r.subscriptionActions(operatorv1.Insights, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+			return subscription.Insights(m, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain)
+		}),
+		operatorv1.GRC: r.subscriptionActions(operatorv1.GRC, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+			return subscription.GRC(m, r.CacheSpec.ImageOverrides)
+		}),
+		operatorv1.ClusterLifecycle: r.subscriptionActions(operatorv1.ClusterLifecycle, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+			return subscription.ClusterLifecycle(m, r.CacheSpec.ImageOverrides)
+		}),
+		operatorv1.Volsync: r.subscriptionActions(operatorv1.Volsync, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+			return subscription.Volsync(m, r.CacheSpec.ImageOverrides)
+		}),
+		operatorv1.Search: r.subscriptionActions(operatorv1.Search, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+			return subscription.Search(m, r.CacheSpec.ImageOverrides)
+		}),
+		operatorv1.ClusterBackup: {
+			subscription: clusterBackup,
+			install: func(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+				result, err := r.ensureNamespace(m, subscription.Namespace())
+				if result != (ctrl.Result{}) {
+					return result, err
+				}
+				return r.ensureChart(m, clusterBackup(m))
+			},
+			remove: func(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+				result, err := r.ensureNoChart(m, clusterBackup(m))
+				if result != (ctrl.Result{}) {
+					return result, err
+				}
+				return r.ensureNoNamespace(m, subscription.NamespaceUnstructured())
+			},
+		},
+		operatorv1.ClusterProxyAddon: r.subscriptionActions(operatorv1.ClusterProxyAddon, func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+			return subscription.ClusterProxyAddon(m, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain)
+		}),
+	}
+}
+
+// subscriptionActions returns the actions for a component installed from the chart of a single appsub
+func (r *MultiClusterHubReconciler) subscriptionActions(component string, build func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured) componentActions {
+	sub := withReleaseVersion(r.withComponentValues(component, build))
+	return componentActions{
+		subscription: sub,
+		install: func(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+			return r.ensureChart(m, sub(m))
+		},
+		remove: func(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+			return r.ensureNoChart(m, sub(m))
+		},
+	}
+}
+
+// pauseComponent keeps the subscription operator from reconciling the chart of an unmanaged component, so
+// that manual changes to its resources are kept. The operator neither installs nor removes an unmanaged
+// component: the appsub is otherwise left as it is, and is brought back in line once the component is
+// managed again. Nothing else is needed for components the operator applies itself.
+func (r *MultiClusterHubReconciler) pauseComponent(m *operatorv1.MultiClusterHub, name string, a componentActions) error {
+	if a.subscription == nil || m.InstallEngine() == operatorv1.InstallEngineHelm {
+		return nil
+	}
+	want := a.subscription(m)
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(want.GroupVersionKind())
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: want.GetName(), Namespace: want.GetNamespace()}, found)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	annotations := found.GetAnnotations()
+	if annotations[channel.AnnotationReconcileRate] == string(operatorv1.ReconcileRateOff) {
+		return nil
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[channel.AnnotationReconcileRate] = string(operatorv1.ReconcileRateOff)
+	found.SetAnnotations(annotations)
+	r.Log.Info("Pausing unmanaged component", "Component", name, "Subscription", found.GetName())
+	return r.Client.Update(context.TODO(), found)
+}
+
+// withComponentValues returns a builder of the component appsub passing the values set for the component
+// to its chart. Values that cannot be read are left out; chart verification holds the component back.
+func (r *MultiClusterHubReconciler) withComponentValues(component string, build func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured) func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+	return func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+		sub := build(m)
+		values, err := m.ComponentValues(component)
+		if err != nil {
+			r.Log.Error(err, "Ignoring component values", "Component", component)
+			return sub
+		}
+		subscription.AddComponentValues(sub, values)
+		return sub
+	}
+}
+
+// foundationComponents are the components the others build on. They are reconciled ahead of the hub
+// resources, which only the other components need.
+var foundationComponents = []string{operatorv1.Repo, operatorv1.MultiClusterEngine}
+
+// reconcileComponents removes disabled components in reverse dependency order, then installs enabled
+// components in dependency order, wave by wave during an upgrade. Components held back are reported
+// through the hub conditions.
+func (r *MultiClusterHubReconciler) reconcileComponents(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+	order := operatorv1.ComponentInstallOrder()
+	actions := r.componentActions()
+	r.CacheSpec.Capabilities = nil
+
+	for i := len(order) - 1; i >= 0; i-- {
+		name := order[i]
+		a, ok := actions[name]
+		if !ok || a.remove == nil || m.Enabled(name) || m.Unmanaged(name) {
+			continue
+		}
+		result, err := a.remove(m)
+		if result != (ctrl.Result{}) {
+			return result, err
+		}
+	}
+
+	blocked := []string{}
+	missingImages := []string{}
+	badCharts := []string{}
+	pendingCharts := []string{}
+	incompatible := []string{}
+	unpinned := []string{}
+	rolloutFailure := ""
+	waveWaiting := false
+	rolledOut := []string{}
+	statusKeys := map[string][]string{}
+	resourcesDeployed := false
+waves:
+	for i, wave := range rolloutWaves(m) {
+		if i > 0 && len(rolledOut) > 0 {
+			statuses, err := r.currentComponentStatuses(m)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			failed, reason, pending := rolloutProgress(rolledOut, statusKeys, statuses)
+			if failed != "" {
+				r.Log.Info(fmt.Sprintf("Upgrade rollout stopped before wave %d: component %s failed", i+1, failed))
+				rolloutFailure = fmt.Sprintf("%s (%s)", failed, reason)
+				break waves
+			}
+			unfinished, err := r.unfinishedRollouts(m, rolledOut, actions)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			for _, c := range unfinished {
+				if !utils.Contains(pending, c) {
+					pending = append(pending, c)
+				}
+			}
+			if len(pending) > 0 {
+				message := fmt.Sprintf("Upgrade rollout wave %d waits for components to be available: %s", i+1, strings.Join(pending, ", "))
+				r.Log.Info(message)
+				condition := NewHubCondition(operatorv1.Progressing, metav1.ConditionTrue, RolloutWaveProgressingReason, message)
+				SetHubConditionMessage(&m.Status, *condition)
+				waveWaiting = true
+				break waves
+			}
+		}
+		for _, name := range wave {
+			a, ok := actions[name]
+			if !ok {
+				continue
+			}
+			if m.Unmanaged(name) {
+				if err := r.pauseComponent(m, name, a); err != nil {
+					return ctrl.Result{}, err
+				}
+				continue
+			}
+			// multicluster-engine is installed regardless of its toggle
+			if !m.Enabled(name) && name != operatorv1.MultiClusterEngine {
+				continue
+			}
+			// A disabled dependency, images or a chart failing their checks block the component; a chart
+			// that cannot be verified yet only delays it
+			if disabled := m.DisabledDependencies(name); len(disabled) > 0 {
+				r.Log.Info(fmt.Sprintf("Component %s is blocked by disabled dependencies: %s", name, strings.Join(disabled, ", ")))
+				blocked = append(blocked, fmt.Sprintf("%s (requires %s)", name, strings.Join(disabled, ", ")))
+				continue
+			}
+			if missing := r.CacheSpec.UnavailableImages[name]; len(missing) > 0 {
+				r.Log.Info(fmt.Sprintf("Component %s is blocked by unavailable images", name))
+				missingImages = append(missingImages, fmt.Sprintf("%s (%s)", name, strings.Join(missing, ", ")))
+				continue
+			}
+			if problem, ok := r.CacheSpec.IncompatibleImages[name]; ok {
+				r.Log.Info(fmt.Sprintf("Component %s is blocked by an incompatible image", name))
+				incompatible = append(incompatible, fmt.Sprintf("%s (%s)", name, problem))
+				continue
+			}
+			if tagged := r.CacheSpec.UnpinnedImages[name]; len(tagged) > 0 {
+				r.Log.Info(fmt.Sprintf("Component %s is blocked by images not pinned by digest", name))
+				unpinned = append(unpinned, fmt.Sprintf("%s (%s)", name, strings.Join(tagged, ", ")))
+				continue
+			}
+			if problem, ok := r.CacheSpec.ChartProblems[name]; ok {
+				r.Log.Info(fmt.Sprintf("Component %s is blocked by chart verification", name))
+				badCharts = append(badCharts, fmt.Sprintf("%s (%s)", name, problem))
+				continue
+			}
+			if problem, ok := r.CacheSpec.PendingCharts[name]; ok {
+				r.Log.Info(fmt.Sprintf("Component %s waits for chart verification", name))
+				pendingCharts = append(pendingCharts, fmt.Sprintf("%s (%s)", name, problem))
+				continue
+			}
+			if !resourcesDeployed && !utils.Contains(foundationComponents, name) {
+				if err := r.ensureHubResources(m); err != nil {
+					return ctrl.Result{}, err
+				}
+				resourcesDeployed = true
+			}
+			result, err := a.install(m)
+			if result != (ctrl.Result{}) {
+				return result, err
+			}
+			rolledOut = append(rolledOut, name)
+			statusKeys[name] = componentStatusKeys(m, name, a)
+		}
+	}
+
+	if len(pendingCharts) > 0 {
+		message := fmt.Sprintf("Components wait for their charts to be verified: %s", strings.Join(pendingCharts, "; "))
+		condition := NewHubCondition(operatorv1.Progressing, metav1.ConditionTrue, ChartVerificationPendingReason, message)
+		SetHubCondition(&m.Status, *condition)
+	}
+
+	messages := []string{}
+	if len(blocked) > 0 {
+		messages = append(messages, fmt.Sprintf("Components blocked by a disabled dependency: %s", strings.Join(blocked, "; ")))
+	}
+	if len(missingImages) > 0 {
+		messages = append(messages, fmt.Sprintf("Components blocked by images missing from their registry: %s", strings.Join(missingImages, "; ")))
+	}
+	if len(incompatible) > 0 {
+		messages = append(messages, fmt.Sprintf("Components blocked by incompatible images: %s", strings.Join(incompatible, "; ")))
+	}
+	if len(unpinned) > 0 {
+		messages = append(messages, fmt.Sprintf("Components blocked by images not pinned by digest as image digest policy %s requires: %s",
+			m.Spec.ImageDigestPolicy, strings.Join(unpinned, "; ")))
+	}
+	if len(badCharts) > 0 {
+		messages = append(messages, fmt.Sprintf("Components blocked by charts failing verification: %s", strings.Join(badCharts, "; ")))
+	}
+	if rolloutFailure != "" {
+		messages = append(messages, fmt.Sprintf("Upgrade rollout stopped by a failed component: %s", rolloutFailure))
+	}
+	if len(messages) > 0 {
+		reason := DependencyDisabledReason
+		if len(blocked) == 0 && len(missingImages) > 0 {
+			reason = ImagesUnavailableReason
+		} else if len(blocked) == 0 && len(incompatible) > 0 {
+			reason = IncompatibleImageReason
+		} else if len(blocked) == 0 && len(unpinned) > 0 {
+			reason = ImagesNotPinnedReason
+		} else if len(blocked) == 0 && len(badCharts) > 0 {
+			reason = ChartVerificationFailedReason
+		} else if len(blocked) == 0 {
+			reason = RolloutWaveFailedReason
+		}
+		condition := NewHubCondition(operatorv1.Blocked, metav1.ConditionTrue, reason, strings.Join(messages, ". "))
+		SetHubCondition(&m.Status, *condition)
+	} else if c := GetHubCondition(m.Status, operatorv1.Blocked); c != nil && (c.Reason == DependencyDisabledReason || c.Reason == ImagesUnavailableReason || c.Reason == IncompatibleImageReason ||
+		c.Reason == ImagesNotPinnedReason || c.Reason == ChartVerificationFailedReason || c.Reason == RolloutWaveFailedReason) {
+		RemoveHubCondition(&m.Status, operatorv1.Blocked)
+	}
+
+	if !resourcesDeployed {
+		if err := r.ensureHubResources(m); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if waveWaiting {
+		return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+	}
+	return ctrl.Result{}, nil
+}
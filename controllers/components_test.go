@@ -0,0 +1,192 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	mcev1 "github.com/stolostron/backplane-operator/api/v1"
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/channel"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/multiclusterengine"
+	"github.com/stolostron/multiclusterhub-operator/pkg/rendering"
+	"github.com/stolostron/multiclusterhub-operator/pkg/subscription"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_unmanagedComponent(t *testing.T) {
+	mch := &operatorsv1.MultiClusterHub{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "operator.open-cluster-management.io/v1", Kind: "MultiClusterHub"},
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test", UID: "mch-uid"},
+		Spec: operatorsv1.MultiClusterHubSpec{Overrides: &operatorsv1.Overrides{
+			Components: []operatorsv1.ComponentConfig{
+				{Name: operatorsv1.GRC, Enabled: true, ManagementState: operatorsv1.ManagementStateUnmanaged},
+			},
+		}},
+	}
+	// The built appsub holds typed slices the fake client cannot copy, so a bare one stands in for it
+	built := subscription.GRC(mch, map[string]string{})
+	appsub := &unstructured.Unstructured{}
+	appsub.SetGroupVersionKind(built.GroupVersionKind())
+	appsub.SetName(built.GetName())
+	appsub.SetNamespace(built.GetNamespace())
+	appsub.SetLabels(built.GetLabels())
+	appsub.SetOwnerReferences(built.GetOwnerReferences())
+	appsub.SetUID("abcdef-uid")
+	helmRelease := &unstructured.Unstructured{}
+	helmRelease.SetGroupVersionKind(schema.GroupVersionKind{Group: "apps.open-cluster-management.io", Kind: "HelmRelease", Version: "v1"})
+	helmRelease.SetName("grc-abcde")
+	helmRelease.SetNamespace("test")
+	helmRelease.SetLabels(map[string]string{"installer.name": "multiclusterhub", "installer.namespace": "test"})
+
+	r := &MultiClusterHubReconciler{
+		Client: fake.NewFakeClient(appsub, helmRelease),
+		Log:    zap.New(),
+	}
+
+	if err := r.pauseComponent(mch, operatorsv1.GRC, r.componentActions()[operatorsv1.GRC]); err != nil {
+		t.Fatalf("pauseComponent() error = %v", err)
+	}
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(appsub.GroupVersionKind())
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "grc-sub", Namespace: "test"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if rate := found.GetAnnotations()[channel.AnnotationReconcileRate]; rate != string(operatorsv1.ReconcileRateOff) {
+		t.Errorf("pauseComponent() reconcile rate of the unmanaged appsub = %q, want off", rate)
+	}
+
+	if err := r.orphanUnmanagedComponents(r.Log, mch); err != nil {
+		t.Fatalf("orphanUnmanagedComponents() error = %v", err)
+	}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "grc-sub", Namespace: "test"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := found.GetLabels()["installer.name"]; ok || len(found.GetOwnerReferences()) != 0 {
+		t.Errorf("orphanUnmanagedComponents() left appsub labels %v, owners %v", found.GetLabels(), found.GetOwnerReferences())
+	}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "grc-abcde", Namespace: "test"}, helmRelease); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := helmRelease.GetLabels()["installer.name"]; ok {
+		t.Errorf("orphanUnmanagedComponents() left helmrelease labels %v", helmRelease.GetLabels())
+	}
+}
+
+func Test_orphanUnmanagedComponents(t *testing.T) {
+	mch := &operatorsv1.MultiClusterHub{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "operator.open-cluster-management.io/v1", Kind: "MultiClusterHub"},
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test", UID: "mch-uid"},
+		Spec: operatorsv1.MultiClusterHubSpec{
+			InstallEngine: operatorsv1.InstallEngineHelm,
+			Overrides: &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+				{Name: operatorsv1.Repo, Enabled: true, ManagementState: operatorsv1.ManagementStateUnmanaged},
+				{Name: operatorsv1.MultiClusterEngine, Enabled: true, ManagementState: operatorsv1.ManagementStateUnmanaged},
+				{Name: operatorsv1.GRC, Enabled: true, ManagementState: operatorsv1.ManagementStateUnmanaged},
+			}},
+		},
+	}
+	installer := map[string]string{"installer.name": "multiclusterhub", "installer.namespace": "test"}
+	owner := []metav1.OwnerReference{*metav1.NewControllerRef(mch, mch.GroupVersionKind())}
+
+	repo := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: helmrepo.HelmRepoName, Namespace: "test", Labels: installer, OwnerReferences: owner}}
+	mce := &mcev1.MultiClusterEngine{ObjectMeta: metav1.ObjectMeta{Name: multiclusterengine.MulticlusterengineName, Labels: installer}}
+
+	// The release of the unmanaged component, with a CRD of its chart and a recorded object
+	release := subscription.GRC(mch, map[string]string{}).GetName()
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(apixv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"))
+	crd.SetName("policies.policy.open-cluster-management.io")
+	crd.SetLabels(map[string]string{"installer.name": "multiclusterhub", "installer.namespace": "test", rendering.ReleaseLabel: release})
+	controller := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "grc-policy-propagator", Namespace: "test", Labels: installer, OwnerReferences: owner}}
+	record, err := rendering.Record(&rendering.Release{Name: release, Chart: "grc", Version: "2.5.0"}, "test",
+		[]rendering.ObjectRef{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "test", Name: controller.Name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range installer {
+		record.Labels[k] = v
+	}
+
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := mcev1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	// Without access to the helm repo, unmanaged releases are orphaned from what the cluster holds
+	r := &MultiClusterHubReconciler{
+		Client: fake.NewClientBuilder().WithScheme(s).WithObjects(repo, mce, crd, controller, record).Build(),
+		Log:    zap.New(),
+	}
+	if err := r.orphanUnmanagedComponents(r.Log, mch); err != nil {
+		t.Fatalf("orphanUnmanagedComponents() error = %v", err)
+	}
+	if err := r.cleanupFoundation(r.Log, mch); err != nil {
+		t.Fatalf("cleanupFoundation() error = %v", err)
+	}
+	if err := r.cleanupMultiClusterEngine(r.Log, mch); err != nil {
+		t.Fatalf("cleanupMultiClusterEngine() error = %v", err)
+	}
+
+	for _, obj := range []client.Object{repo, mce, crd, controller, record} {
+		if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj); err != nil {
+			t.Fatalf("unmanaged %T %s was removed: %v", obj, obj.GetName(), err)
+		}
+		if _, ok := obj.GetLabels()["installer.name"]; ok || len(obj.GetOwnerReferences()) != 0 {
+			t.Errorf("orphanUnmanagedComponents() left %T %s labels %v, owners %v", obj, obj.GetName(), obj.GetLabels(), obj.GetOwnerReferences())
+		}
+	}
+}
+
+func Test_reconcileComponents_foundationFirst(t *testing.T) {
+	t.Setenv("TEMPLATES_PATH", t.TempDir())
+	mch := &operatorsv1.MultiClusterHub{
+		TypeMeta: metav1.TypeMeta{APIVersion: "operator.open-cluster-management.io/v1", Kind: "MultiClusterHub"},
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test", UID: "mch-uid",
+			Annotations: map[string]string{utils.AnnotationHelmRepoTLSSecret: "custom-tls"}},
+		Spec: operatorsv1.MultiClusterHubSpec{Overrides: &operatorsv1.Overrides{
+			Components: []operatorsv1.ComponentConfig{
+				{Name: operatorsv1.Repo, Enabled: true},
+				{Name: operatorsv1.MultiClusterEngine, Enabled: true, ManagementState: operatorsv1.ManagementStateUnmanaged},
+				{Name: operatorsv1.GRC, Enabled: true},
+			},
+		}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-tls", Namespace: "test"},
+		Data: map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key"),
+			helmrepo.SecretCAKey: []byte("custom-ca")},
+	}
+	r := &MultiClusterHubReconciler{
+		Client:    fake.NewFakeClient(secret),
+		Log:       zap.New(),
+		CacheSpec: CacheSpec{ImageOverrides: map[string]string{helmrepo.ImageKey: "quay.io/stolostron/multiclusterhub-repo:2.5.0"}},
+	}
+
+	// The hub resources cannot be read, which holds back the components after the helm repo only
+	if _, err := r.reconcileComponents(mch); err == nil {
+		t.Fatal("reconcileComponents() deployed the hub resources from an empty templates directory")
+	}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: helmrepo.HelmRepoName, Namespace: "test"}, &appsv1.Deployment{}); err != nil {
+		t.Errorf("reconcileComponents() did not install the helm repo ahead of the hub resources: %v", err)
+	}
+	if c := GetHubCondition(mch.Status, operatorsv1.Progressing); c == nil || c.Reason != ResourceRenderReason {
+		t.Errorf("reconcileComponents() Progressing condition = %v, want reason %s", c, ResourceRenderReason)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/registry"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// resolvedDigests maps the tag references resolved for one manifest version to their digest reference
+type resolvedDigests struct {
+	manifestVersion string
+	digests         map[string]string
+}
+
+// enforceDigestPolicy applies spec.imageDigestPolicy to the hub image references. Under Resolve, tag
+// references are replaced by the digest their registry reports. It returns the images along with the image
+// keys that are still not pinned by digest, each followed by the reason when it could not be resolved.
+func (r *MultiClusterHubReconciler) enforceDigestPolicy(ctx context.Context, m *operatorv1.MultiClusterHub,
+	images map[string]string) (map[string]string, []string, error) {
+	policy := m.Spec.ImageDigestPolicy
+	if policy != operatorv1.ImageDigestReject && policy != operatorv1.ImageDigestResolve {
+		return images, nil, nil
+	}
+
+	tagged := utils.TagReferences(images)
+	if policy == operatorv1.ImageDigestResolve && len(tagged) > 0 {
+		var err error
+		if images, tagged, err = r.resolveDigests(ctx, m, images, tagged); err != nil {
+			return nil, nil, err
+		}
+	}
+	return images, tagged, nil
+}
+
+// resolveDigests replaces the tagged references of images with their digest reference and returns the keys
+// that could not be resolved, with the reason
+func (r *MultiClusterHubReconciler) resolveDigests(ctx context.Context, m *operatorv1.MultiClusterHub, images map[string]string,
+	tagged []string) (map[string]string, []string, error) {
+	cache := r.CacheSpec.ResolvedDigests
+	if cache == nil || cache.manifestVersion != r.CacheSpec.ManifestVersion {
+		cache = &resolvedDigests{manifestVersion: r.CacheSpec.ManifestVersion, digests: map[string]string{}}
+		r.CacheSpec.ResolvedDigests = cache
+	}
+	resolved := make(map[string]string, len(images))
+	for key, ref := range images {
+		resolved[key] = ref
+	}
+
+	lookups := map[string]string{}
+	for _, key := range tagged {
+		if digestRef, ok := cache.digests[images[key]]; ok {
+			resolved[key] = digestRef
+		} else {
+			lookups[key] = images[key]
+		}
+	}
+	if len(lookups) == 0 {
+		return resolved, nil, nil
+	}
+
+	creds, err := r.pullSecretCredentials(ctx, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, imagePreflightTimeout)
+	defer cancel()
+
+	unresolved := []string{}
+	for key, result := range registry.NewClient(r.RegistryClient, creds).ResolveDigests(ctx, lookups) {
+		if result.Err != nil {
+			unresolved = append(unresolved, fmt.Sprintf("%s (%s)", key, result.Err))
+			continue
+		}
+		cache.digests[result.Reference] = result.Resolved
+		resolved[key] = result.Resolved
+	}
+	sort.Strings(unresolved)
+	return resolved, unresolved, nil
+}
+
+// checkEngineDigests holds back the multicluster engine when its related images are not pinned by digest under
+// an image digest policy other than Allow. The engine deploys the images of its own bundle, which the hub can
+// neither rewrite nor resolve, so the engine is neither installed nor upgraded until its bundle pins them.
+func (r *MultiClusterHubReconciler) checkEngineDigests(m *operatorv1.MultiClusterHub, mceImages map[string]string) {
+	r.CacheSpec.UnpinnedImages = nil
+	policy := m.Spec.ImageDigestPolicy
+	if policy != operatorv1.ImageDigestReject && policy != operatorv1.ImageDigestResolve {
+		return
+	}
+	if tagged := utils.TagReferences(mceImages); len(tagged) > 0 {
+		r.CacheSpec.UnpinnedImages = map[string][]string{operatorv1.MultiClusterEngine: tagged}
+	}
+}
+
+// multiClusterEngineImages returns the related images listed by the multicluster-engine
+// ClusterServiceVersion, keyed by name
+func multiClusterEngineImages(allCRs []*unstructured.Unstructured) map[string]string {
+	images := map[string]string{}
+	for _, cr := range allCRs {
+		if cr == nil || cr.GetKind() != "ClusterServiceVersion" || !strings.Contains(cr.GetName(), utils.MCESubscriptionName) {
+			continue
+		}
+		related, _, _ := unstructured.NestedSlice(cr.Object, "spec", "relatedImages")
+		for _, entry := range related {
+			img, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := img["name"].(string)
+			ref, _ := img["image"].(string)
+			if name != "" {
+				images[name] = ref
+			}
+		}
+	}
+	return images
+}
+
+// mutableImageReferences stops the rollout of images that are not pinned by digest as the image digest
+// policy requires and names them in the Blocked condition
+func mutableImageReferences(m *operatorv1.MultiClusterHub, keys []string) (ctrl.Result, error) {
+	condition := NewHubCondition(operatorv1.Blocked, metav1.ConditionTrue, MutableImageReferencesReason,
+		fmt.Sprintf("Image references must be pinned by digest under image digest policy %s: %s", m.Spec.ImageDigestPolicy, strings.Join(keys, ", ")))
+	SetHubCondition(&m.Status, *condition)
+	return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+}
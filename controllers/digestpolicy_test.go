@@ -0,0 +1,134 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_enforceDigestPolicy(t *testing.T) {
+	var lookups int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&lookups, 1)
+		if r.URL.Path == "/v2/stolostron/console/manifests/2.5.0" {
+			w.Header().Set("Docker-Content-Digest", "sha256:abc")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	images := map[string]string{
+		"console":      host + "/stolostron/console:2.5.0",
+		"grc_ui":       host + "/stolostron/grc-ui:2.5.0",
+		"search_api":   host + "/stolostron/search-api@sha256:def",
+		"oauth_proxy":  "",
+		"insights_api": host + "/stolostron/insights@sha256:123",
+	}
+	tests := []struct {
+		name             string
+		policy           operatorv1.ImageDigestPolicyType
+		wantImages       map[string]string
+		wantNonCompliant []string
+	}{
+		{
+			name:       "Allow",
+			wantImages: images,
+		},
+		{
+			name:             "Reject",
+			policy:           operatorv1.ImageDigestReject,
+			wantImages:       images,
+			wantNonCompliant: []string{"console", "grc_ui"},
+		},
+		{
+			name:   "Resolve",
+			policy: operatorv1.ImageDigestResolve,
+			wantImages: map[string]string{
+				"console":      host + "/stolostron/console@sha256:abc",
+				"grc_ui":       host + "/stolostron/grc-ui:2.5.0",
+				"search_api":   host + "/stolostron/search-api@sha256:def",
+				"oauth_proxy":  "",
+				"insights_api": host + "/stolostron/insights@sha256:123",
+			},
+			wantNonCompliant: []string{"grc_ui (manifest unknown in " + host + "/stolostron/grc-ui)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mch := &operatorv1.MultiClusterHub{Spec: operatorv1.MultiClusterHubSpec{ImageDigestPolicy: tt.policy}}
+			r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(), Log: zap.New(), RegistryClient: server.Client()}
+
+			got, nonCompliant, err := r.enforceDigestPolicy(context.TODO(), mch, images)
+			if err != nil {
+				t.Fatalf("enforceDigestPolicy() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.wantImages) {
+				t.Errorf("enforceDigestPolicy() images = %v, want %v", got, tt.wantImages)
+			}
+			if (len(nonCompliant) > 0 || len(tt.wantNonCompliant) > 0) && !reflect.DeepEqual(nonCompliant, tt.wantNonCompliant) {
+				t.Errorf("enforceDigestPolicy() non-compliant = %v, want %v", nonCompliant, tt.wantNonCompliant)
+			}
+		})
+	}
+
+	// Resolved tags are not looked up again for the same manifest version
+	r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(), Log: zap.New(), RegistryClient: server.Client(),
+		CacheSpec: CacheSpec{ManifestVersion: "2.5.0"}}
+	mch := &operatorv1.MultiClusterHub{Spec: operatorv1.MultiClusterHubSpec{ImageDigestPolicy: operatorv1.ImageDigestResolve}}
+	consoleOnly := map[string]string{"console": images["console"]}
+	if _, _, err := r.enforceDigestPolicy(context.TODO(), mch, consoleOnly); err != nil {
+		t.Fatalf("enforceDigestPolicy() error = %v", err)
+	}
+	before := atomic.LoadInt32(&lookups)
+	if got, _, _ := r.enforceDigestPolicy(context.TODO(), mch, consoleOnly); got["console"] != host+"/stolostron/console@sha256:abc" || atomic.LoadInt32(&lookups) != before {
+		t.Errorf("enforceDigestPolicy() = %v, want the cached digest without a new lookup", got)
+	}
+	r.CacheSpec.ManifestVersion = "2.6.0"
+	if _, _, err := r.enforceDigestPolicy(context.TODO(), mch, consoleOnly); err != nil || atomic.LoadInt32(&lookups) == before {
+		t.Errorf("enforceDigestPolicy() error = %v, want the tag looked up again for a new manifest version", err)
+	}
+}
+
+func Test_checkEngineDigests(t *testing.T) {
+	t.Setenv("TEMPLATES_PATH", "../pkg/templates")
+	mceImages := map[string]string{
+		"backplane_operator": "quay.io/stolostron/backplane-operator@sha256:aaa",
+		"hive":               "quay.io/stolostron/hive:2.0",
+	}
+	mch := &operatorv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"},
+		Spec:       operatorv1.MultiClusterHubSpec{ImageDigestPolicy: operatorv1.ImageDigestReject},
+	}
+	r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(), Log: zap.New()}
+
+	r.checkEngineDigests(mch, mceImages)
+	if got := r.CacheSpec.UnpinnedImages[operatorv1.MultiClusterEngine]; !reflect.DeepEqual(got, []string{"hive"}) {
+		t.Fatalf("checkEngineDigests() unpinned images = %v, want hive", got)
+	}
+	// The engine is held back without being installed, which the fake client could not do
+	if _, err := r.reconcileComponents(mch); err != nil {
+		t.Fatalf("reconcileComponents() error = %v", err)
+	}
+	if c := GetHubCondition(mch.Status, operatorv1.Blocked); c == nil || c.Reason != ImagesNotPinnedReason || !strings.Contains(c.Message, "multicluster-engine (hive)") {
+		t.Errorf("reconcileComponents() Blocked condition = %+v, want the engine held back for hive", c)
+	}
+
+	mch.Spec.ImageDigestPolicy = operatorv1.ImageDigestAllow
+	r.checkEngineDigests(mch, mceImages)
+	if len(r.CacheSpec.UnpinnedImages) != 0 {
+		t.Errorf("checkEngineDigests() held back the engine under the Allow policy: %v", r.CacheSpec.UnpinnedImages)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// desiredPodDisruptionBudgets returns a budget for the helm repo and for every component deployment running
+// more than one replica. Nothing is budgeted with the Basic availability config, where a budget would
+// block node drains on single replicas.
+func desiredPodDisruptionBudgets(m *operatorv1.MultiClusterHub, componentDeps []*appsv1.Deployment) []*policyv1.PodDisruptionBudget {
+	pdbs := []*policyv1.PodDisruptionBudget{}
+	if utils.DefaultReplicaCount(m) < 2 {
+		return pdbs
+	}
+	if m.Enabled(operatorv1.Repo) {
+		pdbs = append(pdbs, helmrepo.PodDisruptionBudget(m))
+	}
+	for _, d := range componentDeps {
+		if d.Spec.Replicas == nil || *d.Spec.Replicas < 2 || d.Spec.Selector == nil {
+			continue
+		}
+		pdbs = append(pdbs, utils.PodDisruptionBudget(m, d.Name, d.Namespace, d.Spec.Selector.DeepCopy()))
+	}
+	return pdbs
+}
+
+// ensurePodDisruptionBudgets creates and updates the budgets of the highly available components, and
+// removes the budgets the operator created for components that are no longer highly available
+func (r *MultiClusterHubReconciler) ensurePodDisruptionBudgets(m *operatorv1.MultiClusterHub, componentDeps []*appsv1.Deployment) (ctrl.Result, error) {
+	componentDeps, err := r.unbudgetedDeployments(m, componentDeps)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	desired := map[types.NamespacedName]bool{}
+	for _, pdb := range desiredPodDisruptionBudgets(m, componentDeps) {
+		desired[types.NamespacedName{Name: pdb.Name, Namespace: pdb.Namespace}] = true
+		if err := r.ensurePodDisruptionBudget(pdb); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	existing, err := r.listPodDisruptionBudgets(m)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range existing {
+		pdb := &existing[i]
+		if desired[types.NamespacedName{Name: pdb.Name, Namespace: pdb.Namespace}] {
+			continue
+		}
+		r.Log.Info("Deleting PodDisruptionBudget", "Namespace", pdb.Namespace, "Name", pdb.Name)
+		if err := r.Client.Delete(context.TODO(), pdb); err != nil && !errors.IsNotFound(err) {
+			r.Log.Error(err, "Failed to delete PodDisruptionBudget", "Name", pdb.Name)
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// unbudgetedDeployments filters out the deployments whose pods are already selected by a budget the operator
+// did not create, such as one shipped with the component's chart. A second budget would only make evictions
+// of the pods fail.
+func (r *MultiClusterHubReconciler) unbudgetedDeployments(m *operatorv1.MultiClusterHub, deps []*appsv1.Deployment) ([]*appsv1.Deployment, error) {
+	budgets := map[string][]labels.Selector{}
+	unbudgeted := []*appsv1.Deployment{}
+	for _, d := range deps {
+		selectors, listed := budgets[d.Namespace]
+		if !listed {
+			pdbList := &policyv1.PodDisruptionBudgetList{}
+			if err := r.Client.List(context.TODO(), pdbList, client.InNamespace(d.Namespace)); err != nil {
+				r.Log.Error(err, "Failed to list PodDisruptionBudgets", "Namespace", d.Namespace)
+				return nil, err
+			}
+			for _, pdb := range pdbList.Items {
+				if pdb.Labels["installer.name"] == m.Name && pdb.Labels["installer.namespace"] == m.Namespace {
+					continue
+				}
+				selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+				if err != nil {
+					continue
+				}
+				selectors = append(selectors, selector)
+			}
+			budgets[d.Namespace] = selectors
+		}
+
+		budgeted := false
+		for _, selector := range selectors {
+			if selector.Matches(labels.Set(d.Spec.Template.Labels)) {
+				budgeted = true
+				break
+			}
+		}
+		if budgeted {
+			r.Log.V(1).Info("Deployment has a PodDisruptionBudget of its own", "Namespace", d.Namespace, "Name", d.Name)
+			continue
+		}
+		unbudgeted = append(unbudgeted, d)
+	}
+	return unbudgeted, nil
+}
+
+func (r *MultiClusterHubReconciler) ensurePodDisruptionBudget(pdb *policyv1.PodDisruptionBudget) error {
+	pdbLog := r.Log.WithValues("PodDisruptionBudget.Namespace", pdb.Namespace, "PodDisruptionBudget.Name", pdb.Name)
+
+	found := &policyv1.PodDisruptionBudget{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: pdb.Name, Namespace: pdb.Namespace}, found)
+	if errors.IsNotFound(err) {
+		if err := r.Client.Create(context.TODO(), pdb); err != nil {
+			pdbLog.Error(err, "Failed to create PodDisruptionBudget")
+			return err
+		}
+		pdbLog.Info("Created a new PodDisruptionBudget")
+		return nil
+	} else if err != nil {
+		pdbLog.Error(err, "Failed to get PodDisruptionBudget")
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(found.Spec, pdb.Spec) && utils.ContainsMap(found.Labels, pdb.Labels) {
+		return nil
+	}
+	found.Spec = pdb.Spec
+	if found.Labels == nil {
+		found.Labels = map[string]string{}
+	}
+	for k, v := range pdb.Labels {
+		found.Labels[k] = v
+	}
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		pdbLog.Error(err, "Failed to update PodDisruptionBudget")
+		return err
+	}
+	pdbLog.Info("Updated PodDisruptionBudget")
+	return nil
+}
+
+// listPodDisruptionBudgets returns the budgets created by the operator for the multiclusterhub
+func (r *MultiClusterHubReconciler) listPodDisruptionBudgets(m *operatorv1.MultiClusterHub) ([]policyv1.PodDisruptionBudget, error) {
+	pdbList := &policyv1.PodDisruptionBudgetList{}
+	err := r.Client.List(context.TODO(), pdbList, client.MatchingLabels{
+		"installer.name":      m.Name,
+		"installer.namespace": m.Namespace,
+	})
+	if err != nil {
+		r.Log.Error(err, "Failed to list PodDisruptionBudgets")
+		return nil, err
+	}
+	return pdbList.Items, nil
+}
+
+// ensureNoPodDisruptionBudgets removes every budget created by the operator for the multiclusterhub
+func (r *MultiClusterHubReconciler) ensureNoPodDisruptionBudgets(m *operatorv1.MultiClusterHub) error {
+	existing, err := r.listPodDisruptionBudgets(m)
+	if err != nil {
+		return err
+	}
+	for i := range existing {
+		if err := r.Client.Delete(context.TODO(), &existing[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_ensurePodDisruptionBudgets(t *testing.T) {
+	deployment := func(name string, replicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name, "component": "hub"}},
+				},
+			},
+		}
+	}
+	deps := []*appsv1.Deployment{deployment("grc-policy-propagator", 2), deployment("search-collector", 1), deployment("search-api", 2)}
+
+	mch := &operatorv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"},
+		Spec: operatorv1.MultiClusterHubSpec{
+			Overrides: &operatorv1.Overrides{Components: []operatorv1.ComponentConfig{{Name: operatorv1.Repo, Enabled: true}}},
+		},
+	}
+	// search-api ships a budget with its chart
+	chartBudget := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "search-api-pdb", Namespace: "test"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "search-api"}}},
+	}
+	r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(chartBudget), Log: zap.New()}
+
+	budgets := func() []string {
+		pdbList := &policyv1.PodDisruptionBudgetList{}
+		if err := r.Client.List(context.TODO(), pdbList); err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, pdb := range pdbList.Items {
+			if pdb.Name != chartBudget.Name {
+				names = append(names, pdb.Name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if _, err := r.ensurePodDisruptionBudgets(mch, deps); err != nil {
+		t.Fatalf("ensurePodDisruptionBudgets() error = %v", err)
+	}
+	if got := budgets(); len(got) != 2 || got[0] != "grc-policy-propagator" || got[1] != helmrepo.HelmRepoName {
+		t.Errorf("budgets with High availability = %v, want the helm repo and grc-policy-propagator", got)
+	}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(chartBudget), &policyv1.PodDisruptionBudget{}); err != nil {
+		t.Errorf("chart budget = %v, want it kept", err)
+	}
+
+	// Budgets of components that are no longer highly available are removed
+	mch.Spec.AvailabilityConfig = operatorv1.HABasic
+	if _, err := r.ensurePodDisruptionBudgets(mch, deps); err != nil {
+		t.Fatalf("ensurePodDisruptionBudgets() error = %v", err)
+	}
+	if got := budgets(); len(got) != 0 {
+		t.Errorf("budgets with Basic availability = %v, want none", got)
+	}
+}
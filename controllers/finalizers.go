@@ -17,9 +17,12 @@ import (
 	"github.com/stolostron/multiclusterhub-operator/pkg/channel"
 	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
 	"github.com/stolostron/multiclusterhub-operator/pkg/multiclusterengine"
+	"github.com/stolostron/multiclusterhub-operator/pkg/rendering"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -145,6 +148,11 @@ func (r *MultiClusterHubReconciler) cleanupCRDs(log logr.Logger, m *operatorsv1.
 func (r *MultiClusterHubReconciler) cleanupMultiClusterEngine(log logr.Logger, m *operatorsv1.MultiClusterHub) error {
 	ctx := context.Background()
 
+	if m.Unmanaged(operatorsv1.MultiClusterEngine) {
+		log.Info("MultiClusterEngine is unmanaged, skipping MCE finalization")
+		return nil
+	}
+
 	managedByMCE, err := r.ManagedByMCEExists()
 	if err != nil {
 		return err
@@ -165,7 +173,7 @@ func (r *MultiClusterHubReconciler) cleanupMultiClusterEngine(log logr.Logger, m
 			if namespace, ok := labels["installer.namespace"]; ok && namespace == m.GetNamespace() {
 				// MCE is installed by the MCH, no need to manage. Return
 				r.Log.Info("Deleting MultiClusterEngine resources")
-				err := r.Client.Delete(ctx, multiclusterengine.MultiClusterEngine(m))
+				err := r.Client.Delete(ctx, multiclusterengine.MultiClusterEngine(m, nil))
 				if err != nil && (!errors.IsNotFound(err) || !errors.IsGone(err)) {
 					return err
 				}
@@ -230,8 +238,11 @@ func (r *MultiClusterHubReconciler) cleanupMultiClusterEngine(log logr.Logger, m
 
 	return nil
 }
-func (r *MultiClusterHubReconciler) cleanupNamespaces(reqLogger logr.Logger) error {
+func (r *MultiClusterHubReconciler) cleanupNamespaces(reqLogger logr.Logger, m *operatorsv1.MultiClusterHub) error {
 	ctx := context.Background()
+	if m.Unmanaged(operatorsv1.ClusterBackup) {
+		return nil
+	}
 	clusterBackupNamespace := &corev1.Namespace{}
 	err := r.Client.Get(ctx, types.NamespacedName{Name: utils.ClusterSubscriptionNamespace}, clusterBackupNamespace)
 	if err == nil {
@@ -332,8 +343,37 @@ func (r *MultiClusterHubReconciler) cleanupAppSubscriptions(reqLogger logr.Logge
 	return nil
 }
 
+// cleanupReleases deletes the components installed by the Helm install engine
+func (r *MultiClusterHubReconciler) cleanupReleases(reqLogger logr.Logger, m *operatorsv1.MultiClusterHub) error {
+	reqLogger.Info("Deleting releases")
+	if err := r.ensureNoReleases(m); err != nil {
+		reqLogger.Error(err, "Error deleting releases")
+		return err
+	}
+	reqLogger.Info("All releases have been deleted")
+	return nil
+}
+
 func (r *MultiClusterHubReconciler) cleanupFoundation(reqLogger logr.Logger, m *operatorsv1.MultiClusterHub) error {
+	if m.Unmanaged(operatorsv1.Repo) {
+		reqLogger.Info("MultiClusterHub repo is unmanaged, leaving it in place")
+	} else if err := r.cleanupHelmRepo(reqLogger, m); err != nil {
+		return err
+	}
 
+	reqLogger.Info("Deleting MultiClusterHub pod disruption budgets")
+	if err := r.ensureNoPodDisruptionBudgets(m); err != nil {
+		reqLogger.Error(err, "Error deleting MultiClusterHub pod disruption budgets")
+		return err
+	}
+
+	reqLogger.Info("All foundation artefacts have been terminated")
+
+	return nil
+}
+
+// cleanupHelmRepo deletes the helm repo with its service, channel and credentials
+func (r *MultiClusterHubReconciler) cleanupHelmRepo(reqLogger logr.Logger, m *operatorsv1.MultiClusterHub) error {
 	var emptyOverrides map[string]string
 
 	reqLogger.Info("Deleting MultiClusterHub repo deployment")
@@ -350,16 +390,18 @@ func (r *MultiClusterHubReconciler) cleanupFoundation(reqLogger logr.Logger, m *
 		return err
 	}
 
-	reqLogger.Info("Deleting MultiClusterHub channel")
-	err = r.Client.Delete(context.TODO(), channel.Channel(m))
-	if err != nil && !errors.IsNotFound(err) {
-		reqLogger.Error(err, "Error deleting MultiClusterHub channel")
-		return err
+	if m.InstallEngine() != operatorsv1.InstallEngineHelm {
+		reqLogger.Info("Deleting MultiClusterHub channel")
+		err = r.Client.Delete(context.TODO(), channel.Channel(m))
+		if err != nil && !errors.IsNotFound(err) {
+			reqLogger.Error(err, "Error deleting MultiClusterHub channel")
+			return err
+		}
 	}
 
-	reqLogger.Info("All foundation artefacts have been terminated")
-
-	return nil
+	reqLogger.Info("Deleting MultiClusterHub repo credentials")
+	_, err = r.ensureNoHelmRepoCredentials(m)
+	return err
 }
 
 func (r *MultiClusterHubReconciler) orphanOwnedMultiClusterEngine(m *operatorsv1.MultiClusterHub) error {
@@ -384,3 +426,174 @@ func (r *MultiClusterHubReconciler) orphanOwnedMultiClusterEngine(m *operatorsv1
 	r.Log.Info("MCE orphaned")
 	return nil
 }
+
+// orphanUnmanagedComponents detaches unmanaged components from the hub so that the finalizer and garbage
+// collection leave them in place: their appsubs and helmreleases, or the objects recorded for their
+// releases, lose the installer labels and their owner reference to the hub. The same goes for the helm
+// repo and the multicluster-engine, which the finalizer then skips.
+func (r *MultiClusterHubReconciler) orphanUnmanagedComponents(reqLogger logr.Logger, m *operatorsv1.MultiClusterHub) error {
+	for name, a := range r.componentActions() {
+		if !m.Unmanaged(name) {
+			continue
+		}
+		var err error
+		switch {
+		case name == operatorsv1.Repo:
+			err = r.orphanHelmRepo(m)
+		case name == operatorsv1.MultiClusterEngine:
+			err = r.orphanMultiClusterEngine(m)
+		case a.subscription == nil:
+			continue
+		case m.InstallEngine() == operatorsv1.InstallEngineHelm:
+			err = r.orphanRelease(m, a.subscription(m).GetName())
+		default:
+			err = r.orphanAppSubscription(m, a.subscription(m))
+		}
+		if err != nil {
+			reqLogger.Error(err, "Error orphaning unmanaged component", "Component", name)
+			return err
+		}
+		reqLogger.Info("Left unmanaged component in place", "Component", name)
+	}
+	return nil
+}
+
+// orphanHelmRepo detaches the helm repo, its service, credentials and channel from the hub
+func (r *MultiClusterHubReconciler) orphanHelmRepo(m *operatorsv1.MultiClusterHub) error {
+	objects := append([]client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: helmrepo.HelmRepoName, Namespace: m.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: helmrepo.HelmRepoName, Namespace: m.Namespace}},
+	}, helmRepoCredentials(m)...)
+	if m.InstallEngine() != operatorsv1.InstallEngineHelm {
+		ch := channel.Channel(m)
+		bare := &unstructured.Unstructured{}
+		bare.SetGroupVersionKind(ch.GroupVersionKind())
+		bare.SetName(ch.GetName())
+		bare.SetNamespace(ch.GetNamespace())
+		objects = append(objects, bare)
+	}
+	for _, obj := range objects {
+		err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj)
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := r.orphanObject(m, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orphanMultiClusterEngine detaches the multicluster-engine installed by the hub from it
+func (r *MultiClusterHubReconciler) orphanMultiClusterEngine(m *operatorsv1.MultiClusterHub) error {
+	mce := &mcev1.MultiClusterEngine{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: multiclusterengine.MulticlusterengineName}, mce)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if mce.Labels["installer.name"] != m.GetName() || mce.Labels["installer.namespace"] != m.GetNamespace() {
+		return nil
+	}
+	return r.orphanObject(m, mce)
+}
+
+// orphanAppSubscription detaches the appsub of a component and the helmrelease it created from the hub
+func (r *MultiClusterHubReconciler) orphanAppSubscription(m *operatorsv1.MultiClusterHub, sub *unstructured.Unstructured) error {
+	appsub := &unstructured.Unstructured{}
+	appsub.SetGroupVersionKind(sub.GroupVersionKind())
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: sub.GetName(), Namespace: sub.GetNamespace()}, appsub)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := r.orphanObject(m, appsub); err != nil {
+		return err
+	}
+
+	helmRelease := &unstructured.Unstructured{}
+	helmRelease.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "apps.open-cluster-management.io",
+		Kind:    "HelmRelease",
+		Version: "v1",
+	})
+	helmReleaseName := fmt.Sprintf("%s-%s", strings.Replace(appsub.GetName(), "-sub", "", 1), appsub.GetUID()[0:5])
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: helmReleaseName, Namespace: appsub.GetNamespace()}, helmRelease)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return r.orphanObject(m, helmRelease)
+}
+
+// orphanRelease detaches the objects and CRDs applied for a release from the hub, and unlabels its record so
+// that the release is not deleted with the others. Objects are found from the record and CRDs from their
+// release label, so the helm repo is not needed.
+func (r *MultiClusterHubReconciler) orphanRelease(m *operatorsv1.MultiClusterHub, release string) error {
+	record := &corev1.ConfigMap{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rendering.RecordName(release), Namespace: m.Namespace}, record)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	crds := &unstructured.UnstructuredList{}
+	crds.SetGroupVersionKind(apixv1.SchemeGroupVersion.WithKind("CustomResourceDefinitionList"))
+	err = r.Client.List(context.TODO(), crds, client.MatchingLabels{
+		rendering.ReleaseLabel: release,
+		"installer.name":       m.GetName(),
+		"installer.namespace":  m.GetNamespace(),
+	})
+	if err != nil {
+		return err
+	}
+	for i := range crds.Items {
+		if err := r.orphanObject(m, &crds.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	refs, err := rendering.RecordedObjects(record)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		obj := ref.Object()
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, obj)
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := r.orphanObject(m, obj); err != nil {
+			return err
+		}
+	}
+
+	delete(record.Labels, "installer.name")
+	delete(record.Labels, "installer.namespace")
+	return r.Client.Update(context.TODO(), record)
+}
+
+// orphanObject removes the installer labels and the owner reference to the hub from an object
+func (r *MultiClusterHubReconciler) orphanObject(m *operatorsv1.MultiClusterHub, obj client.Object) error {
+	labels := obj.GetLabels()
+	delete(labels, "installer.name")
+	delete(labels, "installer.namespace")
+	obj.SetLabels(labels)
+
+	owners := []metav1.OwnerReference{}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != m.GetUID() {
+			owners = append(owners, ref)
+		}
+	}
+	obj.SetOwnerReferences(owners)
+	return r.Client.Update(context.TODO(), obj)
+}
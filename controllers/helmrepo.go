@@ -0,0 +1,210 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// helmRepoDeployment returns the helm repo deployment stamped with the hash of its serving certificate,
+// so that a rotated certificate rolls the pods
+func (r *MultiClusterHubReconciler) helmRepoDeployment(m *operatorv1.MultiClusterHub) (*appsv1.Deployment, error) {
+	dep := helmrepo.Deployment(m, r.CacheSpec.ImageOverrides)
+	helmrepo.SetChartVersion(dep, releaseVersion(m))
+
+	secret := &corev1.Secret{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: helmrepo.TLSSecretName(m), Namespace: m.Namespace}, secret)
+	if errors.IsNotFound(err) {
+		// The pods start once the certificate is issued, which triggers another reconcile
+		return dep, nil
+	} else if err != nil {
+		return nil, err
+	}
+	helmrepo.SetCertificateHash(dep, helmrepo.CertificateHash(secret))
+	return dep, nil
+}
+
+// checkHelmRepoImage holds back the helm repo when the image manifest records its image at a version that
+// cannot serve charts over TLS with basic auth. An image that is not the one of the manifest is not checked.
+func (r *MultiClusterHubReconciler) checkHelmRepoImage() {
+	r.CacheSpec.IncompatibleImages = nil
+	ref := helmrepo.Image(r.CacheSpec.ImageOverrides)
+	for _, mi := range r.manifestImages() {
+		if mi.ImageKey != helmrepo.ImageKey || !manifestReference(mi, ref) || helmrepo.ImageSupportsTLS(mi.ImageVersion) {
+			continue
+		}
+		r.CacheSpec.IncompatibleImages = map[string]string{operatorv1.Repo: fmt.Sprintf(
+			"%s (%s) is version %s, which does not serve charts over TLS with basic auth; version %s or later is required",
+			helmrepo.ImageKey, ref, mi.ImageVersion, helmrepo.MinimumTLSImageVersion)}
+	}
+}
+
+// manifestReference returns true if the reference, wherever it is pulled from, is the image of the manifest
+func manifestReference(mi manifest.ManifestImage, ref string) bool {
+	if mi.ImageDigest != "" {
+		return strings.HasSuffix(ref, "@"+mi.ImageDigest)
+	}
+	return strings.HasSuffix(ref, "/"+mi.ImageName+":"+mi.ImageTag)
+}
+
+// ensureHelmRepoAuth creates the helm repo credentials. Existing credentials are kept.
+func (r *MultiClusterHubReconciler) ensureHelmRepoAuth(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+	found := &corev1.Secret{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: helmrepo.AuthSecretName, Namespace: m.Namespace}, found)
+	if err == nil {
+		return ctrl.Result{}, nil
+	} else if !errors.IsNotFound(err) {
+		r.Log.Error(err, "Failed to get helm repo credentials")
+		return ctrl.Result{}, err
+	}
+
+	secret, err := helmrepo.AuthSecret(m)
+	if err != nil {
+		r.Log.Error(err, "Failed to generate helm repo credentials")
+		return ctrl.Result{}, err
+	}
+	if err := r.Client.Create(context.TODO(), secret); err != nil {
+		r.Log.Error(err, "Failed to create helm repo credentials")
+		return ctrl.Result{}, err
+	}
+	r.Log.Info("Created helm repo credentials")
+	return ctrl.Result{}, nil
+}
+
+// ensureHelmRepoCA copies the CA that signed the helm repo certificate into the configmap referenced by the
+// channel. The CA is read from the OpenShift service CA bundle or from the ca.crt key of a self-managed
+// certificate secret.
+func (r *MultiClusterHubReconciler) ensureHelmRepoCA(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+	ca, err := r.helmRepoCA(m)
+	if err != nil {
+		r.Log.Error(err, "Failed to get helm repo CA")
+		return ctrl.Result{}, err
+	}
+	if ca == "" {
+		r.Log.Info("Waiting for the helm repo CA", "Secret", helmrepo.TLSSecretName(m))
+		return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+	}
+
+	desired := helmrepo.CAConfigMap(m, ca)
+	found := &corev1.ConfigMap{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, found)
+	if errors.IsNotFound(err) {
+		if err := r.Client.Create(context.TODO(), desired); err != nil {
+			r.Log.Error(err, "Failed to create helm repo CA configmap")
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Created helm repo CA configmap")
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		r.Log.Error(err, "Failed to get helm repo CA configmap")
+		return ctrl.Result{}, err
+	}
+
+	if found.Data[helmrepo.CAKey] == ca {
+		return ctrl.Result{}, nil
+	}
+	found.Data = desired.Data
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update helm repo CA configmap")
+		return ctrl.Result{}, err
+	}
+	r.Log.Info("Updated helm repo CA configmap")
+	return ctrl.Result{}, nil
+}
+
+// helmRepoCA returns the CA bundle trusted for the helm repo, or an empty string while it is not yet issued
+func (r *MultiClusterHubReconciler) helmRepoCA(m *operatorv1.MultiClusterHub) (string, error) {
+	if name := utils.GetHelmRepoTLSSecret(m); name != "" {
+		secret := &corev1.Secret{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: m.Namespace}, secret)
+		if errors.IsNotFound(err) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		if len(secret.Data[helmrepo.SecretCAKey]) == 0 {
+			return "", fmt.Errorf("helm repo certificate secret %s has no %s key", name, helmrepo.SecretCAKey)
+		}
+		return string(secret.Data[helmrepo.SecretCAKey]), nil
+	}
+
+	desired := helmrepo.ServiceCAConfigMap(m)
+	found := &corev1.ConfigMap{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, found)
+	if errors.IsNotFound(err) {
+		if err := r.Client.Create(context.TODO(), desired); err != nil {
+			return "", err
+		}
+		r.Log.Info("Created helm repo service CA configmap")
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return found.Data[helmrepo.ServiceCAKey], nil
+}
+
+// ensureNoHelmRepoCredentials removes the helm repo credentials and CA configmaps
+func (r *MultiClusterHubReconciler) ensureNoHelmRepoCredentials(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
+	for _, obj := range helmRepoCredentials(m) {
+		if err := r.Client.Delete(context.TODO(), obj); err != nil && !errors.IsNotFound(err) {
+			r.Log.Error(err, "Failed to delete helm repo credentials", "Name", obj.GetName())
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// helmRepoCredentials lists the objects the operator creates to secure the helm repo
+func helmRepoCredentials(m *operatorv1.MultiClusterHub) []client.Object {
+	return []client.Object{
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: helmrepo.AuthSecretName, Namespace: m.Namespace}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: helmrepo.CAConfigMapName, Namespace: m.Namespace}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: helmrepo.ServiceCAConfigMapName, Namespace: m.Namespace}},
+	}
+}
+
+// helmRepoTLSSecretField indexes multiclusterhubs by the secret holding their helm repo serving certificate
+const helmRepoTLSSecretField = "helmRepoTLSSecret"
+
+// helmRepoTLSSecretIndex returns the value of helmRepoTLSSecretField for a multiclusterhub
+func helmRepoTLSSecretIndex(o client.Object) []string {
+	m, ok := o.(*operatorv1.MultiClusterHub)
+	if !ok {
+		return nil
+	}
+	return []string{helmrepo.TLSSecretName(m)}
+}
+
+// helmRepoSecretRequests maps a change of the helm repo serving certificate to the multiclusterhub using it.
+// Secrets are looked up in the index by namespace and name, so other secrets do not list multiclusterhubs.
+func (r *MultiClusterHubReconciler) helmRepoSecretRequests(a client.Object) []reconcile.Request {
+	multiClusterHubList := &operatorv1.MultiClusterHubList{}
+	if err := r.Client.List(context.TODO(), multiClusterHubList, client.InNamespace(a.GetNamespace()),
+		client.MatchingFields{helmRepoTLSSecretField: a.GetName()}); err != nil {
+		return []reconcile.Request{}
+	}
+	requests := []reconcile.Request{}
+	for i := range multiClusterHubList.Items {
+		mch := &multiClusterHubList.Items[i]
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Name:      mch.GetName(),
+			Namespace: mch.GetNamespace(),
+		}})
+	}
+	return requests
+}
@@ -0,0 +1,198 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_ensureHelmRepoCA(t *testing.T) {
+	mch := &operatorv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"}}
+	r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(), Log: zap.New()}
+	key := types.NamespacedName{Name: helmrepo.CAConfigMapName, Namespace: "test"}
+
+	// The service CA configmap is created and the bundle awaited
+	if result, err := r.ensureHelmRepoCA(mch); err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("ensureHelmRepoCA() = %v, %v, want a requeue while the CA is not injected", result, err)
+	}
+	serviceCA := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: helmrepo.ServiceCAConfigMapName, Namespace: "test"}, serviceCA); err != nil {
+		t.Fatalf("service CA configmap was not created: %v", err)
+	}
+
+	// The injected bundle is copied for the channel, and copied again when it rotates
+	for _, ca := range []string{"first-ca", "rotated-ca"} {
+		serviceCA.Data = map[string]string{helmrepo.ServiceCAKey: ca}
+		if err := r.Client.Update(context.TODO(), serviceCA); err != nil {
+			t.Fatal(err)
+		}
+		if result, err := r.ensureHelmRepoCA(mch); err != nil || result != (ctrl.Result{}) {
+			t.Fatalf("ensureHelmRepoCA() = %v, %v", result, err)
+		}
+		cm := &corev1.ConfigMap{}
+		if err := r.Client.Get(context.TODO(), key, cm); err != nil || cm.Data[helmrepo.CAKey] != ca {
+			t.Errorf("channel CA configmap = %v, %v, want %s", cm.Data, err, ca)
+		}
+	}
+
+	// A self-managed certificate brings its own CA
+	mch.SetAnnotations(map[string]string{utils.AnnotationHelmRepoTLSSecret: "custom-tls"})
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-tls", Namespace: "test"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key")},
+	}
+	if err := r.Client.Create(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ensureHelmRepoCA(mch); err == nil {
+		t.Errorf("ensureHelmRepoCA() accepted a certificate secret without %s", helmrepo.SecretCAKey)
+	}
+	secret.Data[helmrepo.SecretCAKey] = []byte("custom-ca")
+	if err := r.Client.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ensureHelmRepoCA(mch); err != nil {
+		t.Fatalf("ensureHelmRepoCA() error = %v", err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), key, cm); err != nil || cm.Data[helmrepo.CAKey] != "custom-ca" {
+		t.Errorf("channel CA configmap = %v, %v, want custom-ca", cm.Data, err)
+	}
+}
+
+func Test_helmRepoDeployment(t *testing.T) {
+	mch := &operatorv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"}}
+	r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(), Log: zap.New()}
+
+	dep, err := r.helmRepoDeployment(mch)
+	if err != nil {
+		t.Fatalf("helmRepoDeployment() error = %v", err)
+	}
+	if hash := dep.Spec.Template.Annotations[helmrepo.AnnotationCertificateHash]; hash != "" {
+		t.Errorf("helmRepoDeployment() set certificate hash %s before the certificate was issued", hash)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: helmrepo.ServingCertSecretName, Namespace: "test"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key")},
+	}
+	if err := r.Client.Create(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	dep, _ = r.helmRepoDeployment(mch)
+	first := dep.Spec.Template.Annotations[helmrepo.AnnotationCertificateHash]
+	if first == "" {
+		t.Fatalf("helmRepoDeployment() did not set the certificate hash")
+	}
+
+	secret.Data[corev1.TLSCertKey] = []byte("rotated-cert")
+	if err := r.Client.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	dep, _ = r.helmRepoDeployment(mch)
+	if dep.Spec.Template.Annotations[helmrepo.AnnotationCertificateHash] == first {
+		t.Errorf("helmRepoDeployment() certificate hash did not change with the certificate")
+	}
+}
+
+func Test_ensureHelmRepoAuth(t *testing.T) {
+	mch := &operatorv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"}}
+	r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(), Log: zap.New()}
+	key := types.NamespacedName{Name: helmrepo.AuthSecretName, Namespace: "test"}
+
+	if _, err := r.ensureHelmRepoAuth(mch); err != nil {
+		t.Fatalf("ensureHelmRepoAuth() error = %v", err)
+	}
+	first := &corev1.Secret{}
+	if err := r.Client.Get(context.TODO(), key, first); err != nil {
+		t.Fatalf("credentials were not created: %v", err)
+	}
+
+	if _, err := r.ensureHelmRepoAuth(mch); err != nil {
+		t.Fatalf("ensureHelmRepoAuth() error = %v", err)
+	}
+	if user := string(first.Data["user"]); user != helmrepo.HelmRepoName || len(first.Data["password"]) == 0 {
+		t.Fatalf("credentials data = %v, want user %s and a password", first.Data, helmrepo.HelmRepoName)
+	}
+	second := &corev1.Secret{}
+	if err := r.Client.Get(context.TODO(), key, second); err != nil || string(second.Data["password"]) != string(first.Data["password"]) {
+		t.Errorf("ensureHelmRepoAuth() replaced existing credentials")
+	}
+}
+
+func Test_ensureService_servingCertAnnotation(t *testing.T) {
+	mch := &operatorv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"}}
+	r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(), Log: zap.New()}
+	key := types.NamespacedName{Name: helmrepo.HelmRepoName, Namespace: "test"}
+
+	if _, err := r.ensureService(mch, helmrepo.Service(mch)); err != nil {
+		t.Fatalf("ensureService() error = %v", err)
+	}
+	found := &corev1.Service{}
+	if err := r.Client.Get(context.TODO(), key, found); err != nil || found.Annotations[helmrepo.AnnotationServingCertSecret] == "" {
+		t.Fatalf("service = %v, %v, want the service CA annotation", found.Annotations, err)
+	}
+
+	// A certificate of the user's own replaces the one issued by the service CA
+	mch.SetAnnotations(map[string]string{utils.AnnotationHelmRepoTLSSecret: "custom-tls"})
+	if _, err := r.ensureService(mch, helmrepo.Service(mch)); err != nil {
+		t.Fatalf("ensureService() error = %v", err)
+	}
+	found = &corev1.Service{}
+	if err := r.Client.Get(context.TODO(), key, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := found.Annotations[helmrepo.AnnotationServingCertSecret]; ok {
+		t.Errorf("ensureService() kept the service CA annotation with a self-managed certificate")
+	}
+}
+
+func Test_checkHelmRepoImage(t *testing.T) {
+	images := []manifest.ManifestImage{{ImageKey: helmrepo.ImageKey, ImageName: "multiclusterhub-repo", ImageVersion: "2.4", ImageDigest: "sha256:abc"}}
+	tests := []struct {
+		name     string
+		ref      string
+		version  string
+		wantHeld bool
+	}{
+		{name: "Manifest image without TLS", ref: "mirror.example.com/acm/multiclusterhub-repo@sha256:abc", version: "2.4", wantHeld: true},
+		{name: "Manifest image with TLS", ref: "quay.io/stolostron/multiclusterhub-repo@sha256:abc", version: helmrepo.MinimumTLSImageVersion},
+		{name: "Overridden image", ref: "quay.io/dev/multiclusterhub-repo@sha256:def", version: "2.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images[0].ImageVersion = tt.version
+			r := &MultiClusterHubReconciler{Log: zap.New(), CacheSpec: CacheSpec{
+				ImageOverrides: map[string]string{helmrepo.ImageKey: tt.ref},
+				ManifestImages: images,
+			}}
+			r.checkHelmRepoImage()
+			if _, held := r.CacheSpec.IncompatibleImages[operatorv1.Repo]; held != tt.wantHeld {
+				t.Errorf("checkHelmRepoImage() held = %v, want %v: %v", held, tt.wantHeld, r.CacheSpec.IncompatibleImages)
+			}
+		})
+	}
+}
+
+func Test_helmRepoTLSSecretIndex(t *testing.T) {
+	mch := &operatorv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"}}
+	if got := helmRepoTLSSecretIndex(mch); len(got) != 1 || got[0] != helmrepo.ServingCertSecretName {
+		t.Errorf("helmRepoTLSSecretIndex() = %v, want %s", got, helmrepo.ServingCertSecretName)
+	}
+	mch.SetAnnotations(map[string]string{utils.AnnotationHelmRepoTLSSecret: "custom-tls"})
+	if got := helmRepoTLSSecretIndex(mch); len(got) != 1 || got[0] != "custom-tls" {
+		t.Errorf("helmRepoTLSSecretIndex() = %v, want custom-tls", got)
+	}
+}
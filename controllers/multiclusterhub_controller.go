@@ -22,16 +22,16 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
-	"github.com/stolostron/multiclusterhub-operator/pkg/channel"
 	"github.com/stolostron/multiclusterhub-operator/pkg/deploying"
-	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
 	"github.com/stolostron/multiclusterhub-operator/pkg/imageoverrides"
 	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
 	"github.com/stolostron/multiclusterhub-operator/pkg/predicate"
@@ -40,6 +40,7 @@ import (
 	"github.com/stolostron/multiclusterhub-operator/pkg/version"
 	"sigs.k8s.io/yaml"
 
+	subhelmv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
 	appsubv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 
 	configv1 "github.com/openshift/api/config/v1"
@@ -50,6 +51,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/util/workqueue"
 	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -71,6 +73,17 @@ type MultiClusterHubReconciler struct {
 	CacheSpec CacheSpec
 	Scheme    *runtime.Scheme
 	Log       logr.Logger
+	// VerificationPolicy is checked by image references before they are rolled out. Nil accepts all references.
+	VerificationPolicy *manifest.VerificationPolicy
+	// RegistryClient sends the image preflight requests. http.DefaultClient is used when nil.
+	RegistryClient *http.Client
+	// HelmRepoClient fetches the helm repo index. When nil, a client trusting the helm repo CA is used.
+	HelmRepoClient *http.Client
+	// HelmRepoURL overrides the in-cluster address of the helm repo
+	HelmRepoURL string
+	// Discovery reads the server version and served APIs that charts are rendered against by the Helm
+	// install engine
+	Discovery discovery.DiscoveryInterface
 }
 
 var resyncPeriod = time.Second * 20
@@ -95,9 +108,15 @@ const (
 //+kubebuilder:rbac:groups=console.openshift.io,resources=consoleplugins,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=operator.openshift.io,resources=consoles,verbs=get;list;watch;update;patch
 
+// PodDisruptionBudgets of highly available components
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;delete
+
 // AgentServiceConfig webhook delete check
 //+kubebuilder:rbac:groups=agent-install.openshift.io,resources=agentserviceconfigs,verbs=get;list;watch
 
+// Component disable checks in the webhook
+//+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=backupschedules;restores,verbs=get;list;watch
+
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 // TODO(user): Modify the Reconcile function to compare the state specified by
@@ -135,9 +154,13 @@ func (r *MultiClusterHubReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
-	allHRs, err := r.listHelmReleases(trackedNamespaces)
-	if err != nil {
-		return ctrl.Result{}, err
+	// The Helm install engine creates no helmreleases
+	var allHRs []*subhelmv1.HelmRelease
+	if multiClusterHub.InstallEngine() != operatorv1.InstallEngineHelm {
+		allHRs, err = r.listHelmReleases(trackedNamespaces)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
 	allCRs, err := r.listCustomResources(multiClusterHub)
@@ -196,6 +219,9 @@ func (r *MultiClusterHubReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		}
 	}
 
+	// Deprecated fields are reported before setDefaults migrates them to their replacement
+	updateDeprecationCondition(multiClusterHub)
+
 	var result ctrl.Result
 	result, err = r.setDefaults(multiClusterHub)
 	if result != (ctrl.Result{}) {
@@ -205,17 +231,39 @@ func (r *MultiClusterHubReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{Requeue: true}, err
 	}
 
-	// Read image overrides
+	// Read image overrides, recording the layer each image reference comes from
+	provenance := imageoverrides.NewProvenance()
 	// First, attempt to read image overrides from environmental variables
-	imageOverrides := imageoverrides.GetImageOverrides()
+	imageOverrides, envPrefix := imageoverrides.GetImageOverridesWithPrefix()
 	if len(imageOverrides) == 0 {
-		// If imageoverrides are not set from environmental variables, read from manifest
+		// If imageoverrides are not set from environmental variables, read from manifest. Registry
+		// rewrites are applied below so they can be told apart from the manifest content.
 		r.Log.Info("Image Overrides not set from environment variables. Checking for overrides in manifest")
-		imageOverrides, err = manifest.GetImageOverrides(multiClusterHub)
-		if err != nil {
+		manifestImages, err := manifest.GetVerifiedManifestImages(r.VerificationPolicy)
+		if err == nil {
+			imageOverrides, err = manifest.VerifiedImageOverrides(withoutImageRewrites(multiClusterHub), manifestImages, r.VerificationPolicy)
+		}
+		if manifest.IsVerificationError(err) {
+			return imageVerificationFailed(multiClusterHub, err)
+		} else if manifest.IsManifestError(err) {
+			return invalidImageManifest(multiClusterHub, err)
+		} else if err != nil {
 			r.Log.Error(err, "Could not get map of image overrides")
 			return ctrl.Result{}, err
 		}
+		r.CacheSpec.ManifestImages = manifestImages
+		provenance.Set(imageOverrides, imageoverrides.SourceManifest)
+	} else {
+		provenance.Set(imageOverrides, imageoverrides.SourceEnv(envPrefix))
+	}
+
+	if mirrors := utils.GetRegistryMirrors(multiClusterHub); len(mirrors) > 0 {
+		imageOverrides = utils.MirrorImageReferences(imageOverrides, mirrors)
+		provenance.Rewrite(imageOverrides, imageoverrides.SourceRegistryMirrors)
+	} else if imageRepo := utils.GetImageRepository(multiClusterHub); imageRepo != "" {
+		r.Log.Info(fmt.Sprintf("Overriding Image Repository from annotation 'mch-imageRepository': %s", imageRepo))
+		imageOverrides = utils.OverrideImageRepository(imageOverrides, imageRepo)
+		provenance.Rewrite(imageOverrides, imageoverrides.SourceImageRepository)
 	}
 
 	// Select oauth proxy image to use. If OCP 4.8 use old version. If OCP 4.9+ use new version. Set with key oauth_proxy
@@ -224,21 +272,43 @@ func (r *MultiClusterHubReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	if err != nil {
 		return ctrl.Result{}, err
 	}
-
-	if imageRepo := utils.GetImageRepository(multiClusterHub); imageRepo != "" {
-		r.Log.Info(fmt.Sprintf("Overriding Image Repository from annotation 'mch-imageRepository': %s", imageRepo))
-		imageOverrides = utils.OverrideImageRepository(imageOverrides, imageRepo)
-	}
-
-	// Check for developer overrides
-	if imageOverridesConfigmap := utils.GetImageOverridesConfigmap(multiClusterHub); imageOverridesConfigmap != "" {
-		imageOverrides, err = r.OverrideImagesFromConfigmap(imageOverrides, multiClusterHub.GetNamespace(), imageOverridesConfigmap)
-		if err != nil {
-			r.Log.Error(err, fmt.Sprintf("Could not find image override configmap: %s/%s", multiClusterHub.GetNamespace(), imageOverridesConfigmap))
+	provenance.Set(imageOverrides, imageoverrides.SourceOauthProxy)
+
+	// Check for developer overrides. Configmaps are applied in the listed order so later ones win.
+	overrideIssues := []string{}
+	for _, imageOverridesConfigmap := range utils.GetImageOverridesConfigmaps(multiClusterHub) {
+		var issues []string
+		imageOverrides, issues, err = r.OverrideImagesFromConfigmap(imageOverrides, multiClusterHub.GetNamespace(), imageOverridesConfigmap,
+			utils.GetRegistryMirrors(multiClusterHub))
+		if manifest.IsVerificationError(err) {
+			return imageVerificationFailed(multiClusterHub, err)
+		} else if err != nil {
+			r.Log.Error(err, fmt.Sprintf("Could not read image override configmap: %s/%s", multiClusterHub.GetNamespace(), imageOverridesConfigmap))
 			return ctrl.Result{}, err
 		}
+		overrideIssues = append(overrideIssues, issues...)
+		provenance.Override(imageOverrides, imageoverrides.SourceConfigmap(multiClusterHub.GetNamespace(), imageOverridesConfigmap))
+	}
+	updateImageOverridesCondition(multiClusterHub, overrideIssues, provenance)
+	if err := r.VerificationPolicy.CheckDigests(imageOverrides); err != nil {
+		return imageVerificationFailed(multiClusterHub, err)
+	}
+	r.checkEngineDigests(multiClusterHub, multiClusterEngineImages(allCRs))
+	imageOverrides, nonCompliant, err := r.enforceDigestPolicy(ctx, multiClusterHub, imageOverrides)
+	if err != nil {
+		r.Log.Error(err, "Could not apply the image digest policy")
+		return ctrl.Result{}, err
+	}
+	if len(nonCompliant) > 0 {
+		return mutableImageReferences(multiClusterHub, nonCompliant)
+	}
+	provenance.Rewrite(imageOverrides, imageoverrides.SourceDigestResolution)
+	if c := GetHubCondition(multiClusterHub.Status, operatorv1.Blocked); c != nil &&
+		(c.Reason == ImageVerificationFailedReason || c.Reason == MutableImageReferencesReason || c.Reason == InvalidImageManifestReason) {
+		RemoveHubCondition(&multiClusterHub.Status, operatorv1.Blocked)
 	}
 	r.CacheSpec.ImageOverrides = imageOverrides
+	r.CacheSpec.ImageProvenance = provenance
 	r.CacheSpec.ManifestVersion = version.Version
 	r.CacheSpec.ImageRepository = utils.GetImageRepository(multiClusterHub)
 	r.CacheSpec.ImageOverridesCM = utils.GetImageOverridesConfigmap(multiClusterHub)
@@ -249,6 +319,18 @@ func (r *MultiClusterHubReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
+	err = r.maintainSBOMConfigmap(multiClusterHub)
+	if err != nil {
+		r.Log.Error(err, "Error storing bill of materials in configmap")
+		return ctrl.Result{}, err
+	}
+
+	// Keep components on the previous release when an upgrade is rolled back
+	if err := r.ensureUpgradeRollback(ctx, multiClusterHub); err != nil {
+		r.Log.Error(err, "Error rolling back upgrade")
+		return ctrl.Result{}, err
+	}
+
 	CustomUpgradeRequired, err := r.CustomSelfMgmtHubUpgradeRequired(multiClusterHub)
 	if err != nil {
 		r.Log.Error(err, "Error determining if upgrade specific logic is required")
@@ -266,6 +348,9 @@ func (r *MultiClusterHubReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	// Add installer labels to Helm-owned deployments
 	myHelmReleases := getAppSubOwnedHelmReleases(allHRs, utils.GetAppsubs(multiClusterHub))
 	myHRDeployments := getHelmReleaseOwnedDeployments(allDeploys, myHelmReleases)
+	if multiClusterHub.InstallEngine() == operatorv1.InstallEngineHelm {
+		myHRDeployments = getReleaseDeployments(allDeploys, utils.GetAppsubs(multiClusterHub))
+	}
 	if err := r.labelDeployments(multiClusterHub, myHRDeployments); err != nil {
 		return ctrl.Result{}, nil
 	}
@@ -329,143 +414,38 @@ func (r *MultiClusterHubReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return result, fmt.Errorf("failed to find pullsecret: %s", err)
 	}
 
-	if multiClusterHub.Enabled(operatorv1.Repo) {
-		result, err = r.ensureDeployment(multiClusterHub, helmrepo.Deployment(multiClusterHub, r.CacheSpec.ImageOverrides))
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-
-		result, err = r.ensureService(multiClusterHub, helmrepo.Service(multiClusterHub))
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-
-		result, err = r.ensureChannel(multiClusterHub, channel.Channel(multiClusterHub))
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-	} else {
-		result, err = r.ensureNoDeployment(multiClusterHub, helmrepo.Deployment(multiClusterHub, r.CacheSpec.ImageOverrides))
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-
-		result, err = r.ensureNoService(multiClusterHub, helmrepo.Service(multiClusterHub))
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-
-		result, err = r.ensureNoUnstructured(multiClusterHub, channel.Channel(multiClusterHub))
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-	}
-
-	result, err = r.ensureMultiClusterEngine(multiClusterHub)
+	result, err = r.ingressDomain(multiClusterHub)
 	if result != (ctrl.Result{}) {
 		return result, err
 	}
 
-	result, err = r.ingressDomain(multiClusterHub)
-	if result != (ctrl.Result{}) {
-		return result, err
+	// Hold back components whose images are missing from their registry
+	if err := r.imagePreflight(ctx, multiClusterHub); err != nil {
+		r.Log.Error(err, "Image preflight failed")
+		return ctrl.Result{}, err
 	}
 
-	// Install CRDs
-	reason, err = r.deployResources(r.Log, multiClusterHub)
+	// Hold back the helm repo if its image cannot serve charts as the operator configures it
+	r.checkHelmRepoImage()
+
+	// Hold back components whose charts the helm repo does not serve as released, and those whose charts
+	// cannot be verified yet
+	chartsResult, err := r.verifyCharts(ctx, multiClusterHub)
 	if err != nil {
-		condition := NewHubCondition(
-			operatorv1.Progressing,
-			metav1.ConditionFalse,
-			reason,
-			fmt.Sprintf("Error deploying resources: %s", err),
-		)
-		SetHubCondition(&multiClusterHub.Status, *condition)
+		r.Log.Error(err, "Chart verification failed")
 		return ctrl.Result{}, err
 	}
 
-	// Install the rest of the subscriptions in no particular order
-	if multiClusterHub.Enabled(operatorv1.ManagementIngress) {
-		result, err = r.ensureSubscription(multiClusterHub, subscription.ManagementIngress(multiClusterHub, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain))
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.ManagementIngress(multiClusterHub, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain))
-	}
-	if result != (ctrl.Result{}) {
-		return result, err
-	}
-	if multiClusterHub.Enabled(operatorv1.Console) {
-		result, err = r.ensureSubscription(multiClusterHub, subscription.Console(multiClusterHub, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain))
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.Console(multiClusterHub, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain))
-	}
-	if result != (ctrl.Result{}) {
-		return result, err
-	}
-	if multiClusterHub.Enabled(operatorv1.Insights) {
-		result, err = r.ensureSubscription(multiClusterHub, subscription.Insights(multiClusterHub, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain))
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.Insights(multiClusterHub, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain))
-	}
+	// Install and remove components in dependency order. The hub resources are deployed once the
+	// helm repo and the multicluster-engine are reconciled.
+	result, err = r.reconcileComponents(multiClusterHub)
 	if result != (ctrl.Result{}) {
 		return result, err
 	}
-	if multiClusterHub.Enabled(operatorv1.GRC) {
-		result, err = r.ensureSubscription(multiClusterHub, subscription.GRC(multiClusterHub, r.CacheSpec.ImageOverrides))
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.GRC(multiClusterHub, r.CacheSpec.ImageOverrides))
-	}
-	if result != (ctrl.Result{}) {
-		return result, err
-	}
-	if multiClusterHub.Enabled(operatorv1.ClusterLifecycle) {
-		result, err = r.ensureSubscription(multiClusterHub, subscription.ClusterLifecycle(multiClusterHub, r.CacheSpec.ImageOverrides))
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.ClusterLifecycle(multiClusterHub, r.CacheSpec.ImageOverrides))
-	}
-	if result != (ctrl.Result{}) {
-		return result, err
-	}
-	if multiClusterHub.Enabled(operatorv1.Volsync) {
-		result, err = r.ensureSubscription(multiClusterHub, subscription.Volsync(multiClusterHub, r.CacheSpec.ImageOverrides))
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.Volsync(multiClusterHub, r.CacheSpec.ImageOverrides))
-	}
-	if result != (ctrl.Result{}) {
-		return result, err
-	}
-	if multiClusterHub.Enabled(operatorv1.Search) {
-		result, err = r.ensureSubscription(multiClusterHub, subscription.Search(multiClusterHub, r.CacheSpec.ImageOverrides))
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.Search(multiClusterHub, r.CacheSpec.ImageOverrides))
-	}
-	if result != (ctrl.Result{}) {
-		return result, err
-	}
-	if multiClusterHub.Enabled(operatorv1.ClusterBackup) {
-		result, err = r.ensureNamespace(multiClusterHub, subscription.Namespace())
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-		result, err = r.ensureSubscription(multiClusterHub, subscription.ClusterBackup(multiClusterHub, r.CacheSpec.ImageOverrides))
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.ClusterBackup(multiClusterHub, r.CacheSpec.ImageOverrides))
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-		result, err = r.ensureNoNamespace(multiClusterHub, subscription.NamespaceUnstructured())
-		if result != (ctrl.Result{}) {
-			return result, err
-		}
-	}
-	if multiClusterHub.Enabled(operatorv1.ClusterProxyAddon) {
-		result, err = r.ensureSubscription(multiClusterHub, subscription.ClusterProxyAddon(multiClusterHub, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain))
-	} else {
-		result, err = r.ensureNoSubscription(multiClusterHub, subscription.ClusterProxyAddon(multiClusterHub, r.CacheSpec.ImageOverrides, r.CacheSpec.IngressDomain))
-	}
-	if result != (ctrl.Result{}) {
+
+	// Keep highly available components serving through node drains
+	result, err = r.ensurePodDisruptionBudgets(multiClusterHub, myHRDeployments)
+	if result != (ctrl.Result{}) || err != nil {
 		return result, err
 	}
 
@@ -491,18 +471,28 @@ func (r *MultiClusterHubReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 				return result, err
 			}
 		}
-		result, err = r.ensureRemovalsGone(multiClusterHub)
-		if result != (ctrl.Result{}) {
-			return result, err
+		// Resources the upgrade removes are still needed by the release an upgrade was rolled back to
+		if !rolledBack(multiClusterHub) {
+			result, err = r.ensureRemovalsGone(multiClusterHub)
+			if result != (ctrl.Result{}) {
+				return result, err
+			}
 		}
 	}
 
+	if chartsResult != (ctrl.Result{}) {
+		return chartsResult, nil
+	}
 	return retQueue, retError
 	// return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *MultiClusterHubReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &operatorv1.MultiClusterHub{}, helmRepoTLSSecretField,
+		helmRepoTLSSecretIndex); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&operatorv1.MultiClusterHub{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Watches(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestForOwner{
@@ -517,6 +507,10 @@ func (r *MultiClusterHubReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			IsController: true,
 			OwnerType:    &operatorv1.MultiClusterHub{},
 		}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.imageOverridesConfigmapRequests)).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.helmRepoSecretRequests)).
 		Watches(&source.Kind{Type: &apiregistrationv1.APIService{}}, handler.Funcs{
 			DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
 				labels := e.Object.GetLabels()
@@ -552,6 +546,29 @@ func (r *MultiClusterHubReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
+// imageOverridesConfigmapRequests returns a request for each multiclusterhub that references the configmap
+// in its image overrides annotation, so that edits to the configmap take effect without other changes
+func (r *MultiClusterHubReconciler) imageOverridesConfigmapRequests(a client.Object) []reconcile.Request {
+	multiClusterHubList := &operatorv1.MultiClusterHubList{}
+	if err := r.Client.List(context.TODO(), multiClusterHubList, client.InNamespace(a.GetNamespace())); err != nil {
+		return []reconcile.Request{}
+	}
+	requests := []reconcile.Request{}
+	for i := range multiClusterHubList.Items {
+		mch := &multiClusterHubList.Items[i]
+		for _, name := range utils.GetImageOverridesConfigmaps(mch) {
+			if name == a.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Name:      mch.GetName(),
+					Namespace: mch.GetNamespace(),
+				}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
 // ingressDomain is discovered from Openshift cluster configuration resources
 func (r *MultiClusterHubReconciler) ingressDomain(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
 	if r.CacheSpec.IngressDomain != "" || utils.IsUnitTest() {
@@ -580,10 +597,17 @@ func (r *MultiClusterHubReconciler) finalizeHub(reqLogger logr.Logger, m *operat
 	if _, err := r.ensureHubIsExported(m); err != nil {
 		return err
 	}
-	if err := r.cleanupAppSubscriptions(reqLogger, m); err != nil {
+	if err := r.orphanUnmanagedComponents(reqLogger, m); err != nil {
+		return err
+	}
+	if m.InstallEngine() == operatorv1.InstallEngineHelm {
+		if err := r.cleanupReleases(reqLogger, m); err != nil {
+			return err
+		}
+	} else if err := r.cleanupAppSubscriptions(reqLogger, m); err != nil {
 		return err
 	}
-	if err := r.cleanupNamespaces(reqLogger); err != nil {
+	if err := r.cleanupNamespaces(reqLogger, m); err != nil {
 		return err
 	}
 	if err := r.cleanupFoundation(reqLogger, m); err != nil {
@@ -708,6 +732,21 @@ func (r *MultiClusterHubReconciler) installCRDs(reqLogger logr.Logger, m *operat
 	return "", nil
 }
 
+// ensureHubResources deploys the hub resources, reporting a failure through the Progressing condition
+func (r *MultiClusterHubReconciler) ensureHubResources(m *operatorv1.MultiClusterHub) error {
+	reason, err := r.deployResources(r.Log, m)
+	if err != nil {
+		condition := NewHubCondition(
+			operatorv1.Progressing,
+			metav1.ConditionFalse,
+			reason,
+			fmt.Sprintf("Error deploying resources: %s", err),
+		)
+		SetHubCondition(&m.Status, *condition)
+	}
+	return err
+}
+
 func (r *MultiClusterHubReconciler) deployResources(reqLogger logr.Logger, m *operatorv1.MultiClusterHub) (string, error) {
 	resourceDir, ok := os.LookupEnv(templatesPathEnvVar)
 	if !ok {
@@ -803,6 +842,69 @@ func updatePausedCondition(m *operatorv1.MultiClusterHub) {
 	}
 }
 
+// updateDeprecationCondition reports deprecated fields and annotations set on the multiclusterhub,
+// naming the replacement for each
+func updateDeprecationCondition(m *operatorv1.MultiClusterHub) {
+	deprecations := utils.DeprecatedFieldsInUse(m)
+	if len(deprecations) == 0 {
+		RemoveHubCondition(&m.Status, operatorv1.DeprecatedFieldsInUse)
+		return
+	}
+
+	messages := make([]string, len(deprecations))
+	for i, d := range deprecations {
+		messages[i] = d.String()
+	}
+	condition := NewHubCondition(operatorv1.DeprecatedFieldsInUse, metav1.ConditionTrue, DeprecatedFieldsSetReason, strings.Join(messages, "; "))
+	SetHubConditionMessage(&m.Status, *condition)
+}
+
+// imageVerificationFailed stops the rollout of images that fail the verification policy and reports why
+// in the Blocked condition. Components keep running the images they were last given.
+func imageVerificationFailed(m *operatorv1.MultiClusterHub, err error) (ctrl.Result, error) {
+	condition := NewHubCondition(operatorv1.Blocked, metav1.ConditionTrue, ImageVerificationFailedReason,
+		fmt.Sprintf("Image verification failed: %s", err))
+	SetHubCondition(&m.Status, *condition)
+	return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+}
+
+// invalidImageManifest stops the rollout of images while the image manifest does not match its schema and
+// lists the problems in the Blocked condition
+func invalidImageManifest(m *operatorv1.MultiClusterHub, err error) (ctrl.Result, error) {
+	condition := NewHubCondition(operatorv1.Blocked, metav1.ConditionTrue, InvalidImageManifestReason, err.Error())
+	SetHubCondition(&m.Status, *condition)
+	return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+}
+
+// withoutImageRewrites returns a copy of the multiclusterhub without registry mirrors or a custom image repository
+func withoutImageRewrites(m *operatorv1.MultiClusterHub) *operatorv1.MultiClusterHub {
+	mch := m.DeepCopy()
+	mch.Spec.RegistryMirrors = nil
+	delete(mch.Annotations, utils.AnnotationImageRepo)
+	return mch
+}
+
+// updateImageOverridesCondition reports image override entries that were skipped and overrides that target
+// image keys no component uses
+func updateImageOverridesCondition(m *operatorv1.MultiClusterHub, issues []string, provenance *imageoverrides.Provenance) {
+	if len(issues) == 0 && len(provenance.UnknownKeys) == 0 {
+		RemoveHubCondition(&m.Status, operatorv1.ImageOverridesInvalid)
+		return
+	}
+
+	reason := UnknownImageKeysReason
+	messages := []string{}
+	if len(issues) > 0 {
+		reason = InvalidImageOverridesReason
+		messages = append(messages, fmt.Sprintf("Image overrides skipped: %s", strings.Join(issues, "; ")))
+	}
+	if len(provenance.UnknownKeys) > 0 {
+		messages = append(messages, fmt.Sprintf("Image overrides target unknown image keys: %s", strings.Join(provenance.UnknownKeys, ", ")))
+	}
+	condition := NewHubCondition(operatorv1.ImageOverridesInvalid, metav1.ConditionTrue, reason, strings.Join(messages, ". "))
+	SetHubConditionMessage(&m.Status, *condition)
+}
+
 func (r *MultiClusterHubReconciler) setDefaults(m *operatorv1.MultiClusterHub) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := r.Log
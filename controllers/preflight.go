@@ -0,0 +1,142 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/imageoverrides"
+	"github.com/stolostron/multiclusterhub-operator/pkg/registry"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// imagePreflightTimeout bounds the registry lookups of a single reconcile
+var imagePreflightTimeout = time.Second * 30
+
+// imagePreflightRetryInterval is how long an image that could not be found is reported as unavailable
+// before it is looked up again
+var imagePreflightRetryInterval = time.Minute * 5
+
+// globalPullSecret is the cluster pull secret of OpenShift, which nodes also pull hub images with
+var globalPullSecret = types.NamespacedName{Name: "pull-secret", Namespace: "openshift-config"}
+
+// imagePreflightResults holds the image preflight lookups of one manifest version, by image reference
+type imagePreflightResults struct {
+	manifestVersion string
+	results         map[string]imagePreflightResult
+}
+
+// imagePreflightResult is the outcome of looking up one image reference
+type imagePreflightResult struct {
+	// err describes why the image could not be found, empty if it was
+	err     string
+	checked time.Time
+}
+
+// imagePreflight looks up the images of every enabled component in their registry with the hub pull
+// secrets when the image-preflight annotation is set. Components with images that could not be found are
+// recorded in the cache so that reconcileComponents holds back their rollout. Each image of a manifest
+// version is looked up once, and again only after imagePreflightRetryInterval while it is not found.
+func (r *MultiClusterHubReconciler) imagePreflight(ctx context.Context, m *operatorv1.MultiClusterHub) error {
+	r.CacheSpec.UnavailableImages = nil
+	if !utils.ImagePreflightEnabled(m) {
+		return nil
+	}
+
+	cache := r.CacheSpec.ImagePreflight
+	if cache == nil || cache.manifestVersion != r.CacheSpec.ManifestVersion {
+		cache = &imagePreflightResults{manifestVersion: r.CacheSpec.ManifestVersion, results: map[string]imagePreflightResult{}}
+		r.CacheSpec.ImagePreflight = cache
+	}
+
+	componentKeys := imageoverrides.ComponentImageKeys(r.manifestImages())
+	lookups := map[string]string{}
+	imageComponents := map[string][]string{}
+	for _, c := range operatorv1.ComponentInstallOrder() {
+		if !m.Enabled(c) {
+			continue
+		}
+		for key, ref := range imageoverrides.ComponentImages(c, componentKeys, r.CacheSpec.ImageOverrides) {
+			imageComponents[key] = append(imageComponents[key], c)
+			result, ok := cache.results[ref]
+			if !ok || (result.err != "" && time.Since(result.checked) > imagePreflightRetryInterval) {
+				lookups[key] = ref
+			}
+		}
+	}
+
+	if len(lookups) > 0 {
+		creds, err := r.pullSecretCredentials(ctx, m)
+		if err != nil {
+			return err
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, imagePreflightTimeout)
+		defer cancel()
+		for _, result := range registry.NewClient(r.RegistryClient, creds).CheckImages(lookupCtx, lookups) {
+			checked := imagePreflightResult{checked: time.Now()}
+			if !result.Available {
+				r.Log.Info(fmt.Sprintf("Image preflight could not find %s: %s", result.Reference, result.Err))
+				checked.err = fmt.Sprint(result.Err)
+			}
+			cache.results[result.Reference] = checked
+		}
+	}
+
+	unavailable := map[string][]string{}
+	for key, components := range imageComponents {
+		ref := r.CacheSpec.ImageOverrides[key]
+		result := cache.results[ref]
+		if result.err == "" {
+			continue
+		}
+		for _, c := range components {
+			unavailable[c] = append(unavailable[c], fmt.Sprintf("%s (%s): %s", key, ref, result.err))
+		}
+	}
+	for c := range unavailable {
+		sort.Strings(unavailable[c])
+	}
+	if len(unavailable) > 0 {
+		r.CacheSpec.UnavailableImages = unavailable
+	}
+	return nil
+}
+
+// pullSecretCredentials returns the registry credentials of the cluster pull secret, if there is one, and
+// of the hub pull secret, which take precedence for the same registry
+func (r *MultiClusterHubReconciler) pullSecretCredentials(ctx context.Context, m *operatorv1.MultiClusterHub) (registry.Credentials, error) {
+	creds := registry.Credentials{}
+	secrets := []types.NamespacedName{globalPullSecret}
+	if m.Spec.ImagePullSecret != "" {
+		secrets = append(secrets, types.NamespacedName{Name: m.Spec.ImagePullSecret, Namespace: m.Namespace})
+	}
+	for _, name := range secrets {
+		secret := &corev1.Secret{}
+		err := r.Client.Get(ctx, name, secret)
+		if errors.IsNotFound(err) && name == globalPullSecret {
+			// Clusters other than OpenShift have no cluster pull secret
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		data, ok := secret.Data[corev1.DockerConfigJsonKey]
+		if !ok {
+			return nil, fmt.Errorf("pull secret %s has no %s key", name.Name, corev1.DockerConfigJsonKey)
+		}
+		secretCreds, err := registry.ParseDockerConfigJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		for host, auth := range secretCreds {
+			creds[host] = auth
+		}
+	}
+	return creds, nil
+}
@@ -0,0 +1,130 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_imagePreflight(t *testing.T) {
+	// Registry stand-in that requires basic auth and serves only the console manifest
+	var lookups int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "puller" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		atomic.AddInt32(&lookups, 1)
+		if r.URL.Path == "/v2/stolostron/console/manifests/sha256:abc" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	// The registry credentials come from the cluster pull secret; the hub pull secret only has others
+	dockerConfig := func(host, user, password string) []byte {
+		auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+		return []byte(fmt.Sprintf(`{"auths":{"%s":{"auth":"%s"}}}`, host, auth))
+	}
+	clusterPullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: "openshift-config"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig(host, "puller", "secret")},
+	}
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: "open-cluster-management"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig("registry.example.com", "other", "secret")},
+	}
+
+	mch := &operatorv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "multiclusterhub",
+			Namespace:   "open-cluster-management",
+			Annotations: map[string]string{utils.AnnotationImagePreflight: "true"},
+		},
+		Spec: operatorv1.MultiClusterHubSpec{ImagePullSecret: "pull-secret"},
+	}
+	mch.Enable(operatorv1.Repo)
+	mch.Enable(operatorv1.Console)
+	mch.Enable(operatorv1.Insights)
+	mch.Disable(operatorv1.ClusterBackup)
+
+	r := &MultiClusterHubReconciler{
+		Client:         fake.NewFakeClient(clusterPullSecret, pullSecret),
+		Log:            zap.New(),
+		RegistryClient: server.Client(),
+		CacheSpec: CacheSpec{ManifestVersion: "2.5.0", ManifestImages: []manifest.ManifestImage{}, ImageOverrides: map[string]string{
+			"console":                   host + "/stolostron/console@sha256:abc",
+			"insights_client":           host + "/stolostron/insights-client@sha256:def",
+			"cluster_backup_controller": host + "/stolostron/cluster-backup@sha256:000",
+		}},
+	}
+
+	if err := r.imagePreflight(context.TODO(), mch); err != nil {
+		t.Fatalf("imagePreflight() error = %v", err)
+	}
+	if _, ok := r.CacheSpec.UnavailableImages[operatorv1.Console]; ok {
+		t.Errorf("imagePreflight() reported console images as unavailable: %v", r.CacheSpec.UnavailableImages)
+	}
+	if missing := r.CacheSpec.UnavailableImages[operatorv1.Insights]; len(missing) != 1 || !strings.HasPrefix(missing[0], "insights_client") {
+		t.Errorf("imagePreflight() insights = %v, want insights_client unavailable", missing)
+	}
+	if _, ok := r.CacheSpec.UnavailableImages[operatorv1.ClusterBackup]; ok {
+		t.Errorf("imagePreflight() checked the images of a disabled component")
+	}
+
+	// Images are looked up once, and those not found again only after the retry interval
+	looked := atomic.LoadInt32(&lookups)
+	if err := r.imagePreflight(context.TODO(), mch); err != nil {
+		t.Fatalf("imagePreflight() error = %v", err)
+	}
+	if atomic.LoadInt32(&lookups) != looked {
+		t.Errorf("imagePreflight() looked up images again")
+	}
+	if missing := r.CacheSpec.UnavailableImages[operatorv1.Insights]; len(missing) != 1 {
+		t.Errorf("imagePreflight() insights = %v, want the cached result", missing)
+	}
+	defer func(interval time.Duration) { imagePreflightRetryInterval = interval }(imagePreflightRetryInterval)
+	imagePreflightRetryInterval = 0
+	if err := r.imagePreflight(context.TODO(), mch); err != nil {
+		t.Fatalf("imagePreflight() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&lookups) - looked; got != 1 {
+		t.Errorf("imagePreflight() made %d registry requests after the retry interval, want 1 for insights_client", got)
+	}
+
+	status := getComponentStatuses(mch, nil, nil, nil, nil, r.heldComponents())
+	if insights := status[operatorv1.Insights]; insights.Available || insights.Reason != ImagesUnavailableReason {
+		t.Errorf("getComponentStatuses() insights = %+v, want unavailable with reason %s", insights, ImagesUnavailableReason)
+	}
+
+	// Without the annotation nothing is checked and earlier results are cleared
+	mch.SetAnnotations(nil)
+	if err := r.imagePreflight(context.TODO(), mch); err != nil {
+		t.Fatalf("imagePreflight() error = %v", err)
+	}
+	if r.CacheSpec.UnavailableImages != nil {
+		t.Errorf("imagePreflight() = %v with the preflight disabled", r.CacheSpec.UnavailableImages)
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/deploying"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/rendering"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	"helm.sh/helm/v3/pkg/chartutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// chartFetchTimeout bounds the time spent downloading a chart from the helm repo
+const chartFetchTimeout = 30 * time.Second
+
+// ensureChart installs the chart of a component appsub with the install engine of the hub
+func (r *MultiClusterHubReconciler) ensureChart(m *operatorv1.MultiClusterHub, sub *unstructured.Unstructured) (ctrl.Result, error) {
+	if m.InstallEngine() == operatorv1.InstallEngineHelm {
+		return r.ensureRelease(m, sub)
+	}
+	return r.ensureSubscription(m, sub)
+}
+
+// ensureNoChart removes the chart of a component appsub with the install engine of the hub
+func (r *MultiClusterHubReconciler) ensureNoChart(m *operatorv1.MultiClusterHub, sub *unstructured.Unstructured) (ctrl.Result, error) {
+	if m.InstallEngine() == operatorv1.InstallEngineHelm {
+		return r.ensureNoRelease(m, sub.GetName())
+	}
+	return r.ensureNoSubscription(m, sub)
+}
+
+// ensureRelease renders the chart the appsub subscribes to and applies the result. Objects applied for a
+// previous rendering that the chart no longer renders are deleted.
+func (r *MultiClusterHubReconciler) ensureRelease(m *operatorv1.MultiClusterHub, sub *unstructured.Unstructured) (ctrl.Result, error) {
+	rel, err := rendering.FromSubscription(sub)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	relLog := r.Log.WithValues("Release", rel.Name, "Chart", rel.Chart, "Version", rel.Version)
+
+	archive, err := r.fetchChart(m, rel)
+	if err != nil {
+		relLog.Info("Chart not available from the helm repo yet", "error", err.Error())
+		return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+	}
+	caps, err := r.chartCapabilities()
+	if err != nil {
+		relLog.Error(err, "Failed to discover the cluster capabilities")
+		return ctrl.Result{}, err
+	}
+	crds, objects, err := rendering.Render(archive, rel, caps)
+	if err != nil {
+		relLog.Error(err, "Failed to render chart")
+		condition := NewHubCondition(operatorv1.Progressing, metav1.ConditionFalse, ResourceRenderReason,
+			fmt.Sprintf("Failed to render chart %s %s: %s", rel.Chart, rel.Version, err))
+		SetHubConditionMessage(&m.Status, *condition)
+		return ctrl.Result{}, err
+	}
+
+	for _, crd := range crds {
+		utils.AddInstallerLabel(crd, m.GetName(), m.GetNamespace())
+		if err, _ := deploying.Deploy(r.Client, crd); err != nil {
+			relLog.Error(err, "Failed to deploy chart CRD", "Name", crd.GetName())
+			return ctrl.Result{}, err
+		}
+	}
+
+	for _, obj := range objects {
+		r.setReleaseNamespace(obj, rel.Namespace)
+	}
+	previous, err := r.releaseObjects(m, rel.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	current := rendering.Refs(objects)
+	// Record the objects about to be applied before applying them, so that none is left untracked
+	if err := r.recordRelease(m, rel, appendMissingRefs(previous, current)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	for _, obj := range objects {
+		utils.AddInstallerLabel(obj, m.GetName(), m.GetNamespace())
+		if obj.GetNamespace() == m.Namespace {
+			obj.SetOwnerReferences([]metav1.OwnerReference{
+				*metav1.NewControllerRef(m, m.GetObjectKind().GroupVersionKind()),
+			})
+		}
+		err, created := deploying.Deploy(r.Client, obj)
+		if err != nil {
+			relLog.Error(err, "Failed to apply rendered object", "Kind", obj.GetKind(), "Name", obj.GetName())
+			return ctrl.Result{}, err
+		}
+		if created {
+			message := fmt.Sprintf("created new resource: %s %s", obj.GetKind(), obj.GetName())
+			condition := NewHubCondition(operatorv1.Progressing, metav1.ConditionTrue, NewComponentReason, message)
+			SetHubCondition(&m.Status, *condition)
+		}
+	}
+
+	rendered := map[rendering.ObjectRef]bool{}
+	for _, ref := range current {
+		rendered[ref] = true
+	}
+	for _, ref := range previous {
+		if rendered[ref] {
+			continue
+		}
+		relLog.Info("Deleting object no longer rendered by the chart", "Kind", ref.Kind, "Name", ref.Name)
+		if err := r.deleteReleaseObject(ref); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.recordRelease(m, rel, current)
+}
+
+// chartCapabilities returns the capabilities of the cluster charts are rendered against. They are
+// discovered once per reconcile, as charts installed earlier in the reconcile may serve new APIs.
+func (r *MultiClusterHubReconciler) chartCapabilities() (*chartutil.Capabilities, error) {
+	if r.CacheSpec.Capabilities != nil {
+		return r.CacheSpec.Capabilities, nil
+	}
+	if r.Discovery == nil {
+		return nil, fmt.Errorf("no discovery client to read the cluster capabilities")
+	}
+	caps, err := rendering.Capabilities(r.Discovery)
+	if err != nil {
+		return nil, err
+	}
+	r.CacheSpec.Capabilities = caps
+	return caps, nil
+}
+
+// setReleaseNamespace places a namespaced object without a namespace in the release namespace. Objects of
+// kinds the client cannot map are taken as namespaced.
+func (r *MultiClusterHubReconciler) setReleaseNamespace(obj *unstructured.Unstructured, namespace string) {
+	if obj.GetNamespace() != "" {
+		return
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := r.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err == nil && mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return
+	}
+	obj.SetNamespace(namespace)
+}
+
+// ensureNoRelease deletes the objects applied for a release, then its record
+func (r *MultiClusterHubReconciler) ensureNoRelease(m *operatorv1.MultiClusterHub, release string) (ctrl.Result, error) {
+	record := &corev1.ConfigMap{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rendering.RecordName(release), Namespace: m.Namespace}, record)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.deleteRelease(record)
+}
+
+// deleteRelease deletes the objects a release record lists, in reverse install order, then the record
+func (r *MultiClusterHubReconciler) deleteRelease(record *corev1.ConfigMap) error {
+	refs, err := rendering.RecordedObjects(record)
+	if err != nil {
+		return err
+	}
+	r.Log.Info("Deleting release", "Release", record.Labels[rendering.RecordLabel])
+	for i := len(refs) - 1; i >= 0; i-- {
+		if err := r.deleteReleaseObject(refs[i]); err != nil {
+			return err
+		}
+	}
+	if err := r.Client.Delete(context.TODO(), record); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *MultiClusterHubReconciler) deleteReleaseObject(ref rendering.ObjectRef) error {
+	err := r.Client.Delete(context.TODO(), ref.Object())
+	if err != nil && !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+		r.Log.Error(err, "Failed to delete release object", "Kind", ref.Kind, "Namespace", ref.Namespace, "Name", ref.Name)
+		return err
+	}
+	return nil
+}
+
+// releaseObjects returns the objects recorded for a release, or none if it was never applied
+func (r *MultiClusterHubReconciler) releaseObjects(m *operatorv1.MultiClusterHub, release string) ([]rendering.ObjectRef, error) {
+	record := &corev1.ConfigMap{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rendering.RecordName(release), Namespace: m.Namespace}, record)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return rendering.RecordedObjects(record)
+}
+
+// recordRelease creates or updates the record of the objects applied for a release
+func (r *MultiClusterHubReconciler) recordRelease(m *operatorv1.MultiClusterHub, rel *rendering.Release, refs []rendering.ObjectRef) error {
+	record, err := rendering.Record(rel, m.Namespace, refs)
+	if err != nil {
+		return err
+	}
+	record.Labels["installer.name"] = m.GetName()
+	record.Labels["installer.namespace"] = m.GetNamespace()
+
+	found := &corev1.ConfigMap{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: record.Name, Namespace: record.Namespace}, found)
+	if errors.IsNotFound(err) {
+		return r.Client.Create(context.TODO(), record)
+	} else if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(found.Data, record.Data) {
+		return nil
+	}
+	found.Data = record.Data
+	return r.Client.Update(context.TODO(), found)
+}
+
+// ensureNoReleases deletes every release applied by the Helm install engine
+func (r *MultiClusterHubReconciler) ensureNoReleases(m *operatorv1.MultiClusterHub) error {
+	records := &corev1.ConfigMapList{}
+	err := r.Client.List(context.TODO(), records, client.InNamespace(m.Namespace), client.HasLabels{rendering.RecordLabel},
+		client.MatchingLabels{
+			"installer.name":      m.GetName(),
+			"installer.namespace": m.GetNamespace(),
+		})
+	if err != nil {
+		return err
+	}
+	for i := range records.Items {
+		if err := r.deleteRelease(&records.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchChart downloads the packaged chart of a release from the helm repo. Charts are immutable for a repo
+// image, so each is downloaded once.
+func (r *MultiClusterHubReconciler) fetchChart(m *operatorv1.MultiClusterHub, rel *rendering.Release) ([]byte, error) {
+	key := chartKey(helmrepo.Image(r.CacheSpec.ImageOverrides), subscribedChart{name: rel.Chart, version: rel.Version})
+	if archive, ok := r.CacheSpec.ChartArchives[key]; ok {
+		return archive, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), chartFetchTimeout)
+	defer cancel()
+	repo, err := r.helmRepoAccess(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	index, err := helmrepo.FetchIndex(ctx, repo.client, repo.url, repo.user, repo.password)
+	if err != nil {
+		return nil, err
+	}
+	cv, err := index.Get(rel.Chart, rel.Version)
+	if err != nil {
+		return nil, err
+	}
+	cv, err = releasedChartVersion(m, cv)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := helmrepo.FetchChart(ctx, repo.client, repo.url, repo.user, repo.password, cv)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.CacheSpec.ChartArchives == nil {
+		r.CacheSpec.ChartArchives = map[string][]byte{}
+	}
+	r.CacheSpec.ChartArchives[key] = archive
+	return archive, nil
+}
+
+// getReleaseDeployments returns the deployments rendered for the appsubs of the hub by the Helm install engine
+func getReleaseDeployments(allDeps []*appsv1.Deployment, appsubs []types.NamespacedName) []*appsv1.Deployment {
+	deps := []*appsv1.Deployment{}
+	for _, s := range appsubs {
+		deps = append(deps, filterDeploymentsByRelease(allDeps, s.Name)...)
+	}
+	return deps
+}
+
+// appendMissingRefs returns refs followed by the references of extra it does not hold
+func appendMissingRefs(refs, extra []rendering.ObjectRef) []rendering.ObjectRef {
+	seen := map[rendering.ObjectRef]bool{}
+	all := []rendering.ObjectRef{}
+	for _, ref := range append(append([]rendering.ObjectRef{}, refs...), extra...) {
+		if !seen[ref] {
+			seen[ref] = true
+			all = append(all, ref)
+		}
+	}
+	return all
+}
@@ -0,0 +1,197 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/rendering"
+	"github.com/stolostron/multiclusterhub-operator/pkg/subscription"
+	pkgversion "github.com/stolostron/multiclusterhub-operator/pkg/version"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// testDiscovery serves the APIs of the test charts
+func testDiscovery() *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+			{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}}},
+		}},
+		FakedServerVersion: &version.Info{GitVersion: "v1.23.4", Major: "1", Minor: "23"},
+	}
+}
+
+const releaseDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}-controller
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: grc
+  template:
+    metadata:
+      labels:
+        app: grc
+    spec:
+      containers:
+      - name: controller
+        image: {{ .Values.global.imageOverrides.grc_policy_propagator }}
+`
+
+const releaseService = `apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Release.Name }}-metrics
+`
+
+// packageChart returns a packaged chart holding the templates and, if set, a values schema
+func packageChart(t *testing.T, name, version, schema string, templates map[string]string) []byte {
+	c := &chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: version}}
+	if schema != "" {
+		c.Schema = []byte(schema)
+	}
+	for file, data := range templates {
+		c.Templates = append(c.Templates, &chart.File{Name: "templates/" + file, Data: []byte(data)})
+	}
+	path, err := chartutil.Save(c, t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to package chart: %v", err)
+	}
+	archive, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func Test_ensureRelease(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(manifest.ManifestsPathEnvVar, dir)
+	mch := &operatorv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"},
+		Spec:       operatorv1.MultiClusterHubSpec{InstallEngine: operatorv1.InstallEngineHelm},
+	}
+	sub := subscription.GRC(mch, map[string]string{"grc_policy_propagator": "quay.io/grc@sha256:abc"})
+	rel, err := rendering.FromSubscription(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := packageChart(t, rel.Chart, rel.Version, "", map[string]string{
+		"deployment.yaml": releaseDeployment,
+		"service.yaml":    releaseService,
+	})
+	sum := sha256.Sum256(archive)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != helmrepo.HelmRepoName || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/charts/index.yaml":
+			fmt.Fprintf(w, "entries:\n  %s:\n  - name: %s\n    version: %s\n    digest: %s\n    urls:\n    - %s.tgz\n",
+				rel.Chart, rel.Chart, rel.Version, hex.EncodeToString(sum[:]), rel.Chart)
+		case "/charts/" + rel.Chart + ".tgz":
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	auth := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: helmrepo.AuthSecretName, Namespace: "test"},
+		Data:       map[string][]byte{"user": []byte(helmrepo.HelmRepoName), "password": []byte("secret")},
+	}
+	r := &MultiClusterHubReconciler{
+		Client:         fake.NewFakeClient(auth),
+		Log:            zap.New(),
+		HelmRepoClient: server.Client(),
+		HelmRepoURL:    server.URL + "/charts",
+		Discovery:      testDiscovery(),
+	}
+
+	// An archive that does not match the digest the release shipped is not applied, although the index of
+	// the helm repo lists it
+	chartManifest := func(digest string) []byte {
+		return []byte(fmt.Sprintf(`{"schemaVersion":1,"charts":[{"name":"%s","version":"%s","digest":"sha256:%s"}]}`, rel.Chart, rel.Version, digest))
+	}
+	chartManifestPath := filepath.Join(dir, pkgversion.Version+manifest.ChartsFileSuffix)
+	if err := os.WriteFile(chartManifestPath, chartManifest("0f1e2d"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := r.ensureChart(mch, sub); err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("ensureChart() = %v, %v, want a requeue for the tampered chart", result, err)
+	}
+	if err := os.WriteFile(chartManifestPath, chartManifest(hex.EncodeToString(sum[:])), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.ensureChart(mch, sub); err != nil {
+		t.Fatalf("ensureChart() error = %v", err)
+	}
+	dep := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "grc-sub-controller", Namespace: "test"}, dep); err != nil {
+		t.Fatalf("ensureChart() did not apply the deployment: %v", err)
+	}
+	if dep.Annotations[rendering.ReleaseNameAnnotation] != "grc-sub" || dep.Labels["installer.name"] != "multiclusterhub" {
+		t.Errorf("ensureChart() deployment labels = %v, annotations = %v", dep.Labels, dep.Annotations)
+	}
+	if dep.Spec.Template.Spec.Containers[0].Image != "quay.io/grc@sha256:abc" {
+		t.Errorf("ensureChart() image = %s, want the image override", dep.Spec.Template.Spec.Containers[0].Image)
+	}
+	if refs, err := r.releaseObjects(mch, "grc-sub"); err != nil || len(refs) != 2 {
+		t.Errorf("ensureChart() recorded %v, %v, want the deployment and service", refs, err)
+	}
+
+	// Objects the chart no longer renders are deleted
+	key := chartKey(helmrepo.Image(r.CacheSpec.ImageOverrides), subscribedChart{name: rel.Chart, version: rel.Version})
+	r.CacheSpec.ChartArchives[key] = packageChart(t, rel.Chart, rel.Version, "", map[string]string{"deployment.yaml": releaseDeployment})
+	if _, err := r.ensureChart(mch, sub); err != nil {
+		t.Fatalf("ensureChart() error = %v", err)
+	}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: "grc-sub-metrics", Namespace: "test"}, &corev1.Service{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("ensureChart() kept the service the chart no longer renders: %v", err)
+	}
+	if refs, err := r.releaseObjects(mch, "grc-sub"); err != nil || len(refs) != 1 {
+		t.Errorf("ensureChart() recorded %v, %v, want the deployment", refs, err)
+	}
+
+	if _, err := r.ensureNoChart(mch, sub); err != nil {
+		t.Fatalf("ensureNoChart() error = %v", err)
+	}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: "grc-sub-controller", Namespace: "test"}, &appsv1.Deployment{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("ensureNoChart() kept the deployment: %v", err)
+	}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: rendering.RecordName("grc-sub"), Namespace: "test"}, &corev1.ConfigMap{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("ensureNoChart() kept the release record: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/subscription"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// rolledBack returns true if the upgrade of the hub to the operator version was rolled back
+func rolledBack(m *operatorv1.MultiClusterHub) bool {
+	return upgrading(m) && m.Status.RolledBackVersion == version.Version
+}
+
+// releaseVersion returns the release the hub components run: the operator version, or the release an
+// upgrade was rolled back to
+func releaseVersion(m *operatorv1.MultiClusterHub) string {
+	if rolledBack(m) {
+		return m.Status.CurrentVersion
+	}
+	return version.Version
+}
+
+// withReleaseVersion returns a builder of the component appsub subscribing to the chart of the release
+// the hub components run
+func withReleaseVersion(build func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured) func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+	return func(m *operatorv1.MultiClusterHub) *unstructured.Unstructured {
+		sub := build(m)
+		if rolledBack(m) {
+			subscription.SetVersion(sub, m.Status.CurrentVersion)
+		}
+		return sub
+	}
+}
+
+// ensureUpgradeRollback rolls the hub components back to the release the hub last ran successfully when
+// they are not all available by the deadline after an upgrade started, and keeps them there while the
+// rollback policy is enabled. The image overrides of that release are read from the image manifest
+// configmap the operator kept for it.
+func (r *MultiClusterHubReconciler) ensureUpgradeRollback(ctx context.Context, m *operatorv1.MultiClusterHub) error {
+	if !m.UpgradeRollbackEnabled() || !upgrading(m) {
+		if rolledBack(m) {
+			r.Log.Info("Upgrade rollback disabled, resuming the upgrade", "Version", version.Version)
+		}
+		m.Status.RolledBackVersion = ""
+		RemoveHubCondition(&m.Status, operatorv1.UpgradeRolledBack)
+		return nil
+	}
+	if m.Status.RolledBackVersion != "" && m.Status.RolledBackVersion != version.Version {
+		// The operator moved on from the version whose upgrade was rolled back
+		m.Status.RolledBackVersion = ""
+		RemoveHubCondition(&m.Status, operatorv1.UpgradeRolledBack)
+	}
+
+	snapshot, snapshotErr := manifest.LiveImageManifest(ctx, r.Client, m.Namespace, m.Status.CurrentVersion)
+	if !rolledBack(m) {
+		unavailable, err := r.overdueComponents(ctx, m)
+		if err != nil || len(unavailable) == 0 {
+			return err
+		}
+		message := fmt.Sprintf("Components not available %s after the upgrade to %s started: %s",
+			m.UpgradeRollbackDeadline(), version.Version, strings.Join(unavailable, ", "))
+		if snapshotErr != nil {
+			r.Log.Info("Upgrade cannot be rolled back", "error", snapshotErr.Error())
+			condition := NewHubCondition(operatorv1.UpgradeRolledBack, metav1.ConditionFalse, RollbackUnavailableReason,
+				fmt.Sprintf("%s. The images of %s are unknown: %s", message, m.Status.CurrentVersion, snapshotErr))
+			SetHubCondition(&m.Status, *condition)
+			return nil
+		}
+		r.Log.Info("Rolling back upgrade", "From", version.Version, "To", m.Status.CurrentVersion, "Unavailable", unavailable)
+		m.Status.RolledBackVersion = version.Version
+		condition := NewHubCondition(operatorv1.UpgradeRolledBack, metav1.ConditionTrue, UpgradeDeadlineExceededReason,
+			fmt.Sprintf("%s. Rolled back to %s.", message, m.Status.CurrentVersion))
+		SetHubCondition(&m.Status, *condition)
+	}
+	if snapshotErr != nil {
+		return snapshotErr
+	}
+	r.CacheSpec.ImageOverrides = snapshot.Data
+	return nil
+}
+
+// overdueComponents returns, sorted, the components that are not available once the rollback deadline
+// of the upgrade passed. The upgrade starts when the operator records the image manifest of its version.
+func (r *MultiClusterHubReconciler) overdueComponents(ctx context.Context, m *operatorv1.MultiClusterHub) ([]string, error) {
+	upgrade, err := manifest.LiveImageManifest(ctx, r.Client, m.Namespace, version.Version)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(upgrade.CreationTimestamp.Time) < m.UpgradeRollbackDeadline() {
+		return nil, nil
+	}
+	statuses, err := r.currentComponentStatuses(m)
+	if err != nil {
+		return nil, err
+	}
+	unavailable := []string{}
+	for name, status := range statuses {
+		if name != ManagedClusterName && !successfulComponent(status) {
+			unavailable = append(unavailable, name)
+		}
+	}
+	sort.Strings(unavailable)
+	return unavailable, nil
+}
@@ -0,0 +1,99 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// imageManifest returns the image manifest configmap the operator keeps for a release
+func imageManifest(release string, created time.Time, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "mch-image-manifest-" + release,
+			Namespace:         "test",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{"ocm-configmap-type": "image-manifest", "ocm-release-version": release},
+		},
+		Data: data,
+	}
+}
+
+func Test_ensureUpgradeRollback(t *testing.T) {
+	if version.Version == "" {
+		t.Skip("OPERATOR_VERSION is not set")
+	}
+	t.Setenv("POD_NAMESPACE", "test")
+
+	mch := &operatorsv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"},
+		Spec: operatorsv1.MultiClusterHubSpec{
+			InstallEngine:   operatorsv1.InstallEngineHelm,
+			UpgradeRollback: &operatorsv1.UpgradeRollback{Enabled: true, Deadline: &metav1.Duration{Duration: 2 * time.Hour}},
+			Overrides: &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+				{Name: operatorsv1.Repo, Enabled: true},
+				{Name: operatorsv1.GRC, Enabled: true},
+			}},
+		},
+		Status: operatorsv1.MultiClusterHubStatus{CurrentVersion: "1.0.0"},
+	}
+	previousImages := map[string]string{"grc_policy_propagator": "quay.io/stolostron/grc-policy-propagator:1.0.0"}
+	r := &MultiClusterHubReconciler{
+		Client: fake.NewFakeClient(
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: utils.MCHOperatorName, Namespace: "test"}},
+			imageManifest(version.Version, time.Now().Add(-time.Hour), map[string]string{}),
+			imageManifest("1.0.0", time.Now().Add(-30*24*time.Hour), previousImages),
+		),
+		Log: zap.New(),
+	}
+
+	// Within the deadline the upgrade goes on
+	if err := r.ensureUpgradeRollback(context.TODO(), mch); err != nil {
+		t.Fatalf("ensureUpgradeRollback() error = %v", err)
+	}
+	if rolledBack(mch) || HubConditionPresent(mch.Status, operatorsv1.UpgradeRolledBack) {
+		t.Fatalf("ensureUpgradeRollback() rolled back an upgrade within its deadline")
+	}
+
+	mch.Spec.UpgradeRollback.Deadline = &metav1.Duration{Duration: 10 * time.Minute}
+	if err := r.ensureUpgradeRollback(context.TODO(), mch); err != nil {
+		t.Fatalf("ensureUpgradeRollback() error = %v", err)
+	}
+	if mch.Status.RolledBackVersion != version.Version {
+		t.Fatalf("ensureUpgradeRollback() rolled back version = %q, want %q", mch.Status.RolledBackVersion, version.Version)
+	}
+	if c := GetHubCondition(mch.Status, operatorsv1.UpgradeRolledBack); c == nil || c.Reason != UpgradeDeadlineExceededReason {
+		t.Errorf("ensureUpgradeRollback() condition = %+v, want %s", c, UpgradeDeadlineExceededReason)
+	}
+	if r.CacheSpec.ImageOverrides["grc_policy_propagator"] != previousImages["grc_policy_propagator"] {
+		t.Errorf("ensureUpgradeRollback() image overrides = %v, want those of 1.0.0", r.CacheSpec.ImageOverrides)
+	}
+	sub := r.componentActions()[operatorsv1.GRC].subscription(mch)
+	if v, _, _ := unstructured.NestedString(sub.Object, "spec", "packageFilter", "version"); v != "1.0.0" {
+		t.Errorf("rolled back appsub subscribes to version %q, want 1.0.0", v)
+	}
+
+	// Disabling the policy resumes the upgrade
+	mch.Spec.UpgradeRollback.Enabled = false
+	if err := r.ensureUpgradeRollback(context.TODO(), mch); err != nil {
+		t.Fatalf("ensureUpgradeRollback() error = %v", err)
+	}
+	if rolledBack(mch) || HubConditionPresent(mch.Status, operatorsv1.UpgradeRolledBack) {
+		t.Errorf("ensureUpgradeRollback() kept the rollback once disabled")
+	}
+	if v := releaseVersion(mch); v != version.Version {
+		t.Errorf("releaseVersion() = %q, want %q", v, version.Version)
+	}
+}
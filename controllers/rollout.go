@@ -0,0 +1,210 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	subv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	mcev1 "github.com/stolostron/backplane-operator/api/v1"
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/multiclusterengine"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	subhelmv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
+)
+
+// upgrading returns true while the hub upgrades from a previous release to the operator version
+func upgrading(m *operatorv1.MultiClusterHub) bool {
+	return m.Status.CurrentVersion != "" && m.Status.CurrentVersion != version.Version
+}
+
+// rolloutWaves returns the components to install in waves. Outside of upgrades every component is
+// installed in a single wave.
+func rolloutWaves(m *operatorv1.MultiClusterHub) [][]string {
+	if !upgrading(m) {
+		return [][]string{operatorv1.ComponentInstallOrder()}
+	}
+	return m.RolloutWaves()
+}
+
+// componentStatusKeys returns the entries of the component statuses that report whether a component is
+// available
+func componentStatusKeys(m *operatorv1.MultiClusterHub, name string, a componentActions) []string {
+	keys := []string{}
+	switch {
+	case a.subscription != nil:
+		keys = append(keys, a.subscription(m).GetName())
+	case name == operatorv1.Repo:
+		for _, d := range utils.GetDeploymentsForStatus(m) {
+			keys = append(keys, d.Name)
+		}
+	case name == operatorv1.MultiClusterEngine:
+		for _, cr := range utils.GetCustomResourcesForStatus(m) {
+			keys = append(keys, cr.Name)
+		}
+	}
+	return keys
+}
+
+// currentComponentStatuses lists the component resources afresh and returns their statuses
+func (r *MultiClusterHubReconciler) currentComponentStatuses(m *operatorv1.MultiClusterHub) (map[string]operatorv1.StatusCondition, error) {
+	trackedNamespaces := utils.TrackedNamespaces(m)
+	deps, err := r.listDeployments(trackedNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	var hrs []*subhelmv1.HelmRelease
+	if m.InstallEngine() != operatorv1.InstallEngineHelm {
+		hrs, err = r.listHelmReleases(trackedNamespaces)
+		if err != nil {
+			return nil, err
+		}
+	}
+	crs, err := r.listCustomResources(m)
+	if err != nil {
+		return nil, err
+	}
+	return getComponentStatuses(m, hrs, deps, crs, nil, r.heldComponents()), nil
+}
+
+// rolloutProgress checks the components rolled out so far against their statuses. It returns the first
+// component that failed with the reason, which stops the rollout with the Blocked condition, or else the
+// components that are not available yet, which the next wave waits for.
+func rolloutProgress(rolledOut []string, keys map[string][]string, statuses map[string]operatorv1.StatusCondition) (failed, reason string, pending []string) {
+	pending = []string{}
+	for _, c := range rolledOut {
+		for _, k := range keys[c] {
+			status, ok := statuses[k]
+			if !ok || successfulComponent(status) {
+				continue
+			}
+			if failedComponent(status) {
+				return c, fmt.Sprintf("%s: %s", status.Reason, status.Message), nil
+			}
+			pending = append(pending, c)
+			break
+		}
+	}
+	return "", "", pending
+}
+
+// unfinishedRollouts returns the components rolled out so far that have not finished rolling out, which
+// the next wave waits for as well: a deployment of the component has not observed its latest spec or not
+// updated all its replicas, or the multicluster-engine does not report the version being rolled out.
+// Component statuses alone can report a component available while its previous version still serves.
+func (r *MultiClusterHubReconciler) unfinishedRollouts(m *operatorv1.MultiClusterHub, rolledOut []string, actions map[string]componentActions) ([]string, error) {
+	trackedNamespaces := utils.TrackedNamespaces(m)
+	deps, err := r.listDeployments(trackedNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	var hrs []*subhelmv1.HelmRelease
+	if m.InstallEngine() != operatorv1.InstallEngineHelm {
+		hrs, err = r.listHelmReleases(trackedNamespaces)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	unfinished := []string{}
+	for _, c := range rolledOut {
+		waiting := ""
+		if c == operatorv1.MultiClusterEngine {
+			if waiting, err = r.multiClusterEngineRollout(); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, d := range componentDeployments(m, c, actions[c], deps, hrs) {
+				if !deploymentRolledOut(d) {
+					waiting = fmt.Sprintf("deployment %s/%s is rolling out", d.Namespace, d.Name)
+					break
+				}
+			}
+		}
+		if waiting != "" {
+			r.Log.Info("Component has not finished rolling out", "Component", c, "Detail", waiting)
+			unfinished = append(unfinished, c)
+		}
+	}
+	return unfinished, nil
+}
+
+// componentDeployments returns the deployments of a component: the helm repo, or those rendered from the
+// chart of its appsub
+func componentDeployments(m *operatorv1.MultiClusterHub, name string, a componentActions, deps []*appsv1.Deployment, hrs []*subhelmv1.HelmRelease) []*appsv1.Deployment {
+	switch {
+	case name == operatorv1.Repo:
+		for _, d := range deps {
+			if d.Name == helmrepo.HelmRepoName && d.Namespace == m.Namespace {
+				return []*appsv1.Deployment{d}
+			}
+		}
+	case a.subscription != nil:
+		sub := a.subscription(m)
+		if m.InstallEngine() == operatorv1.InstallEngineHelm {
+			return filterDeploymentsByRelease(deps, sub.GetName())
+		}
+		owned := getAppSubOwnedHelmReleases(hrs, []types.NamespacedName{{Name: sub.GetName(), Namespace: sub.GetNamespace()}})
+		return getHelmReleaseOwnedDeployments(deps, owned)
+	}
+	return nil
+}
+
+// deploymentRolledOut returns true once the deployment controller has observed the latest spec of the
+// deployment and updated all its replicas
+func deploymentRolledOut(d *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return d.Status.ObservedGeneration >= d.Generation && d.Status.UpdatedReplicas == replicas
+}
+
+// multiClusterEngineRollout describes why the multicluster-engine has not finished rolling out, or returns
+// an empty string once it has
+func (r *MultiClusterHubReconciler) multiClusterEngineRollout() (string, error) {
+	mce := &unstructured.Unstructured{}
+	mce.SetGroupVersionKind(mcev1.GroupVersion.WithKind("MultiClusterEngine"))
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: multiclusterengine.MulticlusterengineName}, mce)
+	if errors.IsNotFound(err) {
+		return "the MultiClusterEngine is not created yet", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	expected := ""
+	sub := &subv1alpha1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: utils.MCESubscriptionName, Namespace: utils.MCESubscriptionNamespace}}
+	if csv, err := r.GetCSVFromSubscription(sub); err == nil {
+		expected, _, _ = unstructured.NestedString(csv.Object, "spec", "version")
+	}
+	return multiClusterEngineRollout(mce, expected), nil
+}
+
+// multiClusterEngineRollout checks the version the multicluster-engine reports against the version of its
+// installed operator, or else the version it reports rolling out. Engines that do not report versions are
+// gated on their phase.
+func multiClusterEngineRollout(mce *unstructured.Unstructured, expected string) string {
+	current, _, _ := unstructured.NestedString(mce.Object, "status", "currentVersion")
+	if current == "" {
+		phase, _, _ := unstructured.NestedString(mce.Object, "status", "phase")
+		if phase != string(mcev1.MultiClusterEnginePhaseAvailable) {
+			return fmt.Sprintf("the MultiClusterEngine is in phase %q", phase)
+		}
+		return ""
+	}
+	if expected == "" {
+		expected, _, _ = unstructured.NestedString(mce.Object, "status", "desiredVersion")
+	}
+	if expected != "" && current != expected {
+		return fmt.Sprintf("the MultiClusterEngine reports version %s, rolling out %s", current, expected)
+	}
+	return ""
+}
@@ -0,0 +1,182 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	mcev1 "github.com/stolostron/backplane-operator/api/v1"
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/helmrepo"
+	"github.com/stolostron/multiclusterhub-operator/pkg/multiclusterengine"
+	"github.com/stolostron/multiclusterhub-operator/pkg/rendering"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_rolloutWaves(t *testing.T) {
+	m := &operatorsv1.MultiClusterHub{Status: operatorsv1.MultiClusterHubStatus{CurrentVersion: version.Version}}
+	if waves := rolloutWaves(m); len(waves) != 1 {
+		t.Errorf("rolloutWaves() = %v, want a single wave outside of upgrades", waves)
+	}
+	m.Status.CurrentVersion = ""
+	if waves := rolloutWaves(m); len(waves) != 1 {
+		t.Errorf("rolloutWaves() = %v, want a single wave on install", waves)
+	}
+	m.Status.CurrentVersion = "1.0.0"
+	if waves := rolloutWaves(m); !reflect.DeepEqual(waves, m.RolloutWaves()) {
+		t.Errorf("rolloutWaves() = %v, want the rollout waves during an upgrade", waves)
+	}
+}
+
+func Test_rolloutProgress(t *testing.T) {
+	available := operatorsv1.StatusCondition{Kind: "Deployment", Type: "Available", Status: metav1.ConditionTrue, Available: true}
+	rolling := operatorsv1.StatusCondition{Kind: "Deployment", Type: string(appsv1.DeploymentProgressing), Status: metav1.ConditionTrue}
+	stuck := operatorsv1.StatusCondition{
+		Kind:    "Deployment",
+		Type:    string(appsv1.DeploymentProgressing),
+		Status:  metav1.ConditionFalse,
+		Reason:  "ProgressDeadlineExceeded",
+		Message: "ReplicaSet grc-policy-propagator has timed out progressing.",
+	}
+	keys := map[string][]string{
+		operatorsv1.Repo:   {"multiclusterhub-repo"},
+		operatorsv1.GRC:    {"grc-sub"},
+		operatorsv1.Search: {"search-prod-sub"},
+	}
+	rolledOut := []string{operatorsv1.Repo, operatorsv1.GRC, operatorsv1.Search}
+
+	tests := []struct {
+		name        string
+		statuses    map[string]operatorsv1.StatusCondition
+		wantFailed  string
+		wantPending []string
+	}{
+		{
+			name:        "Available",
+			statuses:    map[string]operatorsv1.StatusCondition{"multiclusterhub-repo": available, "grc-sub": available, "search-prod-sub": available},
+			wantPending: []string{},
+		},
+		{
+			name:        "Rolling out",
+			statuses:    map[string]operatorsv1.StatusCondition{"multiclusterhub-repo": available, "grc-sub": rolling, "search-prod-sub": unknownStatus},
+			wantPending: []string{operatorsv1.GRC, operatorsv1.Search},
+		},
+		{
+			name:       "Failed",
+			statuses:   map[string]operatorsv1.StatusCondition{"multiclusterhub-repo": available, "grc-sub": stuck, "search-prod-sub": rolling},
+			wantFailed: operatorsv1.GRC,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failed, reason, pending := rolloutProgress(rolledOut, keys, tt.statuses)
+			if failed != tt.wantFailed || !reflect.DeepEqual(pending, tt.wantPending) {
+				t.Errorf("rolloutProgress() = %q, %q, %v, want %q, %v", failed, reason, pending, tt.wantFailed, tt.wantPending)
+			}
+			if failed != "" && reason != "ProgressDeadlineExceeded: "+stuck.Message {
+				t.Errorf("rolloutProgress() reason = %q", reason)
+			}
+		})
+	}
+}
+
+func Test_unfinishedRollouts(t *testing.T) {
+	mch := &operatorsv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"},
+		Spec: operatorsv1.MultiClusterHubSpec{
+			InstallEngine: operatorsv1.InstallEngineHelm,
+			Overrides: &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+				{Name: operatorsv1.Repo, Enabled: true},
+				{Name: operatorsv1.GRC, Enabled: true},
+			}},
+		},
+	}
+	deployment := func(name, release string, generation, observed int64, updated int32) *appsv1.Deployment {
+		replicas := int32(2)
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Generation: generation,
+				Annotations: map[string]string{rendering.ReleaseNameAnnotation: release}},
+			Spec:   appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{ObservedGeneration: observed, UpdatedReplicas: updated, AvailableReplicas: 2},
+		}
+	}
+	mce := &unstructured.Unstructured{}
+	mce.SetGroupVersionKind(mcev1.GroupVersion.WithKind("MultiClusterEngine"))
+	mce.SetName(multiclusterengine.MulticlusterengineName)
+	_ = unstructured.SetNestedMap(mce.Object, map[string]interface{}{
+		"phase": "Available", "currentVersion": "2.0.0", "desiredVersion": "2.0.1",
+	}, "status")
+
+	tests := []struct {
+		name string
+		deps []*appsv1.Deployment
+		want []string
+	}{
+		{
+			name: "Rolled out",
+			deps: []*appsv1.Deployment{deployment(helmrepo.HelmRepoName, "", 3, 3, 2), deployment("grc-policy-propagator", "grc-sub", 2, 2, 2)},
+			want: []string{operatorsv1.MultiClusterEngine},
+		},
+		{
+			name: "Spec not observed",
+			deps: []*appsv1.Deployment{deployment(helmrepo.HelmRepoName, "", 3, 3, 2), deployment("grc-policy-propagator", "grc-sub", 2, 1, 2)},
+			want: []string{operatorsv1.GRC, operatorsv1.MultiClusterEngine},
+		},
+		{
+			name: "Replicas not updated",
+			deps: []*appsv1.Deployment{deployment(helmrepo.HelmRepoName, "", 3, 3, 1), deployment("grc-policy-propagator", "grc-sub", 2, 2, 2)},
+			want: []string{operatorsv1.Repo, operatorsv1.MultiClusterEngine},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{mce.DeepCopy()}
+			for _, d := range tt.deps {
+				objs = append(objs, d)
+			}
+			r := &MultiClusterHubReconciler{Client: fake.NewFakeClient(objs...), Log: zap.New()}
+			got, err := r.unfinishedRollouts(mch, []string{operatorsv1.Repo, operatorsv1.GRC, operatorsv1.MultiClusterEngine}, r.componentActions())
+			if err != nil {
+				t.Fatalf("unfinishedRollouts() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unfinishedRollouts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_multiClusterEngineRollout(t *testing.T) {
+	mce := func(status map[string]interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		_ = unstructured.SetNestedMap(u.Object, status, "status")
+		return u
+	}
+	tests := []struct {
+		name     string
+		mce      *unstructured.Unstructured
+		expected string
+		wantWait bool
+	}{
+		{name: "Operator version reported", mce: mce(map[string]interface{}{"currentVersion": "2.0.1"}), expected: "2.0.1"},
+		{name: "Previous version reported", mce: mce(map[string]interface{}{"currentVersion": "2.0.0", "desiredVersion": "2.0.0"}), expected: "2.0.1", wantWait: true},
+		{name: "Desired version reported", mce: mce(map[string]interface{}{"currentVersion": "2.0.1", "desiredVersion": "2.0.1"})},
+		{name: "Desired version pending", mce: mce(map[string]interface{}{"currentVersion": "2.0.0", "desiredVersion": "2.0.1"}), wantWait: true},
+		{name: "No version, available", mce: mce(map[string]interface{}{"phase": "Available"})},
+		{name: "No version, progressing", mce: mce(map[string]interface{}{"phase": "Progressing"}), wantWait: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := multiClusterEngineRollout(tt.mce, tt.expected); (got != "") != tt.wantWait {
+				t.Errorf("multiClusterEngineRollout() = %q, want waiting %v", got, tt.wantWait)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func Test_maintainSBOMConfigmap(t *testing.T) {
+	// The manifest is taken from the cache, so the manifests directory is never read
+	t.Setenv(manifest.ManifestsPathEnvVar, t.TempDir())
+
+	mch := &operatorv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "test"}}
+	r := &MultiClusterHubReconciler{
+		Client: fake.NewFakeClient(),
+		Log:    zap.New(),
+		CacheSpec: CacheSpec{
+			ManifestVersion: "2.5.0",
+			ImageOverrides: map[string]string{
+				"console":   "quay.io/stolostron/console@sha256:0123",
+				"search_ui": "quay.io/stolostron/search-ui@sha256:4567",
+			},
+			ManifestImages: []manifest.ManifestImage{
+				{ImageKey: "console", ImageDigest: "sha256:0123", GitRepository: "stolostron/console", GitSha256: "abcd"},
+				{ImageKey: "search_ui", ImageDigest: "sha256:ffff", GitRepository: "stolostron/search-ui", GitSha256: "ef01"},
+			},
+		},
+	}
+	if err := r.maintainSBOMConfigmap(mch); err != nil {
+		t.Fatalf("maintainSBOMConfigmap() error = %v", err)
+	}
+
+	configmap := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "mch-sbom-2.5.0", Namespace: "test"}, configmap); err != nil {
+		t.Fatalf("failed to get sbom configmap: %v", err)
+	}
+	bom := configmap.Data[sbomConfigmapKey]
+	if !strings.Contains(bom, "https://github.com/stolostron/console") {
+		t.Errorf("bill of materials does not record the source of an image with the manifest digest: %s", bom)
+	}
+	if strings.Contains(bom, "https://github.com/stolostron/search-ui") {
+		t.Errorf("bill of materials records the source of an image with another digest: %s", bom)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/rendering"
 	"github.com/stolostron/multiclusterhub-operator/pkg/version"
 	subhelmv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
 
@@ -69,6 +70,57 @@ const (
 	ResourceRenderReason = "FailedRenderingResource"
 	// CRDRenderReason is added when an error occurs while rendering a CRD
 	CRDRenderReason = "FailedRenderingCRD"
+	// DeprecatedFieldsSetReason is added when the multiclusterhub sets deprecated fields or annotations
+	DeprecatedFieldsSetReason = "DeprecatedFieldsSet"
+	// DependencyDisabledReason is added when an enabled component is not installed because a
+	// component it depends on is disabled
+	DependencyDisabledReason = "DependencyDisabled"
+	// UnknownImageKeysReason is added when image overrides target image keys that are not in the image manifest
+	UnknownImageKeysReason = "UnknownImageKeys"
+	// InvalidImageOverridesReason is added when image override configmaps or entries could not be applied
+	InvalidImageOverridesReason = "InvalidImageOverrides"
+	// ImagesUnavailableReason is added when the image preflight finds images missing from their registry
+	ImagesUnavailableReason = "ImagesUnavailable"
+	// MutableImageReferencesReason is added when image references are not pinned by digest as the image
+	// digest policy requires
+	MutableImageReferencesReason = "MutableImageReferences"
+	// InvalidImageManifestReason is added when the image manifest does not match its schema
+	InvalidImageManifestReason = "InvalidImageManifest"
+	// ImageVerificationFailedReason is added when image references fail signature or digest verification
+	ImageVerificationFailedReason = "ImageVerificationFailed"
+	// IncompatibleImageReason is added when a component image cannot be rolled out with the configuration
+	// of this operator
+	IncompatibleImageReason = "IncompatibleImage"
+	// ChartVerificationFailedReason is added when the helm repo does not serve a subscribed chart at the
+	// expected version and digest
+	ChartVerificationFailedReason = "ChartVerificationFailed"
+	// ChartManifestMissingReason is added when the operator ships no chart manifest for its release, so that
+	// charts are only checked for their version
+	ChartManifestMissingReason = "ChartManifestMissing"
+	// ChartVerificationPendingReason is added while subscribed charts wait for the helm repo to serve its
+	// index
+	ChartVerificationPendingReason = "ChartVerificationPending"
+	// ComponentPausedReason is added for a component whose management state is Unmanaged
+	ComponentPausedReason = "Paused"
+	// RolloutWaveProgressingReason is added while an upgrade waits for a rollout wave to become available
+	RolloutWaveProgressingReason = "RolloutWaveProgressing"
+	// RolloutWaveFailedReason is added when a component of a rollout wave fails, which stops the upgrade
+	RolloutWaveFailedReason = "RolloutWaveFailed"
+	// UpgradeDeadlineExceededReason is added when components are not available by the rollback deadline of
+	// an upgrade, which is rolled back
+	UpgradeDeadlineExceededReason = "UpgradeDeadlineExceeded"
+	// RollbackUnavailableReason is added when an upgrade past its deadline cannot be rolled back because the
+	// images of the previous release are unknown
+	RollbackUnavailableReason = "RollbackUnavailable"
+	// MCEImagesUnknownReason is added when registry mirror rules wait for the images of the multicluster
+	// engine to be known
+	MCEImagesUnknownReason = "MultiClusterEngineImagesUnknown"
+	// MCEMirrorsUnsupportedReason is added when the multicluster engine cannot be given the registry mirror
+	// rules for its images
+	MCEMirrorsUnsupportedReason = "MultiClusterEngineMirrorsUnsupported"
+	// ImagesNotPinnedReason is added when a component deploys images the operator cannot pin by digest and
+	// the image digest policy requires them pinned
+	ImagesNotPinnedReason = "ImagesNotPinned"
 )
 
 func newComponentList(m *operatorsv1.MultiClusterHub) map[string]operatorsv1.StatusCondition {
@@ -95,6 +147,17 @@ var unmanagedStatus = operatorsv1.StatusCondition{
 	Available:          true,
 }
 
+// pausedStatus is reported for a component the operator leaves as it is. It does not hold back the hub.
+var pausedStatus = operatorsv1.StatusCondition{
+	Type:               "Unmanaged",
+	Status:             metav1.ConditionUnknown,
+	LastUpdateTime:     metav1.Now(),
+	LastTransitionTime: metav1.Now(),
+	Reason:             ComponentPausedReason,
+	Message:            "Component is paused: the operator neither applies nor removes it",
+	Available:          true,
+}
+
 var unknownStatus = operatorsv1.StatusCondition{
 	Type:               "Unknown",
 	Status:             metav1.ConditionUnknown,
@@ -121,7 +184,7 @@ func (r *MultiClusterHubReconciler) ComponentsAreRunning(m *operatorsv1.MultiClu
 	deployList, _ := r.listDeployments(trackedNamespaces)
 	hrList, _ := r.listHelmReleases(trackedNamespaces)
 	crList, _ := r.listCustomResources(m)
-	componentStatuses := getComponentStatuses(m, hrList, deployList, crList, nil)
+	componentStatuses := getComponentStatuses(m, hrList, deployList, crList, nil, r.heldComponents())
 	delete(componentStatuses, ManagedClusterName)
 	return allComponentsSuccessful(componentStatuses)
 }
@@ -129,7 +192,7 @@ func (r *MultiClusterHubReconciler) ComponentsAreRunning(m *operatorsv1.MultiClu
 // syncHubStatus checks if the status is up-to-date and sync it if necessary
 func (r *MultiClusterHubReconciler) syncHubStatus(m *operatorsv1.MultiClusterHub, original *operatorsv1.MultiClusterHubStatus, allDeps []*appsv1.Deployment, allHRs []*subhelmv1.HelmRelease, allCRs []*unstructured.Unstructured) (reconcile.Result, error) {
 	localCluster, err := r.ensureManagedClusterIsRunning(m)
-	newStatus := calculateStatus(m, allDeps, allHRs, allCRs, localCluster)
+	newStatus := calculateStatus(m, allDeps, allHRs, allCRs, localCluster, r.heldComponents())
 	if reflect.DeepEqual(m.Status, original) {
 		r.Log.Info("Status hasn't changed")
 		return reconcile.Result{}, nil
@@ -156,17 +219,18 @@ func (r *MultiClusterHubReconciler) syncHubStatus(m *operatorsv1.MultiClusterHub
 	}
 }
 
-func calculateStatus(hub *operatorsv1.MultiClusterHub, allDeps []*appsv1.Deployment, allHRs []*subhelmv1.HelmRelease, allCRs []*unstructured.Unstructured, importClusterStatus []interface{}) operatorsv1.MultiClusterHubStatus {
-	components := getComponentStatuses(hub, allHRs, allDeps, allCRs, importClusterStatus)
+func calculateStatus(hub *operatorsv1.MultiClusterHub, allDeps []*appsv1.Deployment, allHRs []*subhelmv1.HelmRelease, allCRs []*unstructured.Unstructured, importClusterStatus []interface{}, held map[string]operatorsv1.StatusCondition) operatorsv1.MultiClusterHubStatus {
+	components := getComponentStatuses(hub, allHRs, allDeps, allCRs, importClusterStatus, held)
 	status := operatorsv1.MultiClusterHubStatus{
-		CurrentVersion: hub.Status.CurrentVersion,
-		DesiredVersion: version.Version,
-		Components:     components,
+		CurrentVersion:    hub.Status.CurrentVersion,
+		DesiredVersion:    version.Version,
+		RolledBackVersion: hub.Status.RolledBackVersion,
+		Components:        components,
 	}
 
-	// Set current version
+	// Set current version. Components rolled back to the current version do not complete the upgrade.
 	successful := allComponentsSuccessful(components)
-	if successful {
+	if successful && !rolledBack(hub) {
 		status.CurrentVersion = version.Version
 	}
 
@@ -248,8 +312,9 @@ func filterDuplicateHRs(allHRs []*subhelmv1.HelmRelease) []*subhelmv1.HelmReleas
 	return list
 }
 
-// getComponentStatuses populates a complete list of the hub component statuses
-func getComponentStatuses(hub *operatorsv1.MultiClusterHub, allHRs []*subhelmv1.HelmRelease, allDeps []*appsv1.Deployment, allCRs []*unstructured.Unstructured, importClusterStatus []interface{}) map[string]operatorsv1.StatusCondition {
+// getComponentStatuses populates a complete list of the hub component statuses. held reports the status of
+// enabled components that are held back before rollout, such as by the image preflight.
+func getComponentStatuses(hub *operatorsv1.MultiClusterHub, allHRs []*subhelmv1.HelmRelease, allDeps []*appsv1.Deployment, allCRs []*unstructured.Unstructured, importClusterStatus []interface{}, held map[string]operatorsv1.StatusCondition) map[string]operatorsv1.StatusCondition {
 	components := newComponentList(hub)
 
 	filteredHRs := filterDuplicateHRs(allHRs)
@@ -263,7 +328,7 @@ func getComponentStatuses(hub *operatorsv1.MultiClusterHub, allHRs []*subhelmv1.
 		appsub := owners[0].Name
 
 		if _, ok := components[appsub]; ok {
-			components[appsub] = mapHelmRelease(hr)
+			components[appsub] = mapHelmRelease(hr, releaseVersion(hub))
 
 			// If helmrelease is labeled successful, check its deployments for readiness
 			if successfulHelmRelease(hr) {
@@ -279,6 +344,15 @@ func getComponentStatuses(hub *operatorsv1.MultiClusterHub, allHRs []*subhelmv1.
 		}
 	}
 
+	// Without helmreleases, a component installed by the Helm install engine is as ready as its deployments
+	if hub.InstallEngine() == operatorsv1.InstallEngineHelm {
+		for _, s := range utils.GetAppsubsForStatus(hub) {
+			if deps := filterDeploymentsByRelease(allDeps, s.Name); len(deps) > 0 {
+				components[s.Name] = mapReleaseDeployments(deps, releaseVersion(hub))
+			}
+		}
+	}
+
 	for _, d := range allDeps {
 		if _, ok := components[d.Name]; ok {
 			components[d.Name] = mapDeployment(d)
@@ -311,9 +385,133 @@ func getComponentStatuses(hub *operatorsv1.MultiClusterHub, allHRs []*subhelmv1.
 	if !hub.Spec.DisableHubSelfManagement {
 		components["local-cluster"] = mapManagedClusterConditions(importClusterStatus)
 	}
+
+	for _, c := range operatorsv1.ComponentInstallOrder() {
+		if hub.Unmanaged(c) {
+			components[c] = pausedStatus
+			continue
+		}
+		// multicluster-engine is installed regardless of its toggle
+		if !hub.Enabled(c) && c != operatorsv1.MultiClusterEngine {
+			continue
+		}
+		if disabled := hub.DisabledDependencies(c); len(disabled) > 0 {
+			components[c] = blockedByDependencyStatus(disabled)
+		} else if status, ok := held[c]; ok {
+			components[c] = status
+		}
+	}
 	return components
 }
 
+// blockedByDependencyStatus is reported for an enabled component that is not installed because
+// one or more of its dependencies are disabled
+func blockedByDependencyStatus(disabled []string) operatorsv1.StatusCondition {
+	return operatorsv1.StatusCondition{
+		Type:               "Blocked",
+		Status:             metav1.ConditionFalse,
+		LastUpdateTime:     metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             DependencyDisabledReason,
+		Message:            fmt.Sprintf("Component requires disabled dependencies: %s", strings.Join(disabled, ", ")),
+		Available:          false,
+	}
+}
+
+// imagesUnavailableStatus is reported for an enabled component that is not rolled out because the
+// image preflight could not find some of its images
+func imagesUnavailableStatus(missing []string) operatorsv1.StatusCondition {
+	return operatorsv1.StatusCondition{
+		Type:               "Blocked",
+		Status:             metav1.ConditionFalse,
+		LastUpdateTime:     metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             ImagesUnavailableReason,
+		Message:            fmt.Sprintf("Images not found in their registry: %s", strings.Join(missing, "; ")),
+		Available:          false,
+	}
+}
+
+// chartVerificationFailedStatus is reported for an enabled component that is not rolled out because the
+// helm repo does not serve its chart as released
+func chartVerificationFailedStatus(problem string) operatorsv1.StatusCondition {
+	return operatorsv1.StatusCondition{
+		Type:               "Blocked",
+		Status:             metav1.ConditionFalse,
+		LastUpdateTime:     metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             ChartVerificationFailedReason,
+		Message:            fmt.Sprintf("Chart failed verification: %s", problem),
+		Available:          false,
+	}
+}
+
+// chartVerificationPendingStatus is reported for an enabled component that is not rolled out yet because
+// its chart cannot be verified until the helm repo serves its index
+func chartVerificationPendingStatus(problem string) operatorsv1.StatusCondition {
+	return operatorsv1.StatusCondition{
+		Type:               "Progressing",
+		Status:             metav1.ConditionTrue,
+		LastUpdateTime:     metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             ChartVerificationPendingReason,
+		Message:            fmt.Sprintf("Chart waiting for verification: %s", problem),
+		Available:          false,
+	}
+}
+
+// incompatibleImageStatus is reported for an enabled component that is not rolled out because its image
+// cannot be run with the configuration of this operator
+func incompatibleImageStatus(problem string) operatorsv1.StatusCondition {
+	return operatorsv1.StatusCondition{
+		Type:               "Blocked",
+		Status:             metav1.ConditionFalse,
+		LastUpdateTime:     metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             IncompatibleImageReason,
+		Message:            fmt.Sprintf("Image incompatible with the operator: %s", problem),
+		Available:          false,
+	}
+}
+
+// imagesNotPinnedStatus is reported for an enabled component that is not rolled out because it deploys
+// images that are not pinned by digest as the image digest policy requires
+func imagesNotPinnedStatus(tagged []string) operatorsv1.StatusCondition {
+	return operatorsv1.StatusCondition{
+		Type:               "Blocked",
+		Status:             metav1.ConditionFalse,
+		LastUpdateTime:     metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             ImagesNotPinnedReason,
+		Message:            fmt.Sprintf("Images not pinned by digest: %s", strings.Join(tagged, ", ")),
+		Available:          false,
+	}
+}
+
+// heldComponents returns the status of the components held back before rollout by the image preflight,
+// an incompatible image, images not pinned by digest or the chart verification
+func (r *MultiClusterHubReconciler) heldComponents() map[string]operatorsv1.StatusCondition {
+	held := map[string]operatorsv1.StatusCondition{}
+	for c, problem := range r.CacheSpec.PendingCharts {
+		held[c] = chartVerificationPendingStatus(problem)
+	}
+	for c, problem := range r.CacheSpec.ChartProblems {
+		held[c] = chartVerificationFailedStatus(problem)
+	}
+	for c, problem := range r.CacheSpec.IncompatibleImages {
+		held[c] = incompatibleImageStatus(problem)
+	}
+	for c, tagged := range r.CacheSpec.UnpinnedImages {
+		held[c] = imagesNotPinnedStatus(tagged)
+	}
+	for c, missing := range r.CacheSpec.UnavailableImages {
+		if len(missing) > 0 {
+			held[c] = imagesUnavailableStatus(missing)
+		}
+	}
+	return held
+}
+
 func successfulDeploy(d *appsv1.Deployment) bool {
 	for _, c := range d.Status.Conditions {
 		if c.Type == appsv1.DeploymentAvailable && c.Status == corev1.ConditionFalse {
@@ -608,7 +806,9 @@ func latestHelmReleaseCondition(conditions []subhelmv1.HelmAppCondition) subhelm
 	return latest
 }
 
-func mapHelmRelease(hr *subhelmv1.HelmRelease) operatorsv1.StatusCondition {
+// mapHelmRelease returns the status of a helmrelease, which is available once deployed at the expected
+// chart version
+func mapHelmRelease(hr *subhelmv1.HelmRelease, want string) operatorsv1.StatusCondition {
 	if len(hr.Status.Conditions) < 1 {
 		return unknownStatus
 	}
@@ -633,17 +833,49 @@ func mapHelmRelease(hr *subhelmv1.HelmRelease) operatorsv1.StatusCondition {
 		ret.Message = ""
 
 		// Check if using desired chart version
-		if v := hr.Repo.Version; v != version.Version {
+		if v := hr.Repo.Version; v != want {
 			ret = wrongVersionStatus
-			ret.Message = fmt.Sprintf("expected version `%s`, current version is `%s`", version.Version, v)
+			ret.Message = fmt.Sprintf("expected version `%s`, current version is `%s`", want, v)
 		}
 	}
 
 	return ret
 }
 
+// mapReleaseDeployments reports the first deployment of a release that is not ready or not rendered from
+// the chart of the expected version, or the first deployment if all are ready
+func mapReleaseDeployments(deps []*appsv1.Deployment, want string) operatorsv1.StatusCondition {
+	for _, d := range deps {
+		if v := d.GetAnnotations()[rendering.ChartVersionAnnotation]; v != want {
+			ret := wrongVersionStatus
+			ret.Message = fmt.Sprintf("expected version `%s`, current version is `%s`", want, v)
+			return ret
+		}
+		if !successfulDeploy(d) {
+			return mapDeployment(d)
+		}
+	}
+	return mapDeployment(deps[0])
+}
+
 func successfulComponent(sc operatorsv1.StatusCondition) bool { return sc.Available }
 
+// failedComponent returns true if the status reports a component that failed to roll out, rather than
+// one that is still rolling out
+func failedComponent(sc operatorsv1.StatusCondition) bool {
+	if sc.Available {
+		return false
+	}
+	switch sc.Kind {
+	case "HelmRelease":
+		return sc.Type == string(subhelmv1.ConditionReleaseFailed) || sc.Type == string(subhelmv1.ConditionIrreconcilable)
+	case "Deployment":
+		return (sc.Type == string(appsv1.DeploymentProgressing) && sc.Status == metav1.ConditionFalse) ||
+			(sc.Type == string(appsv1.DeploymentReplicaFailure) && sc.Status == metav1.ConditionTrue)
+	}
+	return false
+}
+
 // allComponentsSuccessful returns true if all components are successful, otherwise false
 func allComponentsSuccessful(components map[string]operatorsv1.StatusCondition) bool {
 	for _, val := range components {
@@ -661,6 +893,10 @@ func aggregatePhase(status operatorsv1.MultiClusterHubStatus) operatorsv1.HubPha
 	if utils.IsUnitTest() {
 		return operatorsv1.HubRunning
 	}
+	if status.RolledBackVersion == version.Version && status.CurrentVersion != version.Version {
+		// Components run the release an upgrade was rolled back to
+		return operatorsv1.HubUpgradeRolledBack
+	}
 	if successful {
 		if hubPruning(status) {
 			// hub is in pruning phase
@@ -716,6 +952,19 @@ func SetHubCondition(status *operatorsv1.MultiClusterHubStatus, condition operat
 	status.HubConditions = append(newConditions, condition)
 }
 
+// SetHubConditionMessage sets the status condition like SetHubCondition, but also replaces the message of a
+// condition whose status and reason are unchanged. It is meant for conditions listing what is currently
+// wrong, whose message changes only when that list does.
+func SetHubConditionMessage(status *operatorsv1.MultiClusterHubStatus, condition operatorsv1.HubCondition) {
+	if currentCond := GetHubCondition(*status, condition.Type); currentCond != nil && currentCond.Message != condition.Message {
+		RemoveHubCondition(status, condition.Type)
+		if currentCond.Status == condition.Status {
+			condition.LastTransitionTime = currentCond.LastTransitionTime
+		}
+	}
+	SetHubCondition(status, condition)
+}
+
 // RemoveCRDCondition removes the status condition.
 func RemoveHubCondition(status *operatorsv1.MultiClusterHubStatus, condType operatorsv1.HubConditionType) {
 	status.HubConditions = filterOutCondition(status.HubConditions, condType)
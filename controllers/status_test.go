@@ -223,6 +223,39 @@ func TestSetHubCondition(t *testing.T) {
 			t.Errorf("AddCondition() expected lastTransitionTime of %v, got %v", old2.LastTransitionTime, ltt)
 		}
 	})
+
+	t.Run("Keep message with same reason", func(t *testing.T) {
+		m := &operatorsv1.MultiClusterHub{}
+		SetHubCondition(&m.Status, old2)
+		updated := old2
+		updated.Message = "updated message"
+		SetHubCondition(&m.Status, updated)
+		if got := m.Status.HubConditions[0].Message; got != old2.Message {
+			t.Errorf("AddCondition() expected message %q, got %q", old2.Message, got)
+		}
+	})
+}
+
+func TestSetHubConditionMessage(t *testing.T) {
+	listed := operatorsv1.HubCondition{
+		Type:               operatorsv1.DeprecatedFieldsInUse,
+		Status:             metav1.ConditionTrue,
+		Reason:             DeprecatedFieldsSetReason,
+		Message:            "spec.hive is deprecated",
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	m := &operatorsv1.MultiClusterHub{}
+	SetHubConditionMessage(&m.Status, listed)
+	updated := listed
+	updated.Message = "spec.hive is deprecated; spec.enableClusterBackup is deprecated"
+	updated.LastTransitionTime = metav1.Now()
+	SetHubConditionMessage(&m.Status, updated)
+	if len(m.Status.HubConditions) != 1 {
+		t.Fatalf("SetHubConditionMessage() expected 1 hub condition, got %d", len(m.Status.HubConditions))
+	}
+	if got := m.Status.HubConditions[0]; got.Message != updated.Message || !got.LastTransitionTime.Equal(&listed.LastTransitionTime) {
+		t.Errorf("SetHubConditionMessage() = %+v, want the new message and the old lastTransitionTime", got)
+	}
 }
 
 func TestGetHubCondition(t *testing.T) {
@@ -430,6 +463,17 @@ func Test_aggregatePhase(t *testing.T) {
 			},
 			want: operatorsv1.HubUpdating,
 		},
+		{
+			name: "Hub with a rolled back upgrade",
+			status: operatorsv1.MultiClusterHubStatus{
+				CurrentVersion:    "1.0.0",
+				RolledBackVersion: version.Version,
+				Components: map[string]operatorsv1.StatusCondition{
+					"foo": available,
+				},
+			},
+			want: operatorsv1.HubUpgradeRolledBack,
+		},
 		{
 			name: "Progressing hub with current version",
 			status: operatorsv1.MultiClusterHubStatus{
@@ -469,3 +513,48 @@ func Test_aggregatePhase(t *testing.T) {
 		})
 	}
 }
+
+func Test_getComponentStatuses_blockedByDependency(t *testing.T) {
+	hub := &operatorsv1.MultiClusterHub{
+		Spec: operatorsv1.MultiClusterHubSpec{DisableHubSelfManagement: true},
+	}
+	hub.Enable(operatorsv1.Repo)
+	hub.Enable(operatorsv1.Console)
+	hub.Disable(operatorsv1.ManagementIngress)
+
+	components := getComponentStatuses(hub, nil, nil, nil, nil, nil)
+
+	console, ok := components[operatorsv1.Console]
+	if !ok {
+		t.Fatalf("getComponentStatuses() did not report %s as blocked", operatorsv1.Console)
+	}
+	if console.Available || console.Reason != DependencyDisabledReason {
+		t.Errorf("getComponentStatuses() %s status = %+v, want unavailable with reason %s", operatorsv1.Console, console, DependencyDisabledReason)
+	}
+	if repo := components[operatorsv1.Repo]; repo.Reason == DependencyDisabledReason {
+		t.Errorf("getComponentStatuses() reported %s as blocked", operatorsv1.Repo)
+	}
+	if allComponentsSuccessful(components) {
+		t.Error("allComponentsSuccessful() = true with a blocked component")
+	}
+}
+
+func Test_getComponentStatuses_paused(t *testing.T) {
+	hub := &operatorsv1.MultiClusterHub{
+		Spec: operatorsv1.MultiClusterHubSpec{DisableHubSelfManagement: true, Overrides: &operatorsv1.Overrides{
+			Components: []operatorsv1.ComponentConfig{
+				{Name: operatorsv1.Repo, Enabled: true},
+				{Name: operatorsv1.Search, Enabled: true, ManagementState: operatorsv1.ManagementStateUnmanaged},
+			},
+		}},
+	}
+
+	components := getComponentStatuses(hub, nil, nil, nil, nil, nil)
+	search := components[operatorsv1.Search]
+	if search.Type != "Unmanaged" || search.Reason != ComponentPausedReason || !search.Available {
+		t.Errorf("getComponentStatuses() %s status = %+v, want Unmanaged/Paused", operatorsv1.Search, search)
+	}
+	if _, ok := components["search-prod-sub"]; ok {
+		t.Errorf("getComponentStatuses() tracks the appsub of a paused component")
+	}
+}
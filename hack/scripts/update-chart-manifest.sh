@@ -0,0 +1,54 @@
+#!/bin/bash
+# Copyright Contributors to the Open Cluster Management project
+
+# Writes the chart manifest of the release from the charts packaged in the helm repo image listed in the
+# image manifest. Run after update-image-manifest.sh so both files describe the same snapshot.
+
+# Where the helm repo image keeps its packaged charts
+HELM_REPO_CHARTS_PATH=${HELM_REPO_CHARTS_PATH:-/usr/src/app/multiclusterhub/charts}
+
+# Full version
+VERSION=$(cat COMPONENT_VERSION 2> /dev/null)
+if [ -z "${VERSION}" ]; then
+  echo "VERSION is unset or set to the empty string"
+  exit 1
+fi
+
+IMAGE_MANIFEST="bin/image-manifests/${VERSION}.json"
+if [ ! -f ${IMAGE_MANIFEST} ]; then
+    echo "File ${IMAGE_MANIFEST} not found!"
+    exit 1
+fi
+
+# Image manifests are either a bare list of images or an object holding them
+HELM_REPO_IMAGE=$(jq -r '(if type == "array" then . else .images end)[]
+  | select(."image-key" == "multiclusterhub_repo")
+  | if ."image-digest" then "\(."image-remote")/\(."image-name")@\(."image-digest")"
+    else "\(."image-remote")/\(."image-name"):\(."image-tag")" end' ${IMAGE_MANIFEST})
+if [ -z "${HELM_REPO_IMAGE}" ]; then
+  echo "No multiclusterhub_repo image listed in ${IMAGE_MANIFEST}"
+  exit 1
+fi
+
+echo "Using helm repo image ${HELM_REPO_IMAGE}"
+
+# Remove existing files
+rm -rf charts-temp
+mkdir -p charts-temp
+
+# Copy the packaged charts out of the image
+CONTAINER=$(docker create ${HELM_REPO_IMAGE}) || { rm -rf charts-temp; exit 1; }
+docker cp ${CONTAINER}:${HELM_REPO_CHARTS_PATH}/. charts-temp
+STATUS=$?
+docker rm ${CONTAINER} > /dev/null
+if [ ${STATUS} -ne 0 ]; then
+  rm -rf charts-temp
+  exit 1
+fi
+
+go run ./cmd/chart-manifest --charts charts-temp --output bin/image-manifests/${VERSION}-charts.json
+STATUS=$?
+
+# Delete charts directory
+rm -rf charts-temp
+exit ${STATUS}
@@ -38,10 +38,13 @@ import (
 	consolev1 "github.com/openshift/api/operator/v1"
 	operatorv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
 	"github.com/stolostron/multiclusterhub-operator/controllers"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
 	"github.com/stolostron/multiclusterhub-operator/pkg/webhook"
 	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	appsubv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis"
@@ -100,6 +103,11 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := version.Validate(); err != nil {
+		setupLog.Error(err, "unable to determine operator version")
+		os.Exit(1)
+	}
+
 	ns, err := getOperatorNamespace()
 	if err != nil {
 		setupLog.Error(err, "failed to get operator namespace")
@@ -120,10 +128,23 @@ func main() {
 		os.Exit(1)
 	}
 
+	verificationPolicy, err := manifest.LoadVerificationPolicy()
+	if err != nil {
+		setupLog.Error(err, "unable to load image manifest verification policy")
+		os.Exit(1)
+	}
+
+	// Report a broken image manifest early. The reconciler keeps reporting it in the status until it is fixed.
+	if _, err := manifest.GetManifestImages(); manifest.IsManifestError(err) {
+		setupLog.Error(err, "image manifest does not match its schema, components will not be rolled out")
+	}
+
 	if err = (&controllers.MultiClusterHubReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    ctrl.Log.WithName("Controller").WithName("Multiclusterhub"),
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		Log:                ctrl.Log.WithName("Controller").WithName("Multiclusterhub"),
+		VerificationPolicy: verificationPolicy,
+		Discovery:          discovery.NewDiscoveryClientForConfigOrDie(mgr.GetConfig()),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MultiClusterHub")
 		os.Exit(1)
@@ -4,7 +4,8 @@
 package channel
 
 import (
-	"fmt"
+	"path"
+	"reflect"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -21,22 +22,80 @@ var ChannelName = "charts-v1"
 // Schema is the GVK for an application subscription channel
 var Schema = schema.GroupVersionResource{Group: "apps.open-cluster-management.io", Version: "v1", Resource: "channels"}
 
-// custom annotation to reduce reconcilation rate to once per hour (default is 15 minutes)
-var AnnotationRateLow = map[string]string{
-	"apps.open-cluster-management.io/reconcile-rate": "low",
-}
+// AnnotationReconcileRate sets how often the subscription operator reconciles charts, on a channel or,
+// to turn reconciliation off, on a subscription
+const AnnotationReconcileRate = "apps.open-cluster-management.io/reconcile-rate"
+
+// Annotations the subscription operator reads from subscriptions to Git channels
+const (
+	AnnotationGitPath   = "apps.open-cluster-management.io/git-path"
+	AnnotationGitBranch = "apps.open-cluster-management.io/git-branch"
+)
+
+// SubscriptionAnnotationKeys are the subscription annotations that depend on the chart source and the
+// reconcile settings
+var SubscriptionAnnotationKeys = []string{AnnotationGitPath, AnnotationGitBranch, AnnotationReconcileRate}
+
+// specKeys are the channel spec fields owned by the operator
+var specKeys = []string{"type", "pathname", "configMapRef", "secretRef"}
+
+// channelSpec points the channel at the chart source. The bundled helm repo is reached over TLS with the
+// repo credentials; other sources use the secret and configmap named in the chart source, if any.
+func channelSpec(m *operatorsv1.MultiClusterHub) map[string]interface{} {
+	if m.ChartSourceType() == operatorsv1.ChartSourceHelmRepo {
+		return map[string]interface{}{
+			"type":     "HelmRepo",
+			"pathname": helmrepo.URL(m),
+			"configMapRef": map[string]interface{}{
+				"name": helmrepo.CAConfigMapName,
+			},
+			"secretRef": map[string]interface{}{
+				"name": helmrepo.AuthSecretName,
+			},
+		}
+	}
 
-// custom annotation to increase reconcilation rate to once every two minutes (default is 15 minutes)
-var AnnotationRateHigh = map[string]string{
-	"apps.open-cluster-management.io/reconcile-rate": "high",
+	source := m.Spec.ChartSource
+	spec := map[string]interface{}{
+		"pathname": source.URL,
+	}
+	switch source.Type {
+	case operatorsv1.ChartSourceGit:
+		spec["type"] = "Git"
+	default:
+		// The subscription operator pulls from OCI registries through HelmRepo channels with an oci:// pathname
+		spec["type"] = "HelmRepo"
+	}
+	if source.ConfigMapRef != "" {
+		spec["configMapRef"] = map[string]interface{}{"name": source.ConfigMapRef}
+	}
+	if source.SecretRef != "" {
+		spec["secretRef"] = map[string]interface{}{"name": source.SecretRef}
+	}
+	return spec
 }
 
-// build Helm pathname from repo name and por
-func channelURL(m *operatorsv1.MultiClusterHub) string {
-	return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d/charts", helmrepo.HelmRepoName, m.Namespace, helmrepo.Port)
+// SubscriptionAnnotations returns the annotations a subscription to the named chart needs to find it in
+// the chart source, the directory of the chart and the branch to follow for Git sources, and to follow the
+// subscription reconcile rate
+func SubscriptionAnnotations(m *operatorsv1.MultiClusterHub, chart string) map[string]string {
+	a := map[string]string{}
+	if rate := m.SubscriptionReconcileRate(); rate != "" {
+		a[AnnotationReconcileRate] = string(rate)
+	}
+	if m.ChartSourceType() == operatorsv1.ChartSourceGit {
+		a[AnnotationGitPath] = path.Join(m.Spec.ChartSource.Path, chart)
+		if m.Spec.ChartSource.Branch != "" {
+			a[AnnotationGitBranch] = m.Spec.ChartSource.Branch
+		}
+	}
+	if len(a) == 0 {
+		return nil
+	}
+	return a
 }
 
-// Channel returns an unstructured Channel object to watch the helm repository
+// Channel returns an unstructured Channel object to watch the chart source
 func Channel(m *operatorsv1.MultiClusterHub) *unstructured.Unstructured {
 	ch := &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -46,17 +105,10 @@ func Channel(m *operatorsv1.MultiClusterHub) *unstructured.Unstructured {
 				"name":      ChannelName,
 				"namespace": m.Namespace,
 			},
-			"spec": map[string]interface{}{
-				"type":     "HelmRepo",
-				"pathname": channelURL(m),
-			},
+			"spec": channelSpec(m),
 		},
 	}
-	if m.Status.CurrentVersion != version.Version {
-		ch.SetAnnotations(AnnotationRateHigh)
-	} else {
-		ch.SetAnnotations(AnnotationRateLow)
-	}
+	ch.SetAnnotations(map[string]string{AnnotationReconcileRate: desiredRate(m)})
 
 	ch.SetOwnerReferences([]metav1.OwnerReference{
 		*metav1.NewControllerRef(m, m.GetObjectKind().GroupVersionKind()),
@@ -74,12 +126,27 @@ func Validate(m *operatorsv1.MultiClusterHub, found *unstructured.Unstructured)
 		setAnnotation(m, found)
 		updateNeeded = true
 	}
+
+	// Verify the channel points at the chart source, dropping the fields of a previous source
+	desired := channelSpec(m)
+	for _, key := range specKeys {
+		current, exists, _ := unstructured.NestedFieldNoCopy(found.Object, "spec", key)
+		value, ok := desired[key]
+		switch {
+		case ok && !reflect.DeepEqual(current, value):
+			_ = unstructured.SetNestedField(found.Object, value, "spec", key)
+			updateNeeded = true
+		case !ok && exists:
+			unstructured.RemoveNestedField(found.Object, "spec", key)
+			updateNeeded = true
+		}
+	}
 	return found, updateNeeded
 }
 
 func annotationsCorrect(m *operatorsv1.MultiClusterHub, u *unstructured.Unstructured) bool {
 	a := u.GetAnnotations()
-	if a == nil || a["apps.open-cluster-management.io/reconcile-rate"] != desiredRate(m) {
+	if a == nil || a[AnnotationReconcileRate] != desiredRate(m) {
 		return false
 	}
 	return true
@@ -88,25 +155,17 @@ func annotationsCorrect(m *operatorsv1.MultiClusterHub, u *unstructured.Unstruct
 func setAnnotation(m *operatorsv1.MultiClusterHub, u *unstructured.Unstructured) {
 	a := u.GetAnnotations()
 	if a == nil {
-		u.SetAnnotations(desiredAnnotation(m))
-	} else {
-		a["apps.open-cluster-management.io/reconcile-rate"] = desiredRate(m)
-		u.SetAnnotations(a)
-	}
-}
-
-func desiredAnnotation(m *operatorsv1.MultiClusterHub) map[string]string {
-	if m.Status.CurrentVersion != version.Version {
-		return AnnotationRateHigh
-	} else {
-		return AnnotationRateLow
+		a = map[string]string{}
 	}
+	a[AnnotationReconcileRate] = desiredRate(m)
+	u.SetAnnotations(a)
 }
 
+// desiredRate returns the reconcile rate of the channel, raised by default while the hub upgrades so that
+// the charts of the new version roll out promptly
 func desiredRate(m *operatorsv1.MultiClusterHub) string {
 	if m.Status.CurrentVersion != version.Version {
-		return "high"
-	} else {
-		return "low"
+		return string(m.UpgradeReconcileRate())
 	}
+	return string(m.SteadyReconcileRate())
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
@@ -50,6 +51,11 @@ func TestValidate(t *testing.T) {
 		"apps.open-cluster-management.io/reconcile-rate": "high",
 	})
 
+	plainHTTPCurrent := current.DeepCopy()
+	plainHTTPCurrent.SetAnnotations(map[string]string{
+		"apps.open-cluster-management.io/reconcile-rate": "high",
+	})
+
 	tests := []struct {
 		name  string
 		found *unstructured.Unstructured
@@ -93,6 +99,12 @@ func TestValidate(t *testing.T) {
 			want:  wrongRateDesired,
 			want1: true,
 		},
+		{
+			name:  "Existing channel over plain HTTP",
+			found: plainHTTPCurrent,
+			want:  Channel(m),
+			want1: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -109,3 +121,125 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateChartSource(t *testing.T) {
+	bundled := &operatorsv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Namespace: "test"}}
+	oci := bundled.DeepCopy()
+	oci.Spec.ChartSource = &operatorsv1.ChartSource{
+		Type:      operatorsv1.ChartSourceOCI,
+		URL:       "oci://registry.example.com/charts",
+		SecretRef: "registry-creds",
+	}
+	git := bundled.DeepCopy()
+	git.Spec.ChartSource = &operatorsv1.ChartSource{
+		Type: operatorsv1.ChartSourceGit,
+		URL:  "https://git.example.com/acm/charts.git",
+	}
+
+	tests := []struct {
+		name string
+		from *operatorsv1.MultiClusterHub
+		to   *operatorsv1.MultiClusterHub
+		want map[string]interface{}
+	}{
+		{
+			name: "Bundled repo to OCI registry",
+			from: bundled,
+			to:   oci,
+			want: map[string]interface{}{
+				"type":      "HelmRepo",
+				"pathname":  "oci://registry.example.com/charts",
+				"secretRef": map[string]interface{}{"name": "registry-creds"},
+			},
+		},
+		{
+			name: "OCI registry to Git repository",
+			from: oci,
+			to:   git,
+			want: map[string]interface{}{
+				"type":     "Git",
+				"pathname": "https://git.example.com/acm/charts.git",
+			},
+		},
+		{
+			name: "Git repository to bundled repo",
+			from: git,
+			to:   bundled,
+			want: Channel(bundled).Object["spec"].(map[string]interface{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, needsUpdate := Validate(tt.to, Channel(tt.from))
+			if !needsUpdate {
+				t.Errorf("Validate() did not request an update")
+			}
+			if !reflect.DeepEqual(got.Object["spec"], tt.want) {
+				t.Errorf("Validate() spec got = %v, want %v", got.Object["spec"], tt.want)
+			}
+			if _, needsUpdate := Validate(tt.to, got); needsUpdate {
+				t.Errorf("Validate() requested an update of a reconciled channel")
+			}
+		})
+	}
+}
+
+func TestSubscriptionAnnotations(t *testing.T) {
+	m := &operatorsv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Namespace: "test"}}
+	if got := SubscriptionAnnotations(m, "grc"); got != nil {
+		t.Errorf("SubscriptionAnnotations() = %v for the bundled repo, want none", got)
+	}
+
+	m.Spec.ChartSource = &operatorsv1.ChartSource{Type: operatorsv1.ChartSourceGit, URL: "https://git.example.com/charts.git"}
+	want := map[string]string{AnnotationGitPath: "grc"}
+	if got := SubscriptionAnnotations(m, "grc"); !reflect.DeepEqual(got, want) {
+		t.Errorf("SubscriptionAnnotations() = %v, want %v", got, want)
+	}
+
+	m.Spec.ChartSource.Path = "stable"
+	m.Spec.ChartSource.Branch = "release-2.5"
+	want = map[string]string{AnnotationGitPath: "stable/grc", AnnotationGitBranch: "release-2.5"}
+	if got := SubscriptionAnnotations(m, "grc"); !reflect.DeepEqual(got, want) {
+		t.Errorf("SubscriptionAnnotations() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateReconcileRate(t *testing.T) {
+	m := &operatorsv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test"},
+		Spec: operatorsv1.MultiClusterHubSpec{
+			ChartReconcile: &operatorsv1.ChartReconcile{SteadyRate: operatorsv1.ReconcileRateMedium},
+		},
+	}
+
+	// Upgrades keep the high default rate
+	found := Channel(m)
+	if got := found.GetAnnotations()[AnnotationReconcileRate]; got != "high" {
+		t.Errorf("Channel() rate = %s during upgrade, want high", got)
+	}
+
+	// The steady rate applies once the hub runs the operator version
+	m.Status.CurrentVersion = version.Version
+	found.SetAnnotations(map[string]string{"foo": "bar", AnnotationReconcileRate: "high"})
+	got, needsUpdate := Validate(m, found)
+	if !needsUpdate || got.GetAnnotations()[AnnotationReconcileRate] != "medium" || got.GetAnnotations()["foo"] != "bar" {
+		t.Errorf("Validate() = %v, %v, want the medium rate", got.GetAnnotations(), needsUpdate)
+	}
+
+	m.Spec.ChartReconcile = nil
+	if got, _ := Validate(m, Channel(m)); got.GetAnnotations()[AnnotationReconcileRate] != "low" {
+		t.Errorf("Validate() rate = %s, want the low default", got.GetAnnotations()[AnnotationReconcileRate])
+	}
+}
+
+func TestSubscriptionReconcileRate(t *testing.T) {
+	m := &operatorsv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Namespace: "test"}}
+	if got := SubscriptionAnnotations(m, "grc"); got != nil {
+		t.Errorf("SubscriptionAnnotations() = %v, want none", got)
+	}
+	m.Spec.ChartReconcile = &operatorsv1.ChartReconcile{SubscriptionRate: operatorsv1.ReconcileRateOff}
+	want := map[string]string{AnnotationReconcileRate: "off"}
+	if got := SubscriptionAnnotations(m, "grc"); !reflect.DeepEqual(got, want) {
+		t.Errorf("SubscriptionAnnotations() = %v, want %v", got, want)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -50,7 +51,7 @@ func Image(overrides map[string]string) string {
 
 // Deployment for the helm repo serving charts
 func Deployment(m *operatorsv1.MultiClusterHub, overrides map[string]string) *appsv1.Deployment {
-	replicas := int32(1)
+	replicas := int32(utils.DefaultReplicaCount(m))
 
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -90,7 +91,7 @@ func Deployment(m *operatorsv1.MultiClusterHub, overrides map[string]string) *ap
 								HTTPGet: &corev1.HTTPGetAction{
 									Path:   "/liveness",
 									Port:   intstr.FromInt(Port),
-									Scheme: corev1.URISchemeHTTP,
+									Scheme: corev1.URISchemeHTTPS,
 								},
 							},
 						},
@@ -99,7 +100,7 @@ func Deployment(m *operatorsv1.MultiClusterHub, overrides map[string]string) *ap
 								HTTPGet: &corev1.HTTPGetAction{
 									Path:   "/readiness",
 									Port:   intstr.FromInt(Port),
-									Scheme: corev1.URISchemeHTTP,
+									Scheme: corev1.URISchemeHTTPS,
 								},
 							},
 						},
@@ -129,12 +130,43 @@ func Deployment(m *operatorsv1.MultiClusterHub, overrides map[string]string) *ap
 								Name:  "REPO_DIR",
 								Value: "/repo/charts",
 							},
+							{
+								Name:  "MCH_REPO_TLS_CERT_FILE",
+								Value: tlsMountPath + "/" + corev1.TLSCertKey,
+							},
+							{
+								Name:  "MCH_REPO_TLS_KEY_FILE",
+								Value: tlsMountPath + "/" + corev1.TLSPrivateKeyKey,
+							},
+							{
+								Name: "MCH_REPO_USERNAME",
+								ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{Name: AuthSecretName},
+										Key:                  "user",
+									},
+								},
+							},
+							{
+								Name: "MCH_REPO_PASSWORD",
+								ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{Name: AuthSecretName},
+										Key:                  "password",
+									},
+								},
+							},
 						},
 						VolumeMounts: []corev1.VolumeMount{
 							{
 								Name:      "repo-volume",
 								MountPath: "/repo/charts",
 							},
+							{
+								Name:      "repo-tls",
+								MountPath: tlsMountPath,
+								ReadOnly:  true,
+							},
 						},
 					}},
 					ImagePullSecrets: []corev1.LocalObjectReference{{Name: m.Spec.ImagePullSecret}},
@@ -148,6 +180,14 @@ func Deployment(m *operatorsv1.MultiClusterHub, overrides map[string]string) *ap
 								EmptyDir: &corev1.EmptyDirVolumeSource{},
 							},
 						},
+						{
+							Name: "repo-tls",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: TLSSecretName(m),
+								},
+							},
+						},
 					},
 					// ServiceAccountName: "default",
 				},
@@ -161,7 +201,25 @@ func Deployment(m *operatorsv1.MultiClusterHub, overrides map[string]string) *ap
 	return dep
 }
 
-// Service for the helm repo serving charts
+// SetChartVersion sets the release version of the charts the helm repo serves
+func SetChartVersion(dep *appsv1.Deployment, version string) {
+	for i := range dep.Spec.Template.Spec.Containers {
+		env := dep.Spec.Template.Spec.Containers[i].Env
+		for j := range env {
+			if env[j].Name == "CHART_VERSION" {
+				env[j].Value = version
+			}
+		}
+	}
+}
+
+// PodDisruptionBudget keeps a helm repo pod serving charts while nodes are drained
+func PodDisruptionBudget(m *operatorsv1.MultiClusterHub) *policyv1.PodDisruptionBudget {
+	return utils.PodDisruptionBudget(m, HelmRepoName, m.Namespace, &metav1.LabelSelector{MatchLabels: labels()})
+}
+
+// Service for the helm repo serving charts. Unless the multiclusterhub names its own certificate, the
+// OpenShift service CA issues the serving certificate of the service.
 func Service(m *operatorsv1.MultiClusterHub) *corev1.Service {
 	labels := selectorLabels()
 
@@ -180,6 +238,9 @@ func Service(m *operatorsv1.MultiClusterHub) *corev1.Service {
 			Type: corev1.ServiceTypeClusterIP,
 		},
 	}
+	if utils.GetHelmRepoTLSSecret(m) == "" {
+		s.SetAnnotations(map[string]string{AnnotationServingCertSecret: ServingCertSecretName})
+	}
 
 	s.SetOwnerReferences([]metav1.OwnerReference{
 		*metav1.NewControllerRef(m, m.GetObjectKind().GroupVersionKind()),
@@ -206,6 +267,14 @@ func ValidateDeployment(m *operatorsv1.MultiClusterHub, overrides map[string]str
 		}
 	}
 
+	// verify replica count
+	if expected.Spec.Replicas != nil && (found.Spec.Replicas == nil || *found.Spec.Replicas != *expected.Spec.Replicas) {
+		log.Info("Enforcing replica count from availabilityConfig")
+		replicas := *expected.Spec.Replicas
+		found.Spec.Replicas = &replicas
+		needsUpdate = true
+	}
+
 	// verify image repository and suffix
 	if container.Image != Image(overrides) {
 		log.Info("Enforcing image repo and suffix from CR spec")
@@ -247,6 +316,13 @@ func ValidateDeployment(m *operatorsv1.MultiClusterHub, overrides map[string]str
 		needsUpdate = true
 	}
 
+	// roll the pods when the serving certificate changes
+	if hash := expected.Spec.Template.Annotations[AnnotationCertificateHash]; hash != "" && hash != found.Spec.Template.Annotations[AnnotationCertificateHash] {
+		log.Info("Serving certificate changed, restarting pods")
+		SetCertificateHash(found, hash)
+		needsUpdate = true
+	}
+
 	if !reflect.DeepEqual(container.Args, utils.GetContainerArgs(expected)) {
 		log.Info("Enforcing container arguments")
 		args := utils.GetContainerArgs(expected)
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,6 +34,17 @@ func TestDeployment(t *testing.T) {
 	t.Run("MCH with only required values", func(t *testing.T) {
 		_ = Deployment(essentialsOnly, ovr)
 	})
+
+	t.Run("Replicas follow availabilityConfig", func(t *testing.T) {
+		if replicas := *Deployment(essentialsOnly, ovr).Spec.Replicas; replicas != 2 {
+			t.Errorf("expected 2 replicas by default, got %d", replicas)
+		}
+		basic := essentialsOnly.DeepCopy()
+		basic.Spec.AvailabilityConfig = operatorsv1.HABasic
+		if replicas := *Deployment(basic, ovr).Spec.Replicas; replicas != 1 {
+			t.Errorf("expected 1 replica with Basic availability, got %d", replicas)
+		}
+	})
 }
 
 func TestService(t *testing.T) {
@@ -51,6 +63,21 @@ func TestService(t *testing.T) {
 		if ref := s.GetOwnerReferences(); ref[0].Name != "testName" {
 			t.Errorf("expected ownerReference %s, got %s", "testName", ref[0].Name)
 		}
+		if name := s.Annotations[AnnotationServingCertSecret]; name != ServingCertSecretName {
+			t.Errorf("expected serving certificate secret %s, got %s", ServingCertSecretName, name)
+		}
+	})
+
+	t.Run("Create service with a self-managed certificate", func(t *testing.T) {
+		custom := mch.DeepCopy()
+		custom.SetAnnotations(map[string]string{utils.AnnotationHelmRepoTLSSecret: "custom-tls"})
+		if s := Service(custom); len(s.Annotations) != 0 {
+			t.Errorf("expected no service CA annotation, got %v", s.Annotations)
+		}
+		dep := Deployment(custom, map[string]string{})
+		if secret := dep.Spec.Template.Spec.Volumes[1].Secret.SecretName; secret != "custom-tls" {
+			t.Errorf("expected certificate volume from %s, got %s", "custom-tls", secret)
+		}
 	})
 }
 
@@ -68,6 +95,7 @@ func TestValidateDeployment(t *testing.T) {
 
 	// 1. Valid mch
 	dep := Deployment(mch, ovr)
+	SetCertificateHash(dep, "current")
 
 	// 2. Modified ImagePullSecret
 	dep1 := dep.DeepCopy()
@@ -97,6 +125,15 @@ func TestValidateDeployment(t *testing.T) {
 	dep7 := dep.DeepCopy()
 	dep7.Spec.Template.Labels = selectorLabels()
 
+	// 9. Rotated serving certificate
+	dep8 := dep.DeepCopy()
+	SetCertificateHash(dep8, "previous")
+
+	// 10. Modified replica count
+	dep9 := dep.DeepCopy()
+	one := int32(1)
+	dep9.Spec.Replicas = &one
+
 	type args struct {
 		m   *operatorsv1.MultiClusterHub
 		dep *appsv1.Deployment
@@ -155,6 +192,18 @@ func TestValidateDeployment(t *testing.T) {
 			want:  dep,
 			want1: true,
 		},
+		{
+			name:  "Rotated serving certificate",
+			args:  args{mch, dep8},
+			want:  dep,
+			want1: true,
+		},
+		{
+			name:  "Modified replica count",
+			args:  args{mch, dep9},
+			want:  dep,
+			want1: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -168,3 +217,11 @@ func TestValidateDeployment(t *testing.T) {
 		})
 	}
 }
+
+func TestImageSupportsTLS(t *testing.T) {
+	for version, want := range map[string]bool{"2.4": false, "2.4.3": false, "2.5": true, "2.5.1": true, "3.0.0": true, "latest": true} {
+		if got := ImageSupportsTLS(version); got != want {
+			t.Errorf("ImageSupportsTLS(%s) = %v, want %v", version, got, want)
+		}
+	}
+}
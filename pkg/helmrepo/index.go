@@ -0,0 +1,139 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package helmrepo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// maxIndexSize bounds the size of the repo index read by the operator
+	maxIndexSize = 8 << 20
+	// maxChartSize bounds the size of a packaged chart downloaded by the operator
+	maxChartSize = 16 << 20
+)
+
+// URL returns the address the helm repo serves charts from
+func URL(m *operatorsv1.MultiClusterHub) string {
+	return fmt.Sprintf("https://%s.%s.svc.cluster.local:%d/charts", HelmRepoName, m.Namespace, Port)
+}
+
+// ChartVersion is a chart release listed in the repo index
+type ChartVersion struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Digest  string   `json:"digest"`
+	URLs    []string `json:"urls"`
+}
+
+// IndexFile is the index.yaml of a helm repo
+type IndexFile struct {
+	Entries map[string][]ChartVersion `json:"entries"`
+}
+
+// FetchIndex downloads and parses the index.yaml served under repoURL, authenticating with basic auth
+func FetchIndex(ctx context.Context, httpClient *http.Client, repoURL, username, password string) (*IndexFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(repoURL, "/")+"/index.yaml", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(username, password)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response fetching the helm repo index: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxIndexSize))
+	if err != nil {
+		return nil, err
+	}
+	index := &IndexFile{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse the helm repo index: %w", err)
+	}
+	return index, nil
+}
+
+// Get returns the chart release listed at the given version
+func (idx *IndexFile) Get(name, version string) (*ChartVersion, error) {
+	for i, cv := range idx.Entries[name] {
+		if cv.Version == version {
+			return &idx.Entries[name][i], nil
+		}
+	}
+	return nil, fmt.Errorf("chart %s %s is not served by the helm repo", name, version)
+}
+
+// CheckChart returns an error if the index does not list the chart at the given version, or lists it with
+// another digest. An empty digest only checks the version.
+func (idx *IndexFile) CheckChart(name, version, digest string) error {
+	cv, err := idx.Get(name, version)
+	if err != nil {
+		return err
+	}
+	if digest != "" && trimDigestAlgorithm(cv.Digest) != trimDigestAlgorithm(digest) {
+		return fmt.Errorf("chart %s %s has digest %s, expected %s", name, version, cv.Digest, digest)
+	}
+	return nil
+}
+
+// FetchChart downloads the packaged chart of a chart release listed in the index served under repoURL,
+// checking it against the digest of the chart release
+func FetchChart(ctx context.Context, httpClient *http.Client, repoURL, username, password string, cv *ChartVersion) ([]byte, error) {
+	if len(cv.URLs) == 0 {
+		return nil, fmt.Errorf("chart %s %s has no download URL", cv.Name, cv.Version)
+	}
+	base, err := url.Parse(strings.TrimSuffix(repoURL, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(cv.URLs[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL for chart %s %s: %w", cv.Name, cv.Version, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.ResolveReference(ref).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(username, password)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response fetching chart %s %s: %s", cv.Name, cv.Version, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxChartSize))
+	if err != nil {
+		return nil, err
+	}
+	if cv.Digest != "" {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != trimDigestAlgorithm(cv.Digest) {
+			return nil, fmt.Errorf("chart %s %s does not match its digest %s", cv.Name, cv.Version, cv.Digest)
+		}
+	}
+	return data, nil
+}
+
+// trimDigestAlgorithm drops the sha256: prefix, which helm omits from index digests
+func trimDigestAlgorithm(digest string) string {
+	return strings.TrimPrefix(digest, "sha256:")
+}
@@ -0,0 +1,121 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package helmrepo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testIndex = `apiVersion: v1
+entries:
+  grc:
+  - name: grc
+    version: 2.5.0
+    digest: 0f1e2d
+    urls:
+    - grc-2.5.0.tgz
+  - name: grc
+    version: 2.4.0
+    digest: aabbcc
+    urls:
+    - grc-2.4.0.tgz
+`
+
+func TestFetchIndex(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != HelmRepoName || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/charts/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, testIndex)
+	}))
+	defer server.Close()
+
+	index, err := FetchIndex(context.TODO(), server.Client(), server.URL+"/charts", HelmRepoName, "secret")
+	if err != nil {
+		t.Fatalf("FetchIndex() error = %v", err)
+	}
+	if got := len(index.Entries["grc"]); got != 2 {
+		t.Errorf("FetchIndex() grc entries = %d, want 2", got)
+	}
+
+	if _, err := FetchIndex(context.TODO(), server.Client(), server.URL+"/charts", HelmRepoName, "wrong"); err == nil {
+		t.Errorf("FetchIndex() did not fail with wrong credentials")
+	}
+}
+
+func TestIndexFile_CheckChart(t *testing.T) {
+	index := &IndexFile{Entries: map[string][]ChartVersion{
+		"grc": {{Name: "grc", Version: "2.5.0", Digest: "0f1e2d"}},
+	}}
+
+	tests := []struct {
+		name    string
+		chart   string
+		version string
+		digest  string
+		wantErr bool
+	}{
+		{name: "Served version", chart: "grc", version: "2.5.0"},
+		{name: "Matching digest", chart: "grc", version: "2.5.0", digest: "sha256:0f1e2d"},
+		{name: "Mismatched digest", chart: "grc", version: "2.5.0", digest: "sha256:ffffff", wantErr: true},
+		{name: "Missing version", chart: "grc", version: "2.6.0", wantErr: true},
+		{name: "Missing chart", chart: "search-prod", version: "2.5.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := index.CheckChart(tt.chart, tt.version, tt.digest); (err != nil) != tt.wantErr {
+				t.Errorf("CheckChart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchChart(t *testing.T) {
+	archive := []byte("packaged chart")
+	sum := sha256.Sum256(archive)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, ok := r.BasicAuth(); !ok || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/charts/grc-2.5.0.tgz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		cv      ChartVersion
+		wantErr bool
+	}{
+		{name: "Relative URL", cv: ChartVersion{Name: "grc", Version: "2.5.0", Digest: hex.EncodeToString(sum[:]), URLs: []string{"grc-2.5.0.tgz"}}},
+		{name: "Absolute URL", cv: ChartVersion{Name: "grc", Version: "2.5.0", URLs: []string{server.URL + "/charts/grc-2.5.0.tgz"}}},
+		{name: "Mismatched digest", cv: ChartVersion{Name: "grc", Version: "2.5.0", Digest: "sha256:0f1e2d", URLs: []string{"grc-2.5.0.tgz"}}, wantErr: true},
+		{name: "No URL", cv: ChartVersion{Name: "grc", Version: "2.5.0"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := FetchChart(context.TODO(), server.Client(), server.URL+"/charts", HelmRepoName, "secret", &tt.cv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchChart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(data) != string(archive) {
+				t.Errorf("FetchChart() = %q, want %q", data, archive)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package helmrepo
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/Masterminds/semver"
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// ServingCertSecretName is the secret the OpenShift service CA fills with the serving certificate of the helm repo
+	ServingCertSecretName = "multiclusterhub-repo-tls"
+	// ServiceCAConfigMapName is the configmap the OpenShift service CA injects its CA bundle into
+	ServiceCAConfigMapName = "multiclusterhub-repo-service-ca"
+	// CAConfigMapName is the configmap referenced by the channel to trust the helm repo certificate
+	CAConfigMapName = "multiclusterhub-repo-ca"
+	// AuthSecretName is the secret holding the basic auth credentials of the helm repo
+	AuthSecretName = "multiclusterhub-repo-auth"
+	// AnnotationCertificateHash sits in the helm repo pod template to roll the pods when the certificate changes
+	AnnotationCertificateHash = "installer.open-cluster-management.io/repo-certificate-hash"
+	// AnnotationServingCertSecret asks the OpenShift service CA to issue the serving certificate of a service
+	AnnotationServingCertSecret = "service.beta.openshift.io/serving-cert-secret-name"
+	// MinimumTLSImageVersion is the first multiclusterhub-repo image version that serves charts over HTTPS
+	// with basic auth, configured by the MCH_REPO_TLS_* and MCH_REPO_USERNAME/PASSWORD variables
+	MinimumTLSImageVersion = "2.5"
+)
+
+const (
+	// ServiceCAKey is the configmap key the service CA injects its bundle under
+	ServiceCAKey = "service-ca.crt"
+	// CAKey is the key the channel reads trusted certificates from
+	CAKey = "caCerts"
+	// SecretCAKey is the key of the CA in a self-managed certificate secret
+	SecretCAKey = "ca.crt"
+
+	tlsMountPath = "/etc/repo-tls"
+)
+
+// TLSSecretName returns the secret holding the helm repo serving certificate. A secret named by the
+// multiclusterhub is used as is, otherwise the certificate is issued by the OpenShift service CA.
+func TLSSecretName(m *operatorsv1.MultiClusterHub) string {
+	if name := utils.GetHelmRepoTLSSecret(m); name != "" {
+		return name
+	}
+	return ServingCertSecretName
+}
+
+// ServiceCAConfigMap is filled with the OpenShift service CA bundle
+func ServiceCAConfigMap(m *operatorsv1.MultiClusterHub) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ServiceCAConfigMapName,
+			Namespace:   m.Namespace,
+			Labels:      labels(),
+			Annotations: map[string]string{"service.beta.openshift.io/inject-cabundle": "true"},
+		},
+	}
+	cm.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(m, m.GetObjectKind().GroupVersionKind()),
+	})
+	return cm
+}
+
+// CAConfigMap holds the CA bundle the channel trusts when fetching charts
+func CAConfigMap(m *operatorsv1.MultiClusterHub, ca string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CAConfigMapName,
+			Namespace: m.Namespace,
+			Labels:    labels(),
+		},
+		Data: map[string]string{CAKey: ca},
+	}
+	cm.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(m, m.GetObjectKind().GroupVersionKind()),
+	})
+	return cm
+}
+
+// AuthSecret holds newly generated basic auth credentials shared by the helm repo and the channel
+func AuthSecret(m *operatorsv1.MultiClusterHub) (*corev1.Secret, error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AuthSecretName,
+			Namespace: m.Namespace,
+			Labels:    labels(),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"user":     []byte(HelmRepoName),
+			"password": []byte(hex.EncodeToString(password)),
+		},
+	}
+	s.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(m, m.GetObjectKind().GroupVersionKind()),
+	})
+	return s, nil
+}
+
+// ImageSupportsTLS returns false if the multiclusterhub-repo image version predates MinimumTLSImageVersion.
+// Versions that do not parse, such as those of development builds, are assumed to support it.
+func ImageSupportsTLS(imageVersion string) bool {
+	v, err := semver.NewVersion(imageVersion)
+	if err != nil {
+		return true
+	}
+	return !v.LessThan(semver.MustParse(MinimumTLSImageVersion))
+}
+
+// CertificateHash identifies the serving certificate and key held by a TLS secret
+func CertificateHash(s *corev1.Secret) string {
+	h := sha256.New()
+	h.Write(s.Data[corev1.TLSCertKey])
+	h.Write(s.Data[corev1.TLSPrivateKeyKey])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SetCertificateHash records the serving certificate in the pod template so the pods restart and load
+// a rotated certificate. An empty hash leaves the template unchanged.
+func SetCertificateHash(dep *appsv1.Deployment, hash string) {
+	if hash == "" {
+		return
+	}
+	if dep.Spec.Template.Annotations == nil {
+		dep.Spec.Template.Annotations = map[string]string{}
+	}
+	dep.Spec.Template.Annotations[AnnotationCertificateHash] = hash
+}
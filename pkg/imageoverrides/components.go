@@ -0,0 +1,58 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package imageoverrides
+
+import (
+	"sort"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+)
+
+// defaultComponentImageKeys lists the image keys deployed on the hub by each component's chart or
+// deployment, for image manifests that do not record the components of their images. Charts receive
+// the whole image override map, so keys that are only used on managed clusters are not listed. The
+// multicluster-engine brings its own images.
+var defaultComponentImageKeys = map[string][]string{
+	operatorsv1.Repo:              {"multiclusterhub_repo"},
+	operatorsv1.ManagementIngress: {"management_ingress", "oauth_proxy"},
+	operatorsv1.Console:           {"console"},
+	operatorsv1.Insights:          {"insights_client", "insights_metrics"},
+	operatorsv1.GRC:               {"governance_policy_propagator", "grc_ui", "grc_ui_api"},
+	operatorsv1.ClusterLifecycle:  {"klusterlet_addon_controller", "clusterlifecycle_state_metrics"},
+	operatorsv1.Volsync:           {"volsync"},
+	operatorsv1.Search:            {"search_operator", "search_aggregator", "search_api", "search_collector", "search_ui", "redisgraph_tls"},
+	operatorsv1.ClusterBackup:     {"cluster_backup_controller"},
+	operatorsv1.ClusterProxyAddon: {"cluster_proxy_addon"},
+}
+
+// ComponentImageKeys returns, by component, the image keys the image manifest records as deployed on
+// the hub by that component. Manifests that record no components predate the field, and the keys of
+// the operator release are returned instead.
+func ComponentImageKeys(images []manifest.ManifestImage) map[string][]string {
+	keys := map[string][]string{}
+	for _, img := range images {
+		for _, c := range img.Components {
+			keys[c] = append(keys[c], img.ImageKey)
+		}
+	}
+	if len(keys) == 0 {
+		return defaultComponentImageKeys
+	}
+	for c := range keys {
+		sort.Strings(keys[c])
+	}
+	return keys
+}
+
+// ComponentImages returns the image references from the override map that the component deploys on
+// the hub, keyed by image key
+func ComponentImages(component string, componentKeys map[string][]string, imageOverrides map[string]string) map[string]string {
+	images := map[string]string{}
+	for _, key := range componentKeys[component] {
+		if ref, ok := imageOverrides[key]; ok {
+			images[key] = ref
+		}
+	}
+	return images
+}
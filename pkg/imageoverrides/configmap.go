@@ -0,0 +1,87 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package imageoverrides
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigmapOverrides returns the image references listed in an image overrides configmap, rewritten by
+// the registry mirror rules. Each data key holds an image manifest, versioned or not. Data keys are
+// applied in lexical order, so an image set under a later key replaces the same image set under an
+// earlier one. Keys that do not parse and incomplete entries are skipped and described in the returned
+// issues.
+func ConfigmapOverrides(cm *corev1.ConfigMap, mirrors []operatorsv1.RegistryMirror) (map[string]string, []string) {
+	overrides := map[string]string{}
+	issues := []string{}
+
+	keys := make([]string, 0, len(cm.Data))
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// Schema problems of single entries are reported together, and the complete entries still apply
+		images, err := manifest.ParseManifest([]byte(cm.Data[k]))
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("configmap %s/%s key %s: %s", cm.Namespace, cm.Name, k, err))
+		}
+		for _, img := range images {
+			if img.Validate() == nil {
+				overrides[img.ImageKey] = utils.MirrorImageReference(img.Reference(), mirrors)
+			}
+		}
+	}
+	return overrides, issues
+}
+
+// ApplyConfigmaps replaces images in place with those of the image overrides configmaps of the
+// multiclusterhub, in the listed order. Configmaps that are not found are skipped.
+func ApplyConfigmaps(ctx context.Context, c client.Client, m *operatorsv1.MultiClusterHub, images map[string]string) (map[string]string, error) {
+	for _, name := range utils.GetImageOverridesConfigmaps(m) {
+		cm := &corev1.ConfigMap{}
+		err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: m.Namespace}, cm)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		overrides, _ := ConfigmapOverrides(cm, utils.GetRegistryMirrors(m))
+		for k, v := range overrides {
+			images[k] = v
+		}
+	}
+	return images, nil
+}
+
+// VerifyConfigmap checks the detached signature of every data key of an image overrides configmap. The
+// signature of a key is stored under the same key with the manifest.SignatureSuffix in binaryData.
+func VerifyConfigmap(cm *corev1.ConfigMap, policy *manifest.VerificationPolicy) error {
+	if !policy.VerifiesSignatures() {
+		return nil
+	}
+	keys := make([]string, 0, len(cm.Data))
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		name := fmt.Sprintf("configmap %s/%s key %s", cm.Namespace, cm.Name, k)
+		if err := policy.VerifySignature(name, []byte(cm.Data[k]), cm.BinaryData[k+manifest.SignatureSuffix]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
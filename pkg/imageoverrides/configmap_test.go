@@ -0,0 +1,126 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package imageoverrides
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestConfigmapOverrides(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "overrides", Namespace: "open-cluster-management"},
+		Data: map[string]string{
+			"a-base.json": `[
+				{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron","image-tag":"2.5"},
+				{"image-key":"search","image-name":"search","image-remote":"quay.io/stolostron","image-tag":"2.5"}
+			]`,
+			"b-fix.json": `[
+				{"image-key":"console","image-name":"console","image-remote":"quay.io/dev","image-digest":"sha256:abc","image-tag":"ignored"},
+				{"image-key":"grc","image-name":"grc"}
+			]`,
+			"c-broken.json": `[{"image-key":`,
+			"d-versioned.json": `{"schemaVersion":1,"images":[
+				{"image-key":"volsync","image-name":"volsync","image-remote":"quay.io/stolostron","image-digest":"sha256:def"}
+			]}`,
+		},
+	}
+	mirrors := []operatorsv1.RegistryMirror{{Source: "quay.io/stolostron", Mirror: "mirror.example.com/acm"}}
+
+	got, issues := ConfigmapOverrides(cm, mirrors)
+
+	want := map[string]string{
+		"console": "quay.io/dev/console@sha256:abc",
+		"search":  "mirror.example.com/acm/search:2.5",
+		"volsync": "mirror.example.com/acm/volsync@sha256:def",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConfigmapOverrides() = %v, want %v", got, want)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("ConfigmapOverrides() issues = %v, want 2 issues", issues)
+	}
+	if !strings.Contains(issues[0], "key b-fix.json: invalid image manifest: images[1] (grc)") {
+		t.Errorf("ConfigmapOverrides() issue = %q, want it to name the incomplete entry", issues[0])
+	}
+	if !strings.Contains(issues[1], "key c-broken.json") {
+		t.Errorf("ConfigmapOverrides() issue = %q, want it to name the malformed key", issues[1])
+	}
+}
+
+func TestApplyConfigmaps(t *testing.T) {
+	mch := &operatorsv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: "open-cluster-management"},
+		Spec: operatorsv1.MultiClusterHubSpec{
+			RegistryMirrors: []operatorsv1.RegistryMirror{{Source: "quay.io/stolostron", Mirror: "mirror.example.com/acm"}},
+		},
+	}
+	mch.SetAnnotations(map[string]string{utils.AnnotationImageOverridesCM: "first, missing, second"})
+	first := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "open-cluster-management"},
+		Data: map[string]string{"overrides.json": `[
+			{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron","image-tag":"first"},
+			{"image-key":"search","image-name":"search","image-remote":"quay.io/stolostron","image-tag":"first"}
+		]`},
+	}
+	second := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "open-cluster-management"},
+		Data: map[string]string{"overrides.json": `[
+			{"image-key":"console","image-name":"console","image-remote":"quay.io/dev","image-tag":"second"}
+		]`},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(first, second).Build()
+
+	got, err := ApplyConfigmaps(context.TODO(), c, mch, map[string]string{
+		"console": "quay.io/stolostron/console@sha256:abc",
+		"grc":     "quay.io/stolostron/grc@sha256:def",
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfigmaps() error = %v", err)
+	}
+	want := map[string]string{
+		"console": "quay.io/dev/console:second",
+		"search":  "mirror.example.com/acm/search:first",
+		"grc":     "quay.io/stolostron/grc@sha256:def",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyConfigmaps() = %v, want %v", got, want)
+	}
+}
+
+func TestVerifyConfigmap(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &manifest.VerificationPolicy{PublicKey: pub}
+
+	data := `[{"image-key":"console","image-name":"console","image-remote":"quay.io/dev","image-digest":"sha256:abc"}]`
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "overrides", Namespace: "open-cluster-management"},
+		Data:       map[string]string{"overrides.json": data},
+	}
+	if err := VerifyConfigmap(cm, policy); !manifest.IsVerificationError(err) {
+		t.Errorf("VerifyConfigmap() of unsigned configmap error = %v, want verification error", err)
+	}
+	if err := VerifyConfigmap(cm, nil); err != nil {
+		t.Errorf("VerifyConfigmap() without policy error = %v", err)
+	}
+
+	cm.BinaryData = map[string][]byte{"overrides.json.sig": ed25519.Sign(key, []byte(data))}
+	if err := VerifyConfigmap(cm, policy); err != nil {
+		t.Errorf("VerifyConfigmap() error = %v", err)
+	}
+}
@@ -22,6 +22,13 @@ const (
 
 // GetImageOverrides Reads and formats full image reference from image manifest file.
 func GetImageOverrides() map[string]string {
+	imageOverrides, _ := GetImageOverridesWithPrefix()
+	return imageOverrides
+}
+
+// GetImageOverridesWithPrefix returns the image overrides set by environment variables along with the
+// variable prefix they were read from. The prefix is empty when no overrides are set.
+func GetImageOverridesWithPrefix() (map[string]string, string) {
 	imageOverrides := make(map[string]string)
 
 	// First check for environment variables containing the 'OPERAND_IMAGE_' prefix
@@ -36,7 +43,7 @@ func GetImageOverrides() map[string]string {
 	// If entries exist containing operand image prefix, return
 	if len(imageOverrides) > 0 {
 		logf.Info("Found image overrides from environment variables set by operand image prefix")
-		return imageOverrides
+		return imageOverrides, OperandImagePrefix
 	}
 
 	// If no image overrides found, check 'RELATED_IMAGE_' prefix
@@ -51,7 +58,8 @@ func GetImageOverrides() map[string]string {
 	// If entries exist containing related image prefix, return
 	if len(imageOverrides) > 0 {
 		logf.Info("Found image overrides from environment variables set by related image prefix")
+		return imageOverrides, OSBSImagePrefix
 	}
 
-	return imageOverrides
+	return imageOverrides, ""
 }
@@ -5,7 +5,11 @@ package imageoverrides
 
 import (
 	"os"
+	"reflect"
 	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
 )
 
 func TestGetImageOverridesRelatedImage(t *testing.T) {
@@ -54,3 +58,30 @@ func TestGetImageOverridesBothEnvVars(t *testing.T) {
 		t.Fatal("Expected no image overrides")
 	}
 }
+
+func TestComponentImageKeys(t *testing.T) {
+	images := []manifest.ManifestImage{
+		{ImageKey: "search_ui", Components: []string{operatorsv1.Search}},
+		{ImageKey: "search_api", Components: []string{operatorsv1.Search}},
+		{ImageKey: "oauth_proxy", Components: []string{operatorsv1.ManagementIngress, operatorsv1.Search}},
+		{ImageKey: "work"},
+	}
+	want := map[string][]string{
+		operatorsv1.ManagementIngress: {"oauth_proxy"},
+		operatorsv1.Search:            {"oauth_proxy", "search_api", "search_ui"},
+	}
+	if got := ComponentImageKeys(images); !reflect.DeepEqual(got, want) {
+		t.Errorf("ComponentImageKeys() = %v, want %v", got, want)
+	}
+
+	// Manifests that record no components use the keys of the operator release
+	if got := ComponentImageKeys([]manifest.ManifestImage{{ImageKey: "console"}}); !reflect.DeepEqual(got, defaultComponentImageKeys) {
+		t.Errorf("ComponentImageKeys() = %v, want the default keys", got)
+	}
+
+	images = append(images, manifest.ManifestImage{ImageKey: "console"})
+	overrides := map[string]string{"search_ui": "quay.io/stolostron/search-ui:1", "console": "quay.io/stolostron/console:1"}
+	if got := ComponentImages(operatorsv1.Search, ComponentImageKeys(images), overrides); !reflect.DeepEqual(got, map[string]string{"search_ui": "quay.io/stolostron/search-ui:1"}) {
+		t.Errorf("ComponentImages() = %v, want the search_ui override", got)
+	}
+}
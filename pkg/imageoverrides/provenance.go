@@ -0,0 +1,88 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package imageoverrides
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// SourceManifest marks images read from the versioned image manifest file
+	SourceManifest = "manifest"
+	// SourceOauthProxy marks the oauth proxy image selected for the cluster version
+	SourceOauthProxy = "oauth-proxy-selection"
+	// SourceImageRepository marks images rewritten by the mch-imageRepository annotation
+	SourceImageRepository = "annotation:mch-imageRepository"
+	// SourceRegistryMirrors marks images rewritten by spec.registryMirrors
+	SourceRegistryMirrors = "spec.registryMirrors"
+	// SourceDigestResolution marks tag references replaced by their digest under spec.imageDigestPolicy
+	SourceDigestResolution = "spec.imageDigestPolicy"
+)
+
+// SourceEnv returns the source of images read from environment variables with the given prefix
+func SourceEnv(prefix string) string {
+	return fmt.Sprintf("env:%s*", prefix)
+}
+
+// SourceConfigmap returns the source of images read from an image overrides configmap
+func SourceConfigmap(namespace, name string) string {
+	return fmt.Sprintf("configmap:%s/%s", namespace, name)
+}
+
+// ImageSource is the final reference of an image key and the layer that set it
+type ImageSource struct {
+	// Image is the final image reference
+	Image string `json:"image"`
+	// Source is the layer that set the reference
+	Source string `json:"source"`
+	// RewrittenBy is the registry rewrite applied to the reference after it was set, if any
+	RewrittenBy string `json:"rewrittenBy,omitempty"`
+}
+
+// Provenance records where each image reference came from as the image layers are applied
+type Provenance struct {
+	Images map[string]ImageSource `json:"images"`
+	// UnknownKeys lists override keys that no base layer defines. They are applied but no
+	// component is known to use them, which usually means a typo.
+	UnknownKeys []string `json:"unknownKeys,omitempty"`
+}
+
+// NewProvenance returns an empty provenance
+func NewProvenance() *Provenance {
+	return &Provenance{Images: map[string]ImageSource{}}
+}
+
+// Set attributes every image whose reference differs from the recorded one to source
+func (p *Provenance) Set(images map[string]string, source string) {
+	for key, image := range images {
+		if recorded, ok := p.Images[key]; ok && recorded.Image == image {
+			continue
+		}
+		p.Images[key] = ImageSource{Image: image, Source: source}
+	}
+}
+
+// Rewrite records images whose reference was changed by a registry rewrite, keeping the layer that set them
+func (p *Provenance) Rewrite(images map[string]string, rewrite string) {
+	for key, image := range images {
+		recorded, ok := p.Images[key]
+		if !ok || recorded.Image == image {
+			continue
+		}
+		recorded.Image = image
+		recorded.RewrittenBy = rewrite
+		p.Images[key] = recorded
+	}
+}
+
+// Override attributes changed images to an overrides layer and records the keys no earlier layer defined
+func (p *Provenance) Override(images map[string]string, source string) {
+	for key := range images {
+		if _, ok := p.Images[key]; !ok {
+			p.UnknownKeys = append(p.UnknownKeys, key)
+		}
+	}
+	sort.Strings(p.UnknownKeys)
+	p.Set(images, source)
+}
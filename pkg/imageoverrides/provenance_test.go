@@ -0,0 +1,56 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package imageoverrides
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProvenance(t *testing.T) {
+	p := NewProvenance()
+
+	images := map[string]string{
+		"console":               "quay.io/stolostron/console@sha256:abc",
+		"search":                "quay.io/stolostron/search@sha256:abc",
+		"oauth_proxy_49_and_up": "quay.io/stolostron/oauth-proxy@sha256:abc",
+	}
+	p.Set(images, SourceManifest)
+
+	images["console"] = "mirror.example.com/console@sha256:abc"
+	p.Rewrite(images, SourceRegistryMirrors)
+
+	images["oauth_proxy"] = images["oauth_proxy_49_and_up"]
+	p.Set(images, SourceOauthProxy)
+
+	images["search"] = "quay.io/dev/search:latest"
+	images["serach"] = "quay.io/dev/search:latest"
+	p.Override(images, SourceConfigmap("open-cluster-management", "overrides"))
+
+	want := map[string]ImageSource{
+		"console": {
+			Image:       "mirror.example.com/console@sha256:abc",
+			Source:      SourceManifest,
+			RewrittenBy: SourceRegistryMirrors,
+		},
+		"search":                {Image: "quay.io/dev/search:latest", Source: "configmap:open-cluster-management/overrides"},
+		"serach":                {Image: "quay.io/dev/search:latest", Source: "configmap:open-cluster-management/overrides"},
+		"oauth_proxy_49_and_up": {Image: "quay.io/stolostron/oauth-proxy@sha256:abc", Source: SourceManifest},
+		"oauth_proxy":           {Image: "quay.io/stolostron/oauth-proxy@sha256:abc", Source: SourceOauthProxy},
+	}
+	if !reflect.DeepEqual(p.Images, want) {
+		t.Errorf("Provenance.Images = %v, want %v", p.Images, want)
+	}
+	if !reflect.DeepEqual(p.UnknownKeys, []string{"serach"}) {
+		t.Errorf("Provenance.UnknownKeys = %v, want [serach]", p.UnknownKeys)
+	}
+}
+
+func TestGetImageOverridesWithPrefix(t *testing.T) {
+	t.Setenv("OPERAND_IMAGE_APPLICATION_UI", "quay.io/stolostron/application-ui:test-image")
+
+	images, prefix := GetImageOverridesWithPrefix()
+	if len(images) != 1 || prefix != OperandImagePrefix {
+		t.Errorf("GetImageOverridesWithPrefix() = %v, %q, want 1 image and prefix %q", images, prefix, OperandImagePrefix)
+	}
+}
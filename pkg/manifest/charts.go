@@ -0,0 +1,105 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+)
+
+// ChartsFileSuffix follows the operator version in the name of the file listing the charts of a release
+const ChartsFileSuffix = "-charts.json"
+
+// ManifestChart is a chart shipped with a release, as packaged in the helm repo image
+type ManifestChart struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// sha256 of the packaged chart, as listed in the helm repo index
+	Digest string `json:"digest"`
+}
+
+// ChartManifest is the versioned file listing the charts of a release
+type ChartManifest struct {
+	// SchemaVersion is the version of the chart manifest format
+	SchemaVersion int `json:"schemaVersion"`
+	// Charts lists the charts served by the helm repo
+	Charts []ManifestChart `json:"charts"`
+}
+
+// ParseChartManifest strictly decodes a chart manifest and checks that every chart has a name, version and
+// digest and is listed once. Problems are returned as a *ManifestError.
+func ParseChartManifest(data []byte) ([]ManifestChart, error) {
+	var m ChartManifest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, &ManifestError{Problems: []string{err.Error()}}
+	}
+	switch {
+	case m.SchemaVersion == 0:
+		return nil, &ManifestError{Problems: []string{"schemaVersion is required"}}
+	case m.SchemaVersion > SchemaVersion:
+		return nil, &ManifestError{Problems: []string{
+			fmt.Sprintf("schemaVersion %d is not supported, the newest supported version is %d", m.SchemaVersion, SchemaVersion),
+		}}
+	}
+
+	problems := []string{}
+	seen := map[string]int{}
+	for i, c := range m.Charts {
+		label := fmt.Sprintf("charts[%d]", i)
+		if c.Name != "" {
+			label = fmt.Sprintf("charts[%d] (%s)", i, c.Name)
+		}
+		switch {
+		case c.Name == "":
+			problems = append(problems, label+": name is required")
+		case c.Version == "":
+			problems = append(problems, label+": version is required")
+		case c.Digest == "":
+			problems = append(problems, label+": digest is required")
+		}
+		if c.Name == "" {
+			continue
+		}
+		if first, ok := seen[c.Name]; ok {
+			problems = append(problems, fmt.Sprintf("%s: duplicate name, first listed at charts[%d]", label, first))
+			continue
+		}
+		seen[c.Name] = i
+	}
+	if len(problems) > 0 {
+		return nil, &ManifestError{Problems: problems}
+	}
+	return m.Charts, nil
+}
+
+// GetManifestCharts returns the charts shipped with the operator version, keyed by name. Releases that
+// do not ship a chart manifest return nil.
+func GetManifestCharts() (map[string]ManifestChart, error) {
+	name := version.Version + ChartsFileSuffix
+	filePath, err := manifestsFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	data, err := readManifestsPath(name)
+	if err != nil {
+		return nil, err
+	}
+	charts, err := ParseChartManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]ManifestChart, len(charts))
+	for _, c := range charts {
+		byName[c.Name] = c
+	}
+	return byName, nil
+}
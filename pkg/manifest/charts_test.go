@@ -0,0 +1,82 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+)
+
+func TestParseChartManifest(t *testing.T) {
+	grc := `{"name":"grc","version":"2.5.0","digest":"0f1e2d"}`
+
+	tests := []struct {
+		name       string
+		data       string
+		wantCharts int
+		wantErr    string
+	}{
+		{
+			name:       "Chart manifest",
+			data:       `{"schemaVersion":1,"charts":[` + grc + `]}`,
+			wantCharts: 1,
+		},
+		{
+			name:    "Missing schema version",
+			data:    `{"charts":[` + grc + `]}`,
+			wantErr: "schemaVersion is required",
+		},
+		{
+			name:    "Unknown chart field",
+			data:    `{"schemaVersion":1,"charts":[{"name":"grc","version":"2.5.0","digest":"0f1e2d","url":"grc.tgz"}]}`,
+			wantErr: `unknown field "url"`,
+		},
+		{
+			name:    "Missing digest",
+			data:    `{"schemaVersion":1,"charts":[{"name":"grc","version":"2.5.0"}]}`,
+			wantErr: "charts[0] (grc): digest is required",
+		},
+		{
+			name:    "Duplicate chart",
+			data:    `{"schemaVersion":1,"charts":[` + grc + `,` + grc + `]}`,
+			wantErr: "charts[1] (grc): duplicate name, first listed at charts[0]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			charts, err := ParseChartManifest([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil || len(charts) != tt.wantCharts {
+					t.Errorf("ParseChartManifest() = %d charts, %v, want %d charts", len(charts), err, tt.wantCharts)
+				}
+				return
+			}
+			if !IsManifestError(err) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseChartManifest() error = %v, want a manifest error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetManifestCharts(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(ManifestsPathEnvVar, dir)
+
+	charts, err := GetManifestCharts()
+	if err != nil || charts != nil {
+		t.Errorf("GetManifestCharts() = %v, %v, want nothing for a release without a chart manifest", charts, err)
+	}
+
+	data := `{"schemaVersion":1,"charts":[{"name":"grc","version":"2.5.0","digest":"0f1e2d"}]}`
+	if err := os.WriteFile(filepath.Join(dir, version.Version+ChartsFileSuffix), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	charts, err = GetManifestCharts()
+	if err != nil || charts["grc"].Digest != "0f1e2d" {
+		t.Errorf("GetManifestCharts() = %v, %v", charts, err)
+	}
+}
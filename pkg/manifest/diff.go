@@ -0,0 +1,135 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ImageChangeType describes how an image key differs between two manifests
+type ImageChangeType string
+
+const (
+	// ImageAdded means the image key only exists in the newer manifest
+	ImageAdded ImageChangeType = "Added"
+	// ImageRemoved means the image key only exists in the older manifest
+	ImageRemoved ImageChangeType = "Removed"
+	// ImageChanged means the image key references a different image
+	ImageChanged ImageChangeType = "Changed"
+)
+
+// ImageChange is the difference for a single image key
+type ImageChange struct {
+	ImageKey string          `json:"imageKey"`
+	Change   ImageChangeType `json:"change"`
+	// Component is the git repository building the image, when either manifest records it
+	Component string `json:"component,omitempty"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+}
+
+// ManifestDiff is the difference between two sets of image references
+type ManifestDiff struct {
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Changes   []ImageChange `json:"changes"`
+	Unchanged int           `json:"unchanged"`
+}
+
+// ImageReferences returns the reference of each manifest image keyed by image key
+func ImageReferences(images []ManifestImage) map[string]string {
+	refs := make(map[string]string, len(images))
+	for _, img := range images {
+		refs[img.ImageKey] = img.Reference()
+	}
+	return refs
+}
+
+// ImageComponents returns the git repository building each image key. Later manifests take precedence.
+func ImageComponents(manifests ...[]ManifestImage) map[string]string {
+	components := map[string]string{}
+	for _, images := range manifests {
+		for _, img := range images {
+			if img.GitRepository != "" {
+				components[img.ImageKey] = img.GitRepository
+			}
+		}
+	}
+	return components
+}
+
+// DiffImages compares two sets of image references keyed by image key. Changes are sorted by image
+// key and labelled with the component from components when known.
+func DiffImages(from, to map[string]string, components map[string]string) ManifestDiff {
+	diff := ManifestDiff{Changes: []ImageChange{}}
+	for key, fromRef := range from {
+		toRef, ok := to[key]
+		switch {
+		case !ok:
+			diff.Changes = append(diff.Changes, ImageChange{ImageKey: key, Change: ImageRemoved, From: fromRef})
+		case fromRef != toRef:
+			diff.Changes = append(diff.Changes, ImageChange{ImageKey: key, Change: ImageChanged, From: fromRef, To: toRef})
+		default:
+			diff.Unchanged++
+		}
+	}
+	for key, toRef := range to {
+		if _, ok := from[key]; !ok {
+			diff.Changes = append(diff.Changes, ImageChange{ImageKey: key, Change: ImageAdded, To: toRef})
+		}
+	}
+	for i := range diff.Changes {
+		diff.Changes[i].Component = components[diff.Changes[i].ImageKey]
+	}
+	sort.Slice(diff.Changes, func(i, j int) bool { return diff.Changes[i].ImageKey < diff.Changes[j].ImageKey })
+	return diff
+}
+
+// LiveImageManifest returns the image manifest configmap the operator maintains for a release
+// version in the namespace. An empty version returns the only image manifest configmap present.
+func LiveImageManifest(ctx context.Context, c client.Client, namespace, version string) (*corev1.ConfigMap, error) {
+	cmList := &corev1.ConfigMapList{}
+	labels := client.MatchingLabels{"ocm-configmap-type": "image-manifest"}
+	if version != "" {
+		labels["ocm-release-version"] = version
+	}
+	if err := c.List(ctx, cmList, client.InNamespace(namespace), labels); err != nil {
+		return nil, err
+	}
+	switch len(cmList.Items) {
+	case 0:
+		return nil, fmt.Errorf("no image manifest configmap found in namespace %s", namespace)
+	case 1:
+		return &cmList.Items[0], nil
+	default:
+		return nil, fmt.Errorf("found %d image manifest configmaps in namespace %s, a version is required", len(cmList.Items), namespace)
+	}
+}
+
+// WriteTable writes the changes as a human readable table
+func (d ManifestDiff) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "IMAGE KEY\tCHANGE\tCOMPONENT\tFROM\tTO\n")
+	for _, c := range d.Changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.ImageKey, c.Change, orNone(c.Component), orNone(c.From), orNone(c.To))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d changed, %d unchanged (%s -> %s)\n", len(d.Changes), d.Unchanged, d.From, d.To)
+	return err
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDiffImages(t *testing.T) {
+	from := []ManifestImage{
+		{ImageKey: "console", ImageName: "console", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:old", GitRepository: "stolostron/console"},
+		{ImageKey: "search", ImageName: "search", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:same"},
+		{ImageKey: "removed", ImageName: "removed", ImageRemote: "quay.io/stolostron", ImageTag: "2.4"},
+	}
+	to := []ManifestImage{
+		{ImageKey: "console", ImageName: "console", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:new"},
+		{ImageKey: "search", ImageName: "search", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:same"},
+		{ImageKey: "added", ImageName: "added", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:abc", GitRepository: "stolostron/added"},
+	}
+
+	got := DiffImages(ImageReferences(from), ImageReferences(to), ImageComponents(from, to))
+	want := ManifestDiff{
+		Changes: []ImageChange{
+			{ImageKey: "added", Change: ImageAdded, Component: "stolostron/added", To: "quay.io/stolostron/added@sha256:abc"},
+			{ImageKey: "console", Change: ImageChanged, Component: "stolostron/console",
+				From: "quay.io/stolostron/console@sha256:old", To: "quay.io/stolostron/console@sha256:new"},
+			{ImageKey: "removed", Change: ImageRemoved, From: "quay.io/stolostron/removed:2.4"},
+		},
+		Unchanged: 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffImages() = %+v, want %+v", got, want)
+	}
+
+	out := &bytes.Buffer{}
+	if err := got.WriteTable(out); err != nil {
+		t.Fatalf("WriteTable() error = %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 6 {
+		t.Errorf("WriteTable() wrote %d lines, want header, 3 changes, blank line and summary:\n%s", len(lines), out)
+	}
+}
+
+func TestLiveImageManifest(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	manifestCM := func(version string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      "mch-image-manifest-" + version,
+			Namespace: "open-cluster-management",
+			Labels:    map[string]string{"ocm-configmap-type": "image-manifest", "ocm-release-version": version},
+		}}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(manifestCM("2.4.0"), manifestCM("2.5.0")).Build()
+
+	cm, err := LiveImageManifest(context.TODO(), c, "open-cluster-management", "2.5.0")
+	if err != nil || cm.Name != "mch-image-manifest-2.5.0" {
+		t.Errorf("LiveImageManifest() = %v, %v, want mch-image-manifest-2.5.0", cm, err)
+	}
+	if _, err := LiveImageManifest(context.TODO(), c, "open-cluster-management", ""); err == nil {
+		t.Error("LiveImageManifest() without a version should error when several configmaps exist")
+	}
+	if _, err := LiveImageManifest(context.TODO(), c, "default", ""); err == nil {
+		t.Error("LiveImageManifest() should error when no configmap exists")
+	}
+}
@@ -4,6 +4,7 @@
 package manifest
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -35,29 +36,118 @@ type ManifestImage struct {
 	ImageDigest string `json:"image-digest"`
 
 	ImageTag string `json:"image-tag"`
+
+	// build metadata published by the release pipeline
+	ImageRemoteSrc string `json:"image-remote-src,omitempty"`
+	GitSha256      string `json:"git-sha256,omitempty"`
+	GitRepository  string `json:"git-repository,omitempty"`
+
+	// hub components that deploy the image, when the release pipeline records them
+	Components []string `json:"components,omitempty"`
+}
+
+// Validate returns an error naming the first required field missing from the image
+func (mi ManifestImage) Validate() error {
+	switch {
+	case mi.ImageKey == "":
+		return errors.New("image-key is required")
+	case mi.ImageName == "":
+		return errors.New("image-name is required")
+	case mi.ImageRemote == "":
+		return errors.New("image-remote is required")
+	case mi.ImageDigest == "" && mi.ImageTag == "":
+		return errors.New("one of image-digest or image-tag is required")
+	}
+	for _, c := range mi.Components {
+		if !operatorsv1.ValidComponent(operatorsv1.ComponentConfig{Name: c}) {
+			return fmt.Errorf("unknown component %s", c)
+		}
+	}
+	return nil
+}
+
+// Reference returns the image reference of the manifest image, preferring the digest over the tag
+func (mi ManifestImage) Reference() string {
+	if mi.ImageDigest != "" {
+		return fmt.Sprintf("%s/%s@%s", mi.ImageRemote, mi.ImageName, mi.ImageDigest)
+	}
+	return fmt.Sprintf("%s/%s:%s", mi.ImageRemote, mi.ImageName, mi.ImageTag)
+}
+
+// ParseManifestImages strictly decodes a list of manifest images, rejecting fields that are not
+// part of the ManifestImage schema
+func ParseManifestImages(data []byte) ([]ManifestImage, error) {
+	var manifestImages []ManifestImage
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&manifestImages); err != nil {
+		return nil, err
+	}
+	return manifestImages, nil
 }
 
 // GetImageOverrides Reads and formats full image reference from image manifest file.
 func GetImageOverrides(mch *operatorsv1.MultiClusterHub) (map[string]string, error) {
-	manifestData, err := readManifestFile(version.Version)
+	return GetVerifiedImageOverrides(mch, nil)
+}
+
+// GetVerifiedImageOverrides reads the image manifest file like GetImageOverrides, first checking its
+// detached signature and afterwards the image digests as required by the policy. Policy failures are
+// returned as a *VerificationError and schema problems as a *ManifestError.
+func GetVerifiedImageOverrides(mch *operatorsv1.MultiClusterHub, policy *VerificationPolicy) (map[string]string, error) {
+	manifestImages, err := GetVerifiedManifestImages(policy)
 	if err != nil {
 		return nil, err
 	}
+	return VerifiedImageOverrides(mch, manifestImages, policy)
+}
 
-	var manifestImages []ManifestImage
-	err = json.Unmarshal(manifestData, &manifestImages)
+// GetVerifiedManifestImages returns the images listed in the image manifest file of the operator
+// version, after checking its detached signature as required by the policy
+func GetVerifiedManifestImages(policy *VerificationPolicy) ([]ManifestImage, error) {
+	manifestData, err := readManifestFile(version.Version)
 	if err != nil {
 		return nil, err
 	}
 
+	if policy.VerifiesSignatures() {
+		signature, err := readManifestSignature(version.Version)
+		if err != nil {
+			return nil, err
+		}
+		if err := policy.VerifySignature(fmt.Sprintf("image manifest %s.json", version.Version), manifestData, signature); err != nil {
+			return nil, err
+		}
+	}
+
+	return ParseManifest(manifestData)
+}
+
+// VerifiedImageOverrides formats the full image references of the manifest images and checks their
+// digests as required by the policy
+func VerifiedImageOverrides(mch *operatorsv1.MultiClusterHub, manifestImages []ManifestImage, policy *VerificationPolicy) (map[string]string, error) {
 	imageOverrides, err := formatImageOverrides(mch, manifestImages)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := policy.CheckDigests(imageOverrides); err != nil {
+		return nil, err
+	}
+
 	return imageOverrides, nil
 }
 
+// GetManifestImages returns the images listed in the image manifest file of the operator version. A
+// manifest that does not match its schema is returned as a *ManifestError.
+func GetManifestImages() ([]ManifestImage, error) {
+	manifestData, err := readManifestFile(version.Version)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(manifestData)
+}
+
 func formatImageOverrides(mch *operatorsv1.MultiClusterHub, manifestImages []ManifestImage) (map[string]string, error) {
 	imageOverrides := make(map[string]string)
 	for _, img := range manifestImages {
@@ -67,6 +157,9 @@ func formatImageOverrides(mch *operatorsv1.MultiClusterHub, manifestImages []Man
 }
 
 func buildFullImageReference(mch *operatorsv1.MultiClusterHub, mi ManifestImage) string {
+	if mirrors := utils.GetRegistryMirrors(mch); len(mirrors) > 0 {
+		return utils.MirrorImageReference(manifestFormat(mi, mi.ImageRemote), mirrors)
+	}
 	registry := mi.ImageRemote
 	// Use ImageRepository if provided
 	if reg := utils.GetImageRepository(mch); reg != "" {
@@ -75,21 +168,34 @@ func buildFullImageReference(mch *operatorsv1.MultiClusterHub, mi ManifestImage)
 	return manifestFormat(mi, registry)
 }
 
+// manifestFormat returns the reference of the image in the registry, pinned by digest when the manifest
+// has one and by tag otherwise
 func manifestFormat(mi ManifestImage, registry string) string {
-	image := mi.ImageName
-	digest := mi.ImageDigest
-	return fmt.Sprintf("%s/%s@%s", registry, image, digest)
+	mi.ImageRemote = registry
+	return mi.Reference()
 }
 
 // readManifestFile returns the byte content of a versioned image manifest file
 func readManifestFile(version string) ([]byte, error) {
-	manifestsPath, found := os.LookupEnv(ManifestsPathEnvVar)
-	if !found {
-		missingEnvErr := errors.New("MANIFESTS_PATH environment variable is required")
-		return nil, missingEnvErr
+	return readManifestsPath(version + ".json")
+}
+
+// readManifestSignature returns the detached signature of a versioned image manifest file, or nil if
+// the manifest is not signed
+func readManifestSignature(version string) ([]byte, error) {
+	signature, err := readManifestsPath(version + ".json" + SignatureSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
 	}
+	return signature, err
+}
 
-	filePath := path.Join(manifestsPath, version+".json")
+// readManifestsPath returns the content of a file in the manifests directory
+func readManifestsPath(name string) ([]byte, error) {
+	filePath, err := manifestsFilePath(name)
+	if err != nil {
+		return nil, err
+	}
 	contents, err := ioutil.ReadFile(filepath.Clean(filePath)) // #nosec G304 (filepath cleaned)
 	if err != nil {
 		log.Error(err, "Failed to read image manifest", "Path", filePath)
@@ -97,3 +203,13 @@ func readManifestFile(version string) ([]byte, error) {
 	}
 	return contents, nil
 }
+
+// manifestsFilePath returns the path of a file in the manifests directory
+func manifestsFilePath(name string) (string, error) {
+	manifestsPath, found := os.LookupEnv(ManifestsPathEnvVar)
+	if !found {
+		missingEnvErr := errors.New("MANIFESTS_PATH environment variable is required")
+		return "", missingEnvErr
+	}
+	return path.Join(manifestsPath, name), nil
+}
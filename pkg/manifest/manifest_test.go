@@ -55,6 +55,16 @@ func Test_buildFullImageReference(t *testing.T) {
 	mch2 := mch.DeepCopy()
 	mch2.SetAnnotations(map[string]string{utils.AnnotationImageRepo: "foo.io/bar"})
 
+	mch3 := mch2.DeepCopy()
+	mch3.Spec.RegistryMirrors = []operatorsv1.RegistryMirror{{Source: "quay.io/stolostron", Mirror: "mirror.example.com/acm"}}
+
+	tagged := mi
+	tagged.ImageDigest = ""
+	tagged.ImageTag = "9.9.9-abc"
+
+	mch4 := mch.DeepCopy()
+	mch4.Spec.RegistryMirrors = []operatorsv1.RegistryMirror{{Source: "registry.redhat.io", Mirror: "mirror.example.com"}}
+
 	type args struct {
 		mch *operatorsv1.MultiClusterHub
 		mi  ManifestImage
@@ -69,11 +79,26 @@ func Test_buildFullImageReference(t *testing.T) {
 			args: args{mch1, mi},
 			want: "quay.io/stolostron/test-app@sha256:abc123",
 		},
+		{
+			name: "Tag without digest",
+			args: args{mch1, tagged},
+			want: "quay.io/stolostron/test-app:9.9.9-abc",
+		},
 		{
 			name: "Custom registry",
 			args: args{mch2, mi},
 			want: "foo.io/bar/test-app@sha256:abc123",
 		},
+		{
+			name: "Registry mirror supersedes custom registry",
+			args: args{mch3, mi},
+			want: "mirror.example.com/acm/test-app@sha256:abc123",
+		},
+		{
+			name: "Registry mirror without a matching source",
+			args: args{mch4, mi},
+			want: "quay.io/stolostron/test-app@sha256:abc123",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -83,3 +108,81 @@ func Test_buildFullImageReference(t *testing.T) {
 		})
 	}
 }
+
+func TestParseManifestImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "Valid image list",
+			data: `[{"image-key":"application_ui","image-name":"application-ui","image-remote":"quay.io/stolostron","image-digest":"sha256:abc"}]`,
+		},
+		{
+			name: "Pipeline build metadata",
+			data: `[{"image-key":"application_ui","image-name":"application-ui","image-remote":"quay.io/stolostron","image-digest":"sha256:abc",
+				"image-remote-src":"registry.ci.openshift.org/open-cluster-management","git-sha256":"6304","git-repository":"stolostron/application-ui"}]`,
+		},
+		{
+			name:    "Unknown field",
+			data:    `[{"image-key":"application_ui","image-repo":"quay.io/stolostron"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Not a list",
+			data:    `{"image-key":"application_ui"}`,
+			wantErr: true,
+		},
+		{
+			name:    "Malformed JSON",
+			data:    `[{"image-key":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifestImages([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseManifestImages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManifestImage_Validate(t *testing.T) {
+	valid := ManifestImage{ImageKey: "application_ui", ImageName: "application-ui", ImageRemote: "quay.io/stolostron", ImageTag: "latest"}
+	missingRef := valid
+	missingRef.ImageTag = ""
+	missingKey := valid
+	missingKey.ImageKey = ""
+
+	tests := []struct {
+		name    string
+		image   ManifestImage
+		wantErr bool
+	}{
+		{name: "Valid image", image: valid},
+		{name: "Missing tag and digest", image: missingRef, wantErr: true},
+		{name: "Missing key", image: missingKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.image.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseManifestImages_examples(t *testing.T) {
+	for _, path := range []string{"../../docs/examples/manifest-oneimage.json", "../../bin/image-manifests/2.5.0.json"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if _, err := ParseManifestImages(data); err != nil {
+			t.Errorf("ParseManifestImages(%s) error = %v", path, err)
+		}
+	}
+}
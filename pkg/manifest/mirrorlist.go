@@ -0,0 +1,93 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"sort"
+	"strings"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+)
+
+// ImageMirror is a manifest image and the reference it is pulled from once mirrored
+type ImageMirror struct {
+	ImageKey string
+	Source   string
+	Mirror   string
+	// Tag is the image tag recorded in the manifest, if any
+	Tag string
+}
+
+// RepositoryMirror maps a source repository to the repositories mirroring it, as used by
+// ImageContentSourcePolicy and ImageDigestMirrorSet resources
+type RepositoryMirror struct {
+	Source  string   `json:"source"`
+	Mirrors []string `json:"mirrors"`
+}
+
+// MirrorRulesForRegistry returns a mirror rule for each image remote of the manifest that moves its
+// repositories under the target registry, keeping the repository path like `oc adm catalog mirror`.
+// For example quay.io/stolostron is mirrored to <target>/stolostron.
+func MirrorRulesForRegistry(images []ManifestImage, target string) []operatorsv1.RegistryMirror {
+	target = strings.TrimSuffix(target, "/")
+	remotes := map[string]bool{}
+	for _, img := range images {
+		remotes[img.ImageRemote] = true
+	}
+
+	rules := []operatorsv1.RegistryMirror{}
+	for remote := range remotes {
+		mirror := target
+		if i := strings.Index(remote, "/"); i >= 0 {
+			mirror = target + remote[i:]
+		}
+		rules = append(rules, operatorsv1.RegistryMirror{Source: remote, Mirror: mirror})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Source < rules[j].Source })
+	return rules
+}
+
+// MirrorImages rewrites each manifest image with the mirror rules exactly as the operator does at
+// runtime. Images no rule matches are returned separately by image key.
+func MirrorImages(images []ManifestImage, rules []operatorsv1.RegistryMirror) ([]ImageMirror, []string) {
+	mirrored := []ImageMirror{}
+	unmatched := []string{}
+	for _, img := range images {
+		source := img.Reference()
+		mirror := utils.MirrorImageReference(source, rules)
+		if mirror == source {
+			unmatched = append(unmatched, img.ImageKey)
+			continue
+		}
+		mirrored = append(mirrored, ImageMirror{ImageKey: img.ImageKey, Source: source, Mirror: mirror, Tag: img.ImageTag})
+	}
+	sort.Slice(mirrored, func(i, j int) bool { return mirrored[i].ImageKey < mirrored[j].ImageKey })
+	sort.Strings(unmatched)
+	return mirrored, unmatched
+}
+
+// RepositoryMirrors groups mirrored images by source repository
+func RepositoryMirrors(mirrored []ImageMirror) []RepositoryMirror {
+	bySource := map[string]map[string]bool{}
+	for _, m := range mirrored {
+		source, _, _ := utils.SplitImageReference(m.Source)
+		mirror, _, _ := utils.SplitImageReference(m.Mirror)
+		if bySource[source] == nil {
+			bySource[source] = map[string]bool{}
+		}
+		bySource[source][mirror] = true
+	}
+
+	repos := []RepositoryMirror{}
+	for source, mirrors := range bySource {
+		rm := RepositoryMirror{Source: source}
+		for mirror := range mirrors {
+			rm.Mirrors = append(rm.Mirrors, mirror)
+		}
+		sort.Strings(rm.Mirrors)
+		repos = append(repos, rm)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Source < repos[j].Source })
+	return repos
+}
@@ -0,0 +1,51 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"reflect"
+	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+)
+
+func TestMirrorImages(t *testing.T) {
+	images := []ManifestImage{
+		{ImageKey: "console", ImageName: "console", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:abc"},
+		{ImageKey: "search", ImageName: "search", ImageRemote: "quay.io/stolostron", ImageDigest: "sha256:def"},
+		{ImageKey: "oauth_proxy", ImageName: "origin-oauth-proxy", ImageRemote: "quay.io/openshift", ImageTag: "4.9"},
+		{ImageKey: "registration", ImageName: "registration", ImageRemote: "registry.redhat.io/rhacm2", ImageDigest: "sha256:123"},
+	}
+
+	rules := MirrorRulesForRegistry(images, "mirror.example.com:8443/")
+	wantRules := []operatorsv1.RegistryMirror{
+		{Source: "quay.io/openshift", Mirror: "mirror.example.com:8443/openshift"},
+		{Source: "quay.io/stolostron", Mirror: "mirror.example.com:8443/stolostron"},
+		{Source: "registry.redhat.io/rhacm2", Mirror: "mirror.example.com:8443/rhacm2"},
+	}
+	if !reflect.DeepEqual(rules, wantRules) {
+		t.Errorf("MirrorRulesForRegistry() = %v, want %v", rules, wantRules)
+	}
+
+	// Only mirror the stolostron images
+	mirrored, unmatched := MirrorImages(images, rules[1:2])
+	wantMirrored := []ImageMirror{
+		{ImageKey: "console", Source: "quay.io/stolostron/console@sha256:abc", Mirror: "mirror.example.com:8443/stolostron/console@sha256:abc"},
+		{ImageKey: "search", Source: "quay.io/stolostron/search@sha256:def", Mirror: "mirror.example.com:8443/stolostron/search@sha256:def"},
+	}
+	if !reflect.DeepEqual(mirrored, wantMirrored) {
+		t.Errorf("MirrorImages() mirrored = %v, want %v", mirrored, wantMirrored)
+	}
+	if !reflect.DeepEqual(unmatched, []string{"oauth_proxy", "registration"}) {
+		t.Errorf("MirrorImages() unmatched = %v", unmatched)
+	}
+
+	repos := RepositoryMirrors(mirrored)
+	wantRepos := []RepositoryMirror{
+		{Source: "quay.io/stolostron/console", Mirrors: []string{"mirror.example.com:8443/stolostron/console"}},
+		{Source: "quay.io/stolostron/search", Mirrors: []string{"mirror.example.com:8443/stolostron/search"}},
+	}
+	if !reflect.DeepEqual(repos, wantRepos) {
+		t.Errorf("RepositoryMirrors() = %v, want %v", repos, wantRepos)
+	}
+}
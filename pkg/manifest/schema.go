@@ -0,0 +1,168 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SchemaVersion is the newest image manifest schema version this operator reads
+const SchemaVersion = 1
+
+// Manifest is a versioned image manifest file. Files that hold a bare list of images predate schema
+// versions and are read as version 0.
+type Manifest struct {
+	// SchemaVersion is the version of the manifest format
+	SchemaVersion int `json:"schemaVersion"`
+	// Images lists the images deployed by the hub
+	Images []ManifestImage `json:"images"`
+}
+
+// ManifestError is returned when an image manifest does not match its schema. It lists every problem
+// found so they can be fixed at once.
+type ManifestError struct {
+	Problems []string
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("invalid image manifest: %s", strings.Join(e.Problems, "; "))
+}
+
+// IsManifestError returns true if the error reports an image manifest that does not match its schema
+func IsManifestError(err error) bool {
+	var merr *ManifestError
+	return errors.As(err, &merr)
+}
+
+// ParseManifest strictly decodes an image manifest file, either a versioned manifest or an unversioned list
+// of images, and checks that no object sets a field twice and that every image has its required fields
+// and a unique image key. Problems are returned as a *ManifestError.
+func ParseManifest(data []byte) ([]ManifestImage, error) {
+	trimmed := bytes.TrimSpace(data)
+	unversioned := len(trimmed) > 0 && trimmed[0] == '['
+
+	// The decoder keeps the last of repeated fields, so they are looked for in the tokens first. Syntax
+	// errors are left to the decoder to report.
+	root := ""
+	if unversioned {
+		root = "images"
+	}
+	if duplicates, err := duplicateFields(json.NewDecoder(bytes.NewReader(trimmed)), root); err == nil && len(duplicates) > 0 {
+		return nil, &ManifestError{Problems: duplicates}
+	}
+
+	if unversioned {
+		images, err := ParseManifestImages(trimmed)
+		if err != nil {
+			return nil, &ManifestError{Problems: []string{err.Error()}}
+		}
+		return images, ValidateManifestImages(images)
+	}
+
+	var m Manifest
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, &ManifestError{Problems: []string{err.Error()}}
+	}
+	switch {
+	case m.SchemaVersion == 0:
+		return nil, &ManifestError{Problems: []string{"schemaVersion is required"}}
+	case m.SchemaVersion > SchemaVersion:
+		return nil, &ManifestError{Problems: []string{
+			fmt.Sprintf("schemaVersion %d is not supported, the newest supported version is %d", m.SchemaVersion, SchemaVersion),
+		}}
+	case m.Images == nil:
+		return nil, &ManifestError{Problems: []string{"images is required"}}
+	}
+	return m.Images, ValidateManifestImages(m.Images)
+}
+
+// ValidateManifestImages checks that every image has its required fields and that no image key is
+// listed twice
+func ValidateManifestImages(images []ManifestImage) error {
+	problems := []string{}
+	seen := map[string]int{}
+	for i, img := range images {
+		if err := img.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", imageLabel(i, img), err))
+		}
+		if img.ImageKey == "" {
+			continue
+		}
+		if first, ok := seen[img.ImageKey]; ok {
+			problems = append(problems, fmt.Sprintf("%s: duplicate image-key, first listed at images[%d]", imageLabel(i, img), first))
+			continue
+		}
+		seen[img.ImageKey] = i
+	}
+	if len(problems) > 0 {
+		return &ManifestError{Problems: problems}
+	}
+	return nil
+}
+
+// duplicateFields reads the next JSON value from the decoder and returns a problem for every field that an
+// object of the value sets more than once, naming the object by its path
+func duplicateFields(dec *json.Decoder, path string) ([]string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	problems := []string{}
+	switch tok {
+	case json.Delim('{'):
+		label := path
+		if label == "" {
+			label = "manifest"
+		}
+		seen := map[string]bool{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyTok.(string)
+			if seen[key] {
+				problems = append(problems, fmt.Sprintf("%s: duplicate field %q", label, key))
+			}
+			seen[key] = true
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			nested, err := duplicateFields(dec, child)
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, nested...)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			nested, err := duplicateFields(dec, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, nested...)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
+
+// imageLabel names an image of the manifest in problem descriptions
+func imageLabel(i int, img ManifestImage) string {
+	if img.ImageKey == "" {
+		return fmt.Sprintf("images[%d]", i)
+	}
+	return fmt.Sprintf("images[%d] (%s)", i, img.ImageKey)
+}
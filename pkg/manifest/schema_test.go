@@ -0,0 +1,129 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	image := `{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron","image-digest":"sha256:abc"}`
+	other := `{"image-key":"grc_ui","image-name":"grc-ui","image-remote":"quay.io/stolostron","image-tag":"2.5.0"}`
+
+	tests := []struct {
+		name       string
+		data       string
+		wantImages int
+		wantErr    string
+	}{
+		{
+			name:       "Unversioned list",
+			data:       "\n[" + image + "," + other + "]",
+			wantImages: 2,
+		},
+		{
+			name:       "Versioned manifest",
+			data:       `{"schemaVersion":1,"images":[` + image + `]}`,
+			wantImages: 1,
+		},
+		{
+			name:    "Missing schema version",
+			data:    `{"images":[` + image + `]}`,
+			wantErr: "schemaVersion is required",
+		},
+		{
+			name:    "Newer schema version",
+			data:    `{"schemaVersion":2,"images":[` + image + `]}`,
+			wantErr: "schemaVersion 2 is not supported",
+		},
+		{
+			name:    "Missing images",
+			data:    `{"schemaVersion":1}`,
+			wantErr: "images is required",
+		},
+		{
+			name:    "Unknown field",
+			data:    `{"schemaVersion":1,"images":[],"release":"2.5"}`,
+			wantErr: `unknown field "release"`,
+		},
+		{
+			name:    "Unknown image field",
+			data:    `[{"image-key":"console","image-repo":"quay.io/stolostron"}]`,
+			wantErr: `unknown field "image-repo"`,
+		},
+		{
+			name:    "Missing digest and tag",
+			data:    `{"schemaVersion":1,"images":[{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron"}]}`,
+			wantErr: "images[0] (console): one of image-digest or image-tag is required",
+		},
+		{
+			name:       "Image components",
+			data:       `{"schemaVersion":1,"images":[{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron","image-tag":"1","components":["console"]}]}`,
+			wantImages: 1,
+		},
+		{
+			name:    "Unknown image component",
+			data:    `{"schemaVersion":1,"images":[{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron","image-tag":"1","components":["web-console"]}]}`,
+			wantErr: "images[0] (console): unknown component web-console",
+		},
+		{
+			name:    "Duplicate image key",
+			data:    `[` + image + `,` + other + `,` + image + `]`,
+			wantErr: "images[2] (console): duplicate image-key, first listed at images[0]",
+		},
+		{
+			name:    "Duplicate field",
+			data:    `{"schemaVersion":1,"images":[{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron","image-tag":"1","image-tag":"2"}]}`,
+			wantErr: `images[0]: duplicate field "image-tag"`,
+		},
+		{
+			name:    "Duplicate top level field",
+			data:    `{"schemaVersion":1,"images":[],"schemaVersion":1}`,
+			wantErr: `manifest: duplicate field "schemaVersion"`,
+		},
+		{
+			name:    "Duplicate field in an unversioned list",
+			data:    `[` + image + `,{"image-key":"grc_ui","image-key":"console"}]`,
+			wantErr: `images[1]: duplicate field "image-key"`,
+		},
+		{
+			name:    "Every problem is reported",
+			data:    `[{"image-name":"console","image-remote":"quay.io/stolostron","image-tag":"1"},{"image-key":"grc_ui","image-remote":"quay.io/stolostron","image-tag":"1"}]`,
+			wantErr: "images[0]: image-key is required; images[1] (grc_ui): image-name is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := ParseManifest([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil || len(images) != tt.wantImages {
+					t.Errorf("ParseManifest() = %d images, %v, want %d images", len(images), err, tt.wantImages)
+				}
+				return
+			}
+			if !IsManifestError(err) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseManifest() error = %v, want a manifest error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseManifest_files(t *testing.T) {
+	paths := []string{
+		"../../docs/examples/manifest-oneimage.json",
+		"../../docs/examples/manifest-allimages.json",
+		"../../docs/examples/manifest-v1.json",
+		"../../bin/image-manifests/2.5.0.json",
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if _, err := ParseManifest(data); err != nil {
+			t.Errorf("ParseManifest(%s) error = %v", path, err)
+		}
+	}
+}
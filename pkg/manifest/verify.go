@@ -0,0 +1,138 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+)
+
+const (
+	// VerificationKeyEnvVar is the path of a PEM encoded public key. When set, image manifests and
+	// image override configmaps must carry a detached signature made with the matching private key.
+	VerificationKeyEnvVar = "MANIFEST_VERIFICATION_KEY"
+	// RequireDigestsEnvVar rejects image references that are not pinned by digest when set to true
+	RequireDigestsEnvVar = "MANIFEST_REQUIRE_DIGESTS"
+	// SignatureSuffix is appended to a manifest file name or configmap key to locate its detached signature
+	SignatureSuffix = ".sig"
+)
+
+// VerificationError is returned when image references fail the verification policy
+type VerificationError struct {
+	Reason string
+}
+
+func (e *VerificationError) Error() string {
+	return e.Reason
+}
+
+// IsVerificationError returns true if the error is a verification policy failure
+func IsVerificationError(err error) bool {
+	var verr *VerificationError
+	return errors.As(err, &verr)
+}
+
+// VerificationPolicy describes the checks image references must pass before they are used. The zero
+// value, and a nil policy, accept everything.
+type VerificationPolicy struct {
+	// PublicKey verifies detached signatures. Signatures are not checked when nil.
+	PublicKey crypto.PublicKey
+	// RequireDigests rejects image references that are not pinned by digest
+	RequireDigests bool
+}
+
+// LoadVerificationPolicy reads the verification policy from the operator environment
+func LoadVerificationPolicy() (*VerificationPolicy, error) {
+	policy := &VerificationPolicy{
+		RequireDigests: strings.EqualFold(os.Getenv(RequireDigestsEnvVar), "true"),
+	}
+
+	keyPath := os.Getenv(VerificationKeyEnvVar)
+	if keyPath == "" {
+		return policy, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(keyPath)) // #nosec G304 (filepath cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest verification key: %w", err)
+	}
+	policy.PublicKey, err = ParsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest verification key %s: %w", keyPath, err)
+	}
+	return policy, nil
+}
+
+// ParsePublicKey parses a PEM encoded RSA, ECDSA or Ed25519 public key
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// VerifiesSignatures returns true if detached signatures are required
+func (p *VerificationPolicy) VerifiesSignatures() bool {
+	return p != nil && p.PublicKey != nil
+}
+
+// VerifySignature checks a detached signature over data. RSA keys expect a PKCS #1 v1.5 signature and
+// ECDSA keys an ASN.1 signature, both over the SHA-256 digest, as produced by `openssl dgst -sha256 -sign`.
+// Ed25519 keys sign the data itself.
+func (p *VerificationPolicy) VerifySignature(name string, data, signature []byte) error {
+	if !p.VerifiesSignatures() {
+		return nil
+	}
+	if len(signature) == 0 {
+		return &VerificationError{Reason: fmt.Sprintf("%s has no signature", name)}
+	}
+
+	digest := sha256.Sum256(data)
+	valid := false
+	switch key := p.PublicKey.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, data, signature)
+	}
+	if !valid {
+		return &VerificationError{Reason: fmt.Sprintf("%s signature does not match the verification key", name)}
+	}
+	return nil
+}
+
+// CheckDigests returns an error naming the image keys whose references are not pinned by digest
+func (p *VerificationPolicy) CheckDigests(images map[string]string) error {
+	if p == nil || !p.RequireDigests {
+		return nil
+	}
+	tagged := utils.TagReferences(images)
+	if len(tagged) == 0 {
+		return nil
+	}
+	return &VerificationError{Reason: fmt.Sprintf("image references must be pinned by digest: %s", strings.Join(tagged, ", "))}
+}
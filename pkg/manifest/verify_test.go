@@ -0,0 +1,162 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package manifest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/version"
+)
+
+// testSigner returns a public key and a function signing data the way VerifySignature expects
+func testSigner(t *testing.T, kind string) (crypto.PublicKey, func([]byte) []byte) {
+	t.Helper()
+	switch kind {
+	case "rsa":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &key.PublicKey, func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sig
+		}
+	case "ecdsa":
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &key.PublicKey, func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sig
+		}
+	default:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pub, func(data []byte) []byte { return ed25519.Sign(key, data) }
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	data := []byte(`[{"image-key":"console"}]`)
+	for _, kind := range []string{"rsa", "ecdsa", "ed25519"} {
+		t.Run(kind, func(t *testing.T) {
+			pub, sign := testSigner(t, kind)
+			otherPub, _ := testSigner(t, kind)
+
+			policy := &VerificationPolicy{PublicKey: pub}
+			if err := policy.VerifySignature("manifest", data, sign(data)); err != nil {
+				t.Errorf("VerifySignature() error = %v", err)
+			}
+			if err := policy.VerifySignature("manifest", append(data, ' '), sign(data)); !IsVerificationError(err) {
+				t.Errorf("VerifySignature() of modified data error = %v, want verification error", err)
+			}
+			if err := policy.VerifySignature("manifest", data, nil); !IsVerificationError(err) {
+				t.Errorf("VerifySignature() without signature error = %v, want verification error", err)
+			}
+			other := &VerificationPolicy{PublicKey: otherPub}
+			if err := other.VerifySignature("manifest", data, sign(data)); !IsVerificationError(err) {
+				t.Errorf("VerifySignature() with another key error = %v, want verification error", err)
+			}
+		})
+	}
+
+	var disabled *VerificationPolicy
+	if err := disabled.VerifySignature("manifest", data, nil); err != nil {
+		t.Errorf("VerifySignature() with nil policy error = %v", err)
+	}
+}
+
+func TestCheckDigests(t *testing.T) {
+	images := map[string]string{
+		"console":     "quay.io/stolostron/console@sha256:abc",
+		"search":      "quay.io/stolostron/search:2.5",
+		"grc":         "quay.io/stolostron/grc@",
+		"oauth_proxy": "",
+	}
+	if err := (&VerificationPolicy{}).CheckDigests(images); err != nil {
+		t.Errorf("CheckDigests() without RequireDigests error = %v", err)
+	}
+	err := (&VerificationPolicy{RequireDigests: true}).CheckDigests(images)
+	if !IsVerificationError(err) || err.Error() != "image references must be pinned by digest: grc, search" {
+		t.Errorf("CheckDigests() error = %v", err)
+	}
+}
+
+func TestGetVerifiedImageOverrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(ManifestsPathEnvVar, dir)
+	manifestPath := filepath.Join(dir, version.Version+".json")
+	data := []byte(`[{"image-key":"console","image-name":"console","image-remote":"quay.io/stolostron","image-digest":"sha256:abc"}]`)
+	if err := ioutil.WriteFile(manifestPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, sign := testSigner(t, "ecdsa")
+	policy := &VerificationPolicy{PublicKey: pub, RequireDigests: true}
+	mch := &operatorsv1.MultiClusterHub{}
+
+	if _, err := GetVerifiedImageOverrides(mch, policy); !IsVerificationError(err) {
+		t.Errorf("GetVerifiedImageOverrides() of unsigned manifest error = %v, want verification error", err)
+	}
+
+	if err := ioutil.WriteFile(manifestPath+SignatureSuffix, sign(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	images, err := GetVerifiedImageOverrides(mch, policy)
+	if err != nil {
+		t.Fatalf("GetVerifiedImageOverrides() error = %v", err)
+	}
+	if images["console"] != "quay.io/stolostron/console@sha256:abc" {
+		t.Errorf("GetVerifiedImageOverrides() = %v", images)
+	}
+}
+
+func TestLoadVerificationPolicy(t *testing.T) {
+	pub, _ := testSigner(t, "ed25519")
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(VerificationKeyEnvVar, keyPath)
+	t.Setenv(RequireDigestsEnvVar, "true")
+	policy, err := LoadVerificationPolicy()
+	if err != nil {
+		t.Fatalf("LoadVerificationPolicy() error = %v", err)
+	}
+	if !policy.VerifiesSignatures() || !policy.RequireDigests {
+		t.Errorf("LoadVerificationPolicy() = %+v, want signatures and digests required", policy)
+	}
+
+	t.Setenv(VerificationKeyEnvVar, filepath.Join(t.TempDir(), "missing.pem"))
+	if _, err := LoadVerificationPolicy(); err == nil {
+		t.Error("LoadVerificationPolicy() with a missing key should error")
+	}
+}
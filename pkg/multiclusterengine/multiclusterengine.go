@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	olmv1 "github.com/operator-framework/api/pkg/operators/v1"
 	subv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
@@ -37,7 +38,9 @@ func labels(m *operatorsv1.MultiClusterHub) map[string]string {
 	}
 }
 
-func MultiClusterEngine(m *operatorsv1.MultiClusterHub) *mcev1.MultiClusterEngine {
+// MultiClusterEngine returns the multiclusterengine of the hub. images are the related images of the
+// installed multiclusterengine, keyed by name, against which registry mirror rules are checked.
+func MultiClusterEngine(m *operatorsv1.MultiClusterHub, images map[string]string) *mcev1.MultiClusterEngine {
 	mce := &mcev1.MultiClusterEngine{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: mcev1.GroupVersion.String(),
@@ -46,7 +49,7 @@ func MultiClusterEngine(m *operatorsv1.MultiClusterHub) *mcev1.MultiClusterEngin
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        MulticlusterengineName,
 			Labels:      labels(m),
-			Annotations: GetSupportedAnnotations(m),
+			Annotations: GetSupportedAnnotations(m, images),
 		},
 		Spec: mcev1.MultiClusterEngineSpec{
 			ImagePullSecret: m.Spec.ImagePullSecret,
@@ -60,17 +63,42 @@ func MultiClusterEngine(m *operatorsv1.MultiClusterHub) *mcev1.MultiClusterEngin
 	return mce
 }
 
-// GetSupportedAnnotations ...
-func GetSupportedAnnotations(m *operatorsv1.MultiClusterHub) map[string]string {
+// GetSupportedAnnotations returns the multiclusterhub settings passed on as multiclusterengine annotations.
+// Registry mirror rules are passed on only when MirrorImageRepository can represent them for the related
+// images of the multiclusterengine.
+func GetSupportedAnnotations(m *operatorsv1.MultiClusterHub, images map[string]string) map[string]string {
 	mceAnnotations := make(map[string]string)
-	if m.GetAnnotations() != nil {
-		if val, ok := m.GetAnnotations()[utils.AnnotationImageRepo]; ok && val != "" {
-			mceAnnotations["imageRepository"] = val
+	if mirrors := utils.GetRegistryMirrors(m); len(mirrors) > 0 {
+		if repo, ok := MirrorImageRepository(mirrors, images); ok {
+			mceAnnotations["imageRepository"] = repo
 		}
+	} else if repo := utils.GetImageRepository(m); repo != "" {
+		mceAnnotations["imageRepository"] = repo
 	}
 	return mceAnnotations
 }
 
+// MirrorImageRepository returns the imageRepository annotation that makes the multiclusterengine pull each
+// of its images, keyed by name, from where the registry mirror rules send it. The multiclusterengine
+// replaces everything before the image name with its imageRepository, so the rules can only be passed on
+// when they send every image to the same repository and keep its name. It returns false when they do not,
+// or when there are no images to check the rules against.
+func MirrorImageRepository(mirrors []operatorsv1.RegistryMirror, images map[string]string) (string, bool) {
+	repo := ""
+	for _, ref := range images {
+		mirrored := utils.MirrorImageReference(ref, mirrors)
+		i, j := strings.LastIndex(mirrored, "/"), strings.LastIndex(ref, "/")
+		if i < 0 || j < 0 || mirrored[i:] != ref[j:] {
+			return "", false
+		}
+		if repo != "" && mirrored[:i] != repo {
+			return "", false
+		}
+		repo = mirrored[:i]
+	}
+	return repo, repo != ""
+}
+
 // Subscription for the helm repo serving charts
 func Subscription(m *operatorsv1.MultiClusterHub, c *subv1alpha1.SubscriptionConfig) *subv1alpha1.Subscription {
 	sub := &subv1alpha1.Subscription{
@@ -124,3 +124,101 @@ func TestSubscription(t *testing.T) {
 		})
 	}
 }
+
+func TestGetSupportedAnnotations(t *testing.T) {
+	images := map[string]string{
+		"assisted_service":           "registry.redhat.io/multicluster-engine/assisted-service-rhel8@sha256:abc",
+		"cluster_curator_controller": "registry.redhat.io/multicluster-engine/cluster-curator-controller-rhel8@sha256:def",
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		mirrors     []operatorsv1.RegistryMirror
+		want        map[string]string
+	}{
+		{
+			name: "No image repository",
+			want: map[string]string{},
+		},
+		{
+			name:        "Image repository annotation",
+			annotations: map[string]string{"mch-imageRepository": "quay.io/stolostron"},
+			want:        map[string]string{"imageRepository": "quay.io/stolostron"},
+		},
+		{
+			name:        "Registry mirror supersedes annotation",
+			annotations: map[string]string{"mch-imageRepository": "quay.io/stolostron"},
+			mirrors:     []operatorsv1.RegistryMirror{{Source: "registry.redhat.io", Mirror: "mirror.example.com"}},
+			want:        map[string]string{"imageRepository": "mirror.example.com/multicluster-engine"},
+		},
+		{
+			name:        "Registry mirrors the multiclusterengine cannot represent",
+			annotations: map[string]string{"mch-imageRepository": "quay.io/stolostron"},
+			mirrors: []operatorsv1.RegistryMirror{
+				{Source: "registry.redhat.io/multicluster-engine/assisted-service-rhel8", Mirror: "mirror.example.com/assisted"},
+				{Source: "registry.redhat.io", Mirror: "other.example.com"},
+			},
+			want: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &operatorsv1.MultiClusterHub{Spec: operatorsv1.MultiClusterHubSpec{RegistryMirrors: tt.mirrors}}
+			m.SetAnnotations(tt.annotations)
+			if got := GetSupportedAnnotations(m, images); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetSupportedAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMirrorImageRepository(t *testing.T) {
+	images := map[string]string{
+		"assisted_service": "registry.redhat.io/multicluster-engine/assisted-service-rhel8@sha256:abc",
+		"hive":             "registry.redhat.io/multicluster-engine/hive-rhel8@sha256:def",
+	}
+	tests := []struct {
+		name    string
+		mirrors []operatorsv1.RegistryMirror
+		images  map[string]string
+		want    string
+		wantOk  bool
+	}{
+		{
+			name:    "Registry mirror",
+			mirrors: []operatorsv1.RegistryMirror{{Source: "registry.redhat.io", Mirror: "mirror.example.com:5000"}},
+			images:  images,
+			want:    "mirror.example.com:5000/multicluster-engine",
+			wantOk:  true,
+		},
+		{
+			name:    "Repository mirror",
+			mirrors: []operatorsv1.RegistryMirror{{Source: "registry.redhat.io/multicluster-engine", Mirror: "mirror.example.com/mce"}},
+			images:  images,
+			want:    "mirror.example.com/mce",
+			wantOk:  true,
+		},
+		{
+			name:    "Images sent to different repositories",
+			mirrors: []operatorsv1.RegistryMirror{{Source: "registry.redhat.io/multicluster-engine/hive-rhel8", Mirror: "mirror.example.com/hive/hive-rhel8"}},
+			images:  images,
+		},
+		{
+			name:    "Image renamed",
+			mirrors: []operatorsv1.RegistryMirror{{Source: "registry.redhat.io/multicluster-engine/hive-rhel8", Mirror: "registry.redhat.io/multicluster-engine/hive"}},
+			images:  images,
+		},
+		{
+			name:    "No images",
+			mirrors: []operatorsv1.RegistryMirror{{Source: "registry.redhat.io", Mirror: "mirror.example.com"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := MirrorImageRepository(tt.mirrors, tt.images)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("MirrorImageRepository() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
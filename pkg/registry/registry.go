@@ -0,0 +1,384 @@
+// Copyright Contributors to the Open Cluster Management project
+
+// Package registry looks up image manifests with the registry v2 API to check that image references
+// can be pulled before they are rolled out.
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+)
+
+const (
+	dockerHubHost     = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+	// maxConcurrentChecks bounds the number of manifest requests in flight
+	maxConcurrentChecks = 8
+)
+
+// manifestMediaTypes are accepted on manifest requests so that registries answer for image indexes as
+// well as single platform manifests
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Auth holds the credentials for one registry
+type Auth struct {
+	Username string
+	Password string
+}
+
+// Credentials maps a registry host, optionally followed by a repository path, to its credentials
+type Credentials map[string]Auth
+
+// ParseDockerConfigJSON reads the credentials of a kubernetes.io/dockerconfigjson pull secret
+func ParseDockerConfigJSON(data []byte) (Credentials, error) {
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	creds := Credentials{}
+	for key, entry := range config.Auths {
+		auth := Auth{Username: entry.Username, Password: entry.Password}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for %s: %w", key, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid auth for %s: expected username:password", key)
+			}
+			auth = Auth{Username: parts[0], Password: parts[1]}
+		}
+		key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+		creds[strings.TrimSuffix(key, "/")] = auth
+	}
+	return creds, nil
+}
+
+// lookup returns the credentials with the longest key matching the repository, which is given
+// with its registry host
+func (c Credentials) lookup(repository string) (Auth, bool) {
+	for prefix := repository; prefix != ""; {
+		if auth, ok := c[prefix]; ok {
+			return auth, true
+		}
+		i := strings.LastIndex(prefix, "/")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return Auth{}, false
+}
+
+// Result is the outcome of looking up one image reference
+type Result struct {
+	Reference string
+	// Available is true when the registry has the manifest
+	Available bool
+	// Resolved is the reference pinned to the digest of the manifest, set by ResolveDigests
+	Resolved string
+	// Err describes why the manifest could not be found, or why the registry could not be asked
+	Err error
+}
+
+// Client checks image manifests against their registries over HTTPS
+type Client struct {
+	// HTTPClient sends the registry requests. http.DefaultClient is used when nil.
+	HTTPClient  *http.Client
+	Credentials Credentials
+}
+
+// NewClient returns a client that authenticates with the given credentials
+func NewClient(httpClient *http.Client, creds Credentials) *Client {
+	return &Client{HTTPClient: httpClient, Credentials: creds}
+}
+
+// CheckImages looks up every image reference, a few at a time, and returns the results by image key
+func (c *Client) CheckImages(ctx context.Context, images map[string]string) map[string]Result {
+	return c.lookupAll(ctx, images, func(ctx context.Context, ref string) (string, error) {
+		return "", c.ManifestExists(ctx, ref)
+	})
+}
+
+// ResolveDigests resolves every image reference to a digest reference, a few at a time, and returns the
+// results by image key
+func (c *Client) ResolveDigests(ctx context.Context, images map[string]string) map[string]Result {
+	return c.lookupAll(ctx, images, c.ResolveDigest)
+}
+
+// lookupAll calls lookup for every image reference with a bounded number of calls in flight
+func (c *Client) lookupAll(ctx context.Context, images map[string]string, lookup func(context.Context, string) (string, error)) map[string]Result {
+	keys := make([]string, 0, len(images))
+	for key := range images {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := make(map[string]Result, len(images))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentChecks)
+	for _, key := range keys {
+		key, ref := key, images[key]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resolved, err := lookup(ctx, ref)
+			mu.Lock()
+			results[key] = Result{Reference: ref, Available: err == nil, Resolved: resolved, Err: err}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// ManifestExists sends a HEAD request for the manifest of the image reference. It returns nil when the
+// registry has the manifest, and otherwise an error saying whether the manifest is missing, access was
+// denied or the registry could not be reached.
+func (c *Client) ManifestExists(ctx context.Context, ref string) error {
+	_, err := c.manifestDigest(ctx, ref)
+	return err
+}
+
+// ResolveDigest returns the image reference pinned to the digest the registry reports for its manifest.
+// References that already carry a digest are returned unchanged.
+func (c *Client) ResolveDigest(ctx context.Context, ref string) (string, error) {
+	if utils.ImageHasDigest(ref) {
+		return ref, nil
+	}
+	digest, err := c.manifestDigest(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if digest == "" {
+		return "", fmt.Errorf("registry did not report the digest of %s", ref)
+	}
+	name, _, _ := utils.SplitImageReference(ref)
+	return name + "@" + digest, nil
+}
+
+// manifestDigest looks up the manifest of the image reference and returns the digest reported in the
+// Docker-Content-Digest header, which may be empty
+func (c *Client) manifestDigest(ctx context.Context, ref string) (string, error) {
+	host, repository, reference, err := parseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repository, reference)
+
+	resp, err := c.headManifest(ctx, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization, err := c.authorize(ctx, resp.Header.Get("WWW-Authenticate"), registryKey(host, repository))
+		if err != nil {
+			return "", err
+		}
+		if authorization != "" {
+			if resp, err = c.headManifest(ctx, manifestURL, authorization); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("Docker-Content-Digest"), nil
+	case http.StatusNotFound:
+		return "", fmt.Errorf("manifest unknown in %s/%s", host, repository)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", fmt.Errorf("access to %s/%s denied: %s", host, repository, resp.Status)
+	default:
+		return "", fmt.Errorf("unexpected response from %s: %s", host, resp.Status)
+	}
+}
+
+func (c *Client) headManifest(ctx context.Context, manifestURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// authorize answers an authentication challenge, returning the Authorization header to retry with, or
+// an empty string when the challenge cannot be answered
+func (c *Client) authorize(ctx context.Context, challenge, repository string) (string, error) {
+	auth, hasAuth := c.Credentials.lookup(repository)
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasAuth {
+			return "", nil
+		}
+		return "Basic " + basicAuth(auth), nil
+	case "bearer":
+		token, err := c.fetchToken(ctx, params, auth, hasAuth)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	return "", nil
+}
+
+// fetchToken requests a pull token from the realm of a bearer challenge
+func (c *Client) fetchToken(ctx context.Context, params map[string]string, auth Auth, hasAuth bool) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	query := realm.Query()
+	for _, p := range []string{"service", "scope"} {
+		if params[p] != "" {
+			query.Set(p, params[p])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasAuth {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request to %s failed: %s", realm.Host, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token response from %s: %w", realm.Host, err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// parseReference splits an image reference into the registry host to contact, the repository and the
+// digest or tag of the manifest
+func parseReference(ref string) (host, repository, reference string, err error) {
+	name, tag, digest := utils.SplitImageReference(ref)
+	reference = digest
+	if reference == "" {
+		reference = tag
+	}
+	if reference == "" {
+		reference = "latest"
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		host, repository = parts[0], parts[1]
+	} else {
+		host, repository = dockerHubHost, name
+	}
+	if host == dockerHubHost {
+		host = dockerHubRegistry
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+	if repository == "" {
+		return "", "", "", fmt.Errorf("invalid image reference %q", ref)
+	}
+	return host, repository, reference, nil
+}
+
+// registryKey returns the name under which pull secrets list the repository
+func registryKey(host, repository string) string {
+	if host == dockerHubRegistry {
+		host = dockerHubHost
+	}
+	return host + "/" + repository
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and parameters
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+	for _, param := range splitParams(parts[1]) {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		}
+	}
+	return parts[0], params
+}
+
+// splitParams splits challenge parameters on commas outside of quoted values
+func splitParams(s string) []string {
+	params := []string{}
+	quoted := false
+	start := 0
+	for i, ch := range s {
+		switch {
+		case ch == '"':
+			quoted = !quoted
+		case ch == ',' && !quoted:
+			params = append(params, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(params, s[start:])
+}
+
+func basicAuth(auth Auth) string {
+	return base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+}
@@ -0,0 +1,178 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestRegistry serves the given manifests, keyed by repository and reference. Requests must carry a
+// bearer token obtained with the test credentials.
+func newTestRegistry(t *testing.T, manifests map[string]bool) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "puller" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"pull-token"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:x:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodHead || !strings.HasPrefix(r.URL.Path, "/v2/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if manifests[strings.TrimPrefix(r.URL.Path, "/v2/")] {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_CheckImages(t *testing.T) {
+	manifests := map[string]bool{
+		"stolostron/console/manifests/sha256:abc": true,
+		"stolostron/search/manifests/2.5.0":       true,
+	}
+	server := newTestRegistry(t, manifests)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	images := map[string]string{
+		"console": host + "/stolostron/console@sha256:abc",
+		"search":  host + "/stolostron/search:2.5.0",
+		"grc":     host + "/stolostron/grc@sha256:def",
+	}
+
+	tests := []struct {
+		name  string
+		creds Credentials
+		want  map[string]bool
+	}{
+		{
+			name:  "Authenticated",
+			creds: Credentials{host: {Username: "puller", Password: "secret"}},
+			want:  map[string]bool{"console": true, "search": true, "grc": false},
+		},
+		{
+			name:  "Credentials for a repository path",
+			creds: Credentials{host + "/stolostron": {Username: "puller", Password: "secret"}},
+			want:  map[string]bool{"console": true, "search": true, "grc": false},
+		},
+		{
+			name: "Missing credentials",
+			want: map[string]bool{"console": false, "search": false, "grc": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := NewClient(server.Client(), tt.creds).CheckImages(context.TODO(), images)
+			for key, want := range tt.want {
+				if got := results[key]; got.Available != want || (got.Err == nil) != want {
+					t.Errorf("CheckImages() %s = %+v, want available %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_ResolveDigests(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/stolostron/console/manifests/2.5.0":
+			w.Header().Set("Docker-Content-Digest", "sha256:abc")
+		case "/v2/stolostron/search/manifests/2.5.0":
+			// a registry that does not report digests
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	results := NewClient(server.Client(), nil).ResolveDigests(context.TODO(), map[string]string{
+		"console": host + "/stolostron/console:2.5.0",
+		"search":  host + "/stolostron/search:2.5.0",
+		"grc":     host + "/stolostron/grc:2.5.0",
+		"pinned":  host + "/stolostron/insights@sha256:def",
+	})
+	if got := results["console"]; got.Err != nil || got.Resolved != host+"/stolostron/console@sha256:abc" {
+		t.Errorf("ResolveDigests() console = %+v", got)
+	}
+	if got := results["pinned"]; got.Err != nil || got.Resolved != host+"/stolostron/insights@sha256:def" {
+		t.Errorf("ResolveDigests() pinned = %+v", got)
+	}
+	for _, key := range []string{"search", "grc"} {
+		if got := results[key]; got.Err == nil {
+			t.Errorf("ResolveDigests() %s = %+v, want an error", key, got)
+		}
+	}
+}
+
+func TestClient_ManifestExists_unreachable(t *testing.T) {
+	server := newTestRegistry(t, nil)
+	host := strings.TrimPrefix(server.URL, "https://")
+	server.Close()
+
+	if err := NewClient(nil, nil).ManifestExists(context.TODO(), host+"/stolostron/console:latest"); err == nil {
+		t.Errorf("ManifestExists() did not return an error for an unreachable registry")
+	}
+}
+
+func TestParseDockerConfigJSON(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("puller:se:cret"))
+	data := fmt.Sprintf(`{"auths":{"https://quay.io/":{"auth":"%s"},"registry.example.com/acm":{"username":"u","password":"p"}}}`, auth)
+
+	creds, err := ParseDockerConfigJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseDockerConfigJSON() error = %v", err)
+	}
+	if got := creds["quay.io"]; got != (Auth{Username: "puller", Password: "se:cret"}) {
+		t.Errorf("ParseDockerConfigJSON() quay.io = %+v", got)
+	}
+	if got, _ := creds.lookup("registry.example.com/acm/console"); got != (Auth{Username: "u", Password: "p"}) {
+		t.Errorf("lookup() registry.example.com/acm/console = %+v", got)
+	}
+	if _, ok := creds.lookup("registry.example.com/other/console"); ok {
+		t.Errorf("lookup() matched credentials of another repository")
+	}
+
+	if _, err := ParseDockerConfigJSON([]byte(`{"auths":{"quay.io":{"auth":"bm9jb2xvbg=="}}}`)); err == nil {
+		t.Errorf("ParseDockerConfigJSON() accepted auth without a password")
+	}
+}
+
+func Test_parseReference(t *testing.T) {
+	tests := []struct {
+		ref                         string
+		host, repository, reference string
+	}{
+		{"quay.io/stolostron/console@sha256:abc", "quay.io", "stolostron/console", "sha256:abc"},
+		{"mirror.example.com:5000/acm/console:2.5", "mirror.example.com:5000", "acm/console", "2.5"},
+		{"localhost/console", "localhost", "console", "latest"},
+		{"busybox", dockerHubRegistry, "library/busybox", "latest"},
+		{"docker.io/org/app:1", dockerHubRegistry, "org/app", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			host, repository, reference, err := parseReference(tt.ref)
+			if err != nil || host != tt.host || repository != tt.repository || reference != tt.reference {
+				t.Errorf("parseReference() = %s, %s, %s, %v, want %s, %s, %s", host, repository, reference, err, tt.host, tt.repository, tt.reference)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package rendering
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ReleaseLabel names the release an object was rendered for
+	ReleaseLabel = "installer.open-cluster-management.io/release"
+	// RecordLabel names the release a configmap records the objects of
+	RecordLabel = "installer.open-cluster-management.io/release-record"
+
+	// Annotations helm sets on the objects of a release, which status uses to find release deployments
+	ReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	ReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	// ChartVersionAnnotation holds the version of the chart an object was rendered from
+	ChartVersionAnnotation = "installer.open-cluster-management.io/chart-version"
+
+	// recordPrefix starts the name of the configmap recording the objects of a release
+	recordPrefix = "mch-release-"
+	// recordObjectsKey holds the objects of a release in its record
+	recordObjectsKey = "objects"
+	// recordChartKey holds the chart and version of a release in its record
+	recordChartKey = "chart"
+)
+
+// Release is a chart installed by the operator without an appsub
+type Release struct {
+	// Name of the release, which is the name of the appsub the chart would otherwise be installed by
+	Name      string
+	Namespace string
+	Chart     string
+	Version   string
+	// Values passed to the chart, the same the appsub passes as packageOverrides
+	Values map[string]interface{}
+}
+
+// ObjectRef identifies an object applied for a release
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// FromSubscription returns the release installing the chart an appsub subscribes to, with the values the
+// appsub overrides
+func FromSubscription(sub *unstructured.Unstructured) (*Release, error) {
+	spec, ok := sub.Object["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("subscription %s has no spec", sub.GetName())
+	}
+	rel := &Release{Name: sub.GetName(), Namespace: sub.GetNamespace(), Values: map[string]interface{}{}}
+	rel.Chart, _ = spec["name"].(string)
+	if filter, ok := spec["packageFilter"].(map[string]interface{}); ok {
+		rel.Version, _ = filter["version"].(string)
+	}
+	if rel.Chart == "" || rel.Version == "" {
+		return nil, fmt.Errorf("subscription %s does not pin a chart version", sub.GetName())
+	}
+
+	for _, pkg := range objectList(spec["packageOverrides"]) {
+		if pkg["packageName"] != rel.Chart {
+			continue
+		}
+		for _, o := range objectList(pkg["packageOverrides"]) {
+			if o["path"] != "spec" {
+				continue
+			}
+			values, err := normalize(o["value"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid values in subscription %s: %w", sub.GetName(), err)
+			}
+			rel.Values = values
+		}
+	}
+	return rel, nil
+}
+
+// normalize converts values built in code, such as typed tolerations, to the plain JSON values the
+// subscription operator passes to the chart
+func normalize(v interface{}) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if v == nil {
+		return values, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// objectList reads a list of objects built either in code or by decoding JSON
+func objectList(v interface{}) []map[string]interface{} {
+	switch l := v.(type) {
+	case []map[string]interface{}:
+		return l
+	case []interface{}:
+		objects := []map[string]interface{}{}
+		for _, item := range l {
+			if o, ok := item.(map[string]interface{}); ok {
+				objects = append(objects, o)
+			}
+		}
+		return objects
+	}
+	return nil
+}
+
+// Capabilities returns the capabilities charts are rendered against, the way helm install discovers them:
+// the server version and every group/version and group/version/kind the cluster serves. Groups that fail
+// discovery, such as those of an unavailable aggregated API, are left out.
+func Capabilities(dc discovery.DiscoveryInterface) (*chartutil.Capabilities, error) {
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the server version: %w", err)
+	}
+	groups, resources, err := dc.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover the served APIs: %w", err)
+	}
+
+	versions := map[string]bool{}
+	for _, g := range groups {
+		for _, gv := range g.Versions {
+			versions[gv.GroupVersion] = true
+		}
+	}
+	for _, list := range resources {
+		versions[list.GroupVersion] = true
+		for _, res := range list.APIResources {
+			versions[list.GroupVersion+"/"+res.Kind] = true
+		}
+	}
+	apiVersions := make(chartutil.VersionSet, 0, len(versions))
+	for v := range versions {
+		apiVersions = append(apiVersions, v)
+	}
+	sort.Strings(apiVersions)
+
+	return &chartutil.Capabilities{
+		KubeVersion: chartutil.KubeVersion{Version: info.GitVersion, Major: info.Major, Minor: info.Minor},
+		APIVersions: apiVersions,
+		HelmVersion: chartutil.DefaultCapabilities.HelmVersion,
+	}, nil
+}
+
+// Render renders a packaged chart for the release the way helm install does, against the capabilities of
+// the cluster, returning the CRDs of the chart and its other objects in install order. Like helm template,
+// objects are left without a namespace unless the chart sets one. Charts defining hooks fail to render, as
+// the hooks would not be run.
+func Render(archive []byte, rel *Release, caps *chartutil.Capabilities) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	chrt, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load chart %s: %w", rel.Chart, err)
+	}
+	if chrt.Metadata.Name != rel.Chart || chrt.Metadata.Version != rel.Version {
+		return nil, nil, fmt.Errorf("archive holds chart %s %s, expected %s %s",
+			chrt.Metadata.Name, chrt.Metadata.Version, rel.Chart, rel.Version)
+	}
+
+	if err := chartutil.ProcessDependencies(chrt, rel.Values); err != nil {
+		return nil, nil, fmt.Errorf("failed to process the dependencies of chart %s: %w", rel.Chart, err)
+	}
+	options := chartutil.ReleaseOptions{Name: rel.Name, Namespace: rel.Namespace, Revision: 1, IsInstall: true}
+	values, err := chartutil.ToRenderValues(chrt, rel.Values, options, caps)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid values for chart %s: %w", rel.Chart, err)
+	}
+	files, err := engine.Render(chrt, values)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render chart %s: %w", rel.Chart, err)
+	}
+	for name := range files {
+		if strings.HasSuffix(name, "NOTES.txt") {
+			delete(files, name)
+		}
+	}
+	hooks, manifests, err := releaseutil.SortManifests(files, caps.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sort the manifests of chart %s: %w", rel.Chart, err)
+	}
+	if len(hooks) > 0 {
+		names := []string{}
+		for _, h := range hooks {
+			names = append(names, fmt.Sprintf("%s %s (%s)", h.Kind, h.Name, h.Path))
+		}
+		return nil, nil, fmt.Errorf("chart %s defines hooks, which are not run when rendering charts: %s",
+			rel.Chart, strings.Join(names, ", "))
+	}
+
+	crds := []*unstructured.Unstructured{}
+	for _, crd := range chrt.CRDObjects() {
+		docs := releaseutil.SplitManifests(string(crd.File.Data))
+		keys := make([]string, 0, len(docs))
+		for k := range docs {
+			keys = append(keys, k)
+		}
+		sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+		for _, k := range keys {
+			obj, err := decode(docs[k], crd.Filename)
+			if err != nil {
+				return nil, nil, err
+			}
+			if obj != nil {
+				labelForRelease(obj, rel)
+				crds = append(crds, obj)
+			}
+		}
+	}
+
+	objects := []*unstructured.Unstructured{}
+	for _, m := range manifests {
+		obj, err := decode(m.Content, m.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if obj == nil {
+			continue
+		}
+		labelForRelease(obj, rel)
+		objects = append(objects, obj)
+	}
+	return crds, objects, nil
+}
+
+// ValidateValues checks the values of the release, merged over the chart defaults, against the values schema
+// of a packaged chart. Charts without a schema accept any values.
+func ValidateValues(archive []byte, rel *Release) error {
+	chrt, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("failed to load chart %s: %w", rel.Chart, err)
+	}
+	if chrt.Schema == nil {
+		return nil
+	}
+	values, err := chartutil.CoalesceValues(chrt, rel.Values)
+	if err != nil {
+		return err
+	}
+	return chartutil.ValidateAgainstSchema(chrt, values)
+}
+
+// decode parses a rendered manifest, returning nil for documents without content
+func decode(content, source string) (*unstructured.Unstructured, error) {
+	data, err := yaml.YAMLToJSON([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	if s := strings.TrimSpace(string(data)); s == "null" || s == "{}" {
+		return nil, nil
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	if u.GetName() == "" {
+		return nil, fmt.Errorf("%s renders a %s without a name", source, u.GetKind())
+	}
+	return u, nil
+}
+
+// labelForRelease marks an object as belonging to the release, with the annotations helm would set
+func labelForRelease(obj *unstructured.Unstructured, rel *Release) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ReleaseLabel] = rel.Name
+	obj.SetLabels(labels)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ReleaseNameAnnotation] = rel.Name
+	annotations[ReleaseNamespaceAnnotation] = rel.Namespace
+	annotations[ChartVersionAnnotation] = rel.Version
+	obj.SetAnnotations(annotations)
+}
+
+// Refs returns references to the objects
+func Refs(objects []*unstructured.Unstructured) []ObjectRef {
+	refs := make([]ObjectRef, 0, len(objects))
+	for _, obj := range objects {
+		refs = append(refs, ObjectRef{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		})
+	}
+	return refs
+}
+
+// Object returns an empty object the reference points to, suitable for a get or delete
+func (ref ObjectRef) Object() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+	u.SetNamespace(ref.Namespace)
+	u.SetName(ref.Name)
+	return u
+}
+
+// RecordName returns the name of the configmap recording the objects of a release
+func RecordName(release string) string {
+	return recordPrefix + release
+}
+
+// Record returns the configmap recording the chart and objects of a release, from which the objects are
+// pruned and removed
+func Record(rel *Release, namespace string, refs []ObjectRef) (*corev1.ConfigMap, error) {
+	data, err := json.Marshal(refs)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      RecordName(rel.Name),
+			Namespace: namespace,
+			Labels:    map[string]string{RecordLabel: rel.Name},
+		},
+		Data: map[string]string{
+			recordChartKey:   rel.Chart + "-" + rel.Version,
+			recordObjectsKey: string(data),
+		},
+	}, nil
+}
+
+// RecordedObjects returns the objects a release record lists
+func RecordedObjects(cm *corev1.ConfigMap) ([]ObjectRef, error) {
+	refs := []ObjectRef{}
+	if data := cm.Data[recordObjectsKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &refs); err != nil {
+			return nil, fmt.Errorf("invalid release record %s: %w", cm.Name, err)
+		}
+	}
+	return refs, nil
+}
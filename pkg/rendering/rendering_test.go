@@ -0,0 +1,241 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package rendering
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/subscription"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}-controller
+  annotations:
+    kube-version: {{ .Capabilities.KubeVersion.Version }}
+spec:
+  replicas: {{ .Values.hubconfig.replicaCount }}
+  template:
+    spec:
+      containers:
+      - name: controller
+        image: {{ .Values.global.imageOverrides.grc_policy_propagator }}
+      tolerations:
+{{ toYaml .Values.hubconfig.tolerations | indent 6 }}
+`
+
+const testServices = `apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Release.Name }}-metrics
+---
+# documents without content are dropped
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: {{ .Release.Name }}-role
+`
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: policies.policy.open-cluster-management.io
+`
+
+const testPolicy = `{{- if .Capabilities.APIVersions.Has "policy.open-cluster-management.io/v1/Policy" }}
+apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: {{ .Release.Name }}-policy
+{{- end }}
+`
+
+const testHook = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Release.Name }}-migrate
+  annotations:
+    helm.sh/hook: pre-upgrade
+`
+
+// testDiscovery serves a cluster without the policy API of the test chart
+func testDiscovery() *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+			{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "services", Kind: "Service", Namespaced: true}}},
+			{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}}},
+			{GroupVersion: "rbac.authorization.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "clusterroles", Kind: "ClusterRole"}}},
+		}},
+		FakedServerVersion: &version.Info{GitVersion: "v1.23.4", Major: "1", Minor: "23"},
+	}
+}
+
+// testChart packages a chart with the given templates
+func testChart(t *testing.T, name, version string, templates map[string]string) []byte {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: version},
+		Raw:      []*chart.File{{Name: chartutil.ValuesfileName, Data: []byte("hubconfig:\n  replicaCount: 1\n")}},
+		Values:   map[string]interface{}{"hubconfig": map[string]interface{}{"replicaCount": 1}},
+		Files:    []*chart.File{{Name: "crds/policies.yaml", Data: []byte(testCRD)}},
+	}
+	for file, data := range templates {
+		c.Templates = append(c.Templates, &chart.File{Name: "templates/" + file, Data: []byte(data)})
+	}
+	path, err := chartutil.Save(c, t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to package chart: %v", err)
+	}
+	archive, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func TestFromSubscription(t *testing.T) {
+	mch := &operatorsv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test"},
+		Spec: operatorsv1.MultiClusterHubSpec{
+			AvailabilityConfig: operatorsv1.HAHigh,
+			Tolerations:        []corev1.Toleration{{Key: "infra", Operator: corev1.TolerationOpExists}},
+		},
+	}
+	rel, err := FromSubscription(subscription.GRC(mch, map[string]string{"grc_policy_propagator": "quay.io/grc@sha256:abc"}))
+	if err != nil {
+		t.Fatalf("FromSubscription() error = %v", err)
+	}
+	if rel.Name != "grc-sub" || rel.Namespace != "test" || rel.Chart != "grc" || rel.Version == "" {
+		t.Errorf("FromSubscription() = %s/%s chart %s %s", rel.Namespace, rel.Name, rel.Chart, rel.Version)
+	}
+
+	// Values are plain JSON values, as the subscription operator passes them
+	hubconfig, ok := rel.Values["hubconfig"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("FromSubscription() values = %v, want hubconfig", rel.Values)
+	}
+	if _, ok := hubconfig["tolerations"].([]interface{}); !ok {
+		t.Errorf("FromSubscription() tolerations = %T, want plain values", hubconfig["tolerations"])
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	caps, err := Capabilities(testDiscovery())
+	if err != nil {
+		t.Fatalf("Capabilities() error = %v", err)
+	}
+	if caps.KubeVersion.Version != "v1.23.4" || caps.KubeVersion.Minor != "23" {
+		t.Errorf("Capabilities() kube version = %+v, want the server version", caps.KubeVersion)
+	}
+	for _, v := range []string{"v1", "apps/v1", "apps/v1/Deployment", "rbac.authorization.k8s.io/v1/ClusterRole"} {
+		if !caps.APIVersions.Has(v) {
+			t.Errorf("Capabilities() API versions = %v, want %s", caps.APIVersions, v)
+		}
+	}
+	if caps.APIVersions.Has("batch/v1") {
+		t.Errorf("Capabilities() API versions = %v, want only served versions", caps.APIVersions)
+	}
+}
+
+func TestRender(t *testing.T) {
+	archive := testChart(t, "grc", "2.5.0", map[string]string{
+		"deployment.yaml": testDeployment,
+		"services.yaml":   testServices,
+		"policy.yaml":     testPolicy,
+		"NOTES.txt":       "Installed {{ .Release.Name }}",
+	})
+	caps, err := Capabilities(testDiscovery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := &Release{
+		Name:      "grc-sub",
+		Namespace: "test",
+		Chart:     "grc",
+		Version:   "2.5.0",
+		Values: map[string]interface{}{
+			"global": map[string]interface{}{
+				"imageOverrides": map[string]interface{}{"grc_policy_propagator": "quay.io/grc@sha256:abc"},
+			},
+			"hubconfig": map[string]interface{}{
+				"replicaCount": 2,
+				"tolerations":  []interface{}{map[string]interface{}{"key": "infra", "operator": "Exists"}},
+			},
+		},
+	}
+
+	crds, objects, err := Render(archive, rel, caps)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if len(crds) != 1 || crds[0].GetName() != "policies.policy.open-cluster-management.io" || crds[0].GetLabels()[ReleaseLabel] != "grc-sub" {
+		t.Errorf("Render() crds = %v, want the chart CRD labeled for the release", Refs(crds))
+	}
+
+	// Objects come in install order
+	want := []ObjectRef{
+		{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "grc-sub-role"},
+		{APIVersion: "v1", Kind: "Service", Name: "grc-sub-metrics"},
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "grc-sub-controller"},
+	}
+	if got := Refs(objects); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Render() objects = %v, want %v", got, want)
+	}
+
+	dep := objects[2]
+	if dep.GetLabels()[ReleaseLabel] != "grc-sub" || dep.GetAnnotations()[ReleaseNameAnnotation] != "grc-sub" ||
+		dep.GetAnnotations()[ChartVersionAnnotation] != "2.5.0" {
+		t.Errorf("Render() labels = %v, annotations = %v", dep.GetLabels(), dep.GetAnnotations())
+	}
+	if replicas, _, _ := unstructured.NestedInt64(dep.Object, "spec", "replicas"); replicas != 2 {
+		t.Errorf("Render() replicas = %d, want the value override 2", replicas)
+	}
+	if v := dep.GetAnnotations()["kube-version"]; v != "v1.23.4" {
+		t.Errorf("Render() kube version = %s, want the server version", v)
+	}
+
+	if _, _, err := Render(archive, &Release{Name: "grc-sub", Chart: "grc", Version: "2.6.0"}, caps); err == nil {
+		t.Errorf("Render() accepted an archive of another chart version")
+	}
+
+	// Hooks would not be run
+	hooked := testChart(t, "grc", "2.5.0", map[string]string{"deployment.yaml": testDeployment, "migrate.yaml": testHook})
+	if _, _, err := Render(hooked, rel, caps); err == nil || !strings.Contains(err.Error(), "Job grc-sub-migrate") {
+		t.Errorf("Render() error = %v, want the hook to fail the render", err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	rel := &Release{Name: "grc-sub", Chart: "grc", Version: "2.5.0"}
+	refs := []ObjectRef{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "test", Name: "grc-sub-controller"}}
+
+	cm, err := Record(rel, "test", refs)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if cm.Name != RecordName("grc-sub") || cm.Labels[RecordLabel] != "grc-sub" {
+		t.Errorf("Record() = %s with labels %v", cm.Name, cm.Labels)
+	}
+	got, err := RecordedObjects(cm)
+	if err != nil || !reflect.DeepEqual(got, refs) {
+		t.Errorf("RecordedObjects() = %v, %v, want %v", got, err, refs)
+	}
+
+	cm.Data[recordObjectsKey] = "not json"
+	if _, err := RecordedObjects(cm); err == nil {
+		t.Errorf("RecordedObjects() accepted an invalid record")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright Contributors to the Open Cluster Management project
+
+// Package sbom builds a software bill of materials for the images deployed by the hub
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+)
+
+const (
+	// MediaType is the media type of the generated documents
+	MediaType = "application/vnd.cyclonedx+json"
+	// specVersion is the CycloneDX specification version of the generated documents
+	specVersion = "1.4"
+)
+
+// Image is an image deployed by the hub
+type Image struct {
+	// Key is the image key used by the operator and the charts
+	Key string
+	// Reference is the resolved image reference
+	Reference string
+	// Source is the layer that set the reference, see imageoverrides.Provenance
+	Source string
+	// GitRepository and GitSha identify the source the image was built from, when known
+	GitRepository string
+	GitSha        string
+}
+
+type document struct {
+	BOMFormat   string      `json:"bomFormat"`
+	SpecVersion string      `json:"specVersion"`
+	Version     int         `json:"version"`
+	Metadata    metadata    `json:"metadata"`
+	Components  []component `json:"components"`
+}
+
+type metadata struct {
+	Component component `json:"component"`
+}
+
+type component struct {
+	BOMRef     string     `json:"bom-ref,omitempty"`
+	Type       string     `json:"type"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version,omitempty"`
+	PURL       string     `json:"purl,omitempty"`
+	Hashes     []hash     `json:"hashes,omitempty"`
+	Properties []property `json:"properties,omitempty"`
+	ExtRefs    []extRef   `json:"externalReferences,omitempty"`
+}
+
+type hash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type extRef struct {
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// CycloneDX returns a CycloneDX JSON document listing the images of a hub at the given release version.
+// The document has no timestamp or serial number so that it only changes when the images do.
+func CycloneDX(hubVersion string, images []Image) ([]byte, error) {
+	doc := document{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: specVersion,
+		Version:     1,
+		Metadata: metadata{Component: component{
+			Type:    "application",
+			Name:    "multiclusterhub",
+			Version: hubVersion,
+		}},
+		Components: []component{},
+	}
+
+	sorted := append([]Image{}, images...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	for _, img := range sorted {
+		if img.Reference == "" {
+			continue
+		}
+		doc.Components = append(doc.Components, imageComponent(img))
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func imageComponent(img Image) component {
+	repository, tag, digest := utils.SplitImageReference(img.Reference)
+	c := component{
+		BOMRef: img.Key,
+		Type:   "container",
+		Name:   repository,
+		PURL:   purl(repository, tag, digest),
+		Properties: []property{
+			{Name: "open-cluster-management:image-key", Value: img.Key},
+			{Name: "open-cluster-management:image-reference", Value: img.Reference},
+		},
+	}
+	if digest != "" {
+		c.Version = digest
+		if alg, content := splitDigest(digest); alg != "" {
+			c.Hashes = []hash{{Alg: alg, Content: content}}
+		}
+	} else {
+		c.Version = tag
+	}
+	if img.Source != "" {
+		c.Properties = append(c.Properties, property{Name: "open-cluster-management:image-source", Value: img.Source})
+	}
+	if img.GitRepository != "" {
+		ref := extRef{Type: "vcs", URL: fmt.Sprintf("https://github.com/%s", img.GitRepository)}
+		if img.GitSha != "" {
+			ref.Comment = fmt.Sprintf("commit %s", img.GitSha)
+		}
+		c.ExtRefs = []extRef{ref}
+	}
+	return c
+}
+
+// splitDigest returns the CycloneDX hash algorithm and content of a digest
+func splitDigest(digest string) (string, string) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	switch parts[0] {
+	case "sha256":
+		return "SHA-256", parts[1]
+	case "sha512":
+		return "SHA-512", parts[1]
+	}
+	return "", ""
+}
+
+// purl returns the package URL of an OCI image as defined by the purl specification
+func purl(repository, tag, digest string) string {
+	name := strings.ToLower(repository[strings.LastIndex(repository, "/")+1:])
+	p := fmt.Sprintf("pkg:oci/%s", name)
+	if digest != "" {
+		p += "@" + url.QueryEscape(digest)
+	}
+	q := url.Values{}
+	q.Set("repository_url", repository)
+	if tag != "" {
+		q.Set("tag", tag)
+	}
+	return p + "?" + q.Encode()
+}
@@ -0,0 +1,49 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sbom
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCycloneDX(t *testing.T) {
+	images := []Image{
+		{Key: "search", Reference: "quay.io/stolostron/search:2.5", Source: "configmap:open-cluster-management/overrides"},
+		{Key: "console", Reference: "quay.io/stolostron/console@sha256:abc", Source: "manifest",
+			GitRepository: "stolostron/console", GitSha: "0123"},
+		{Key: "oauth_proxy_48", Reference: ""},
+	}
+
+	data, err := CycloneDX("2.5.0", images)
+	if err != nil {
+		t.Fatalf("CycloneDX() error = %v", err)
+	}
+	doc := document{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("CycloneDX() returned invalid JSON: %v", err)
+	}
+
+	if doc.BOMFormat != "CycloneDX" || doc.Metadata.Component.Version != "2.5.0" {
+		t.Errorf("CycloneDX() metadata = %+v", doc.Metadata)
+	}
+	if len(doc.Components) != 2 {
+		t.Fatalf("CycloneDX() components = %+v, want 2 components", doc.Components)
+	}
+
+	console := doc.Components[0]
+	if console.BOMRef != "console" || console.Name != "quay.io/stolostron/console" || console.Version != "sha256:abc" {
+		t.Errorf("CycloneDX() console component = %+v", console)
+	}
+	if console.PURL != "pkg:oci/console@sha256%3Aabc?repository_url=quay.io%2Fstolostron%2Fconsole" {
+		t.Errorf("CycloneDX() console purl = %s", console.PURL)
+	}
+	if len(console.Hashes) != 1 || console.Hashes[0].Alg != "SHA-256" || len(console.ExtRefs) != 1 {
+		t.Errorf("CycloneDX() console hashes = %+v, external references = %+v", console.Hashes, console.ExtRefs)
+	}
+
+	search := doc.Components[1]
+	if search.Version != "2.5" || search.PURL != "pkg:oci/search?repository_url=quay.io%2Fstolostron%2Fsearch&tag=2.5" {
+		t.Errorf("CycloneDX() search component = %+v", search)
+	}
+}
@@ -96,6 +96,23 @@ func Validate(found *unstructured.Unstructured, want *unstructured.Unstructured)
 		return found, true
 	}
 
+	// Follow the annotations set from the chart source and the reconcile settings
+	if subscriptionAnnotationsDiffer(found, want) {
+		a := found.GetAnnotations()
+		if a == nil {
+			a = map[string]string{}
+		}
+		for _, key := range channel.SubscriptionAnnotationKeys {
+			if v, ok := want.GetAnnotations()[key]; ok {
+				a[key] = v
+			} else {
+				delete(a, key)
+			}
+		}
+		found.SetAnnotations(a)
+		return found, true
+	}
+
 	// Remove owner reference if it shouldn't be there
 	if want.GetOwnerReferences() == nil && found.GetOwnerReferences() != nil {
 		found.SetOwnerReferences(nil)
@@ -105,6 +122,39 @@ func Validate(found *unstructured.Unstructured, want *unstructured.Unstructured)
 	return nil, false
 }
 
+// subscriptionAnnotationsDiffer returns true if the subscriptions disagree on any annotation the operator sets
+func subscriptionAnnotationsDiffer(found, want *unstructured.Unstructured) bool {
+	for _, key := range channel.SubscriptionAnnotationKeys {
+		if found.GetAnnotations()[key] != want.GetAnnotations()[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// SetVersion pins the chart version the appsub subscribes to
+func SetVersion(u *unstructured.Unstructured, version string) {
+	_ = unstructured.SetNestedField(u.Object, version, "spec", "packageFilter", "version")
+}
+
+// AddSubscriptionAnnotations adds the annotations the subscription operator needs to find the chart of the
+// subscription in the chart source of the hub and to reconcile it at the rate the hub sets
+func AddSubscriptionAnnotations(m *operatorsv1.MultiClusterHub, u *unstructured.Unstructured) {
+	chart, _, _ := unstructured.NestedString(u.Object, "spec", "name")
+	sourceAnnotations := channel.SubscriptionAnnotations(m, chart)
+	if len(sourceAnnotations) == 0 {
+		return
+	}
+	a := u.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	for k, v := range sourceAnnotations {
+		a[k] = v
+	}
+	u.SetAnnotations(a)
+}
+
 // setCustomCA sets a CustomCAConfigmap to the hubconfig overrides if available
 func setCustomCA(m *operatorsv1.MultiClusterHub, sub *Subscription) {
 	if m.Spec.CustomCAConfigmap != "" {
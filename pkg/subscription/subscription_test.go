@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/channel"
 	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -117,6 +118,40 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateChartSource(t *testing.T) {
+	mch := &operatorsv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Namespace: "test"}}
+	gitMCH := mch.DeepCopy()
+	gitMCH.Spec.ChartSource = &operatorsv1.ChartSource{
+		Type:   operatorsv1.ChartSourceGit,
+		URL:    "https://git.example.com/acm/charts.git",
+		Path:   "charts",
+		Branch: "hotfix",
+	}
+
+	newSub := func(m *operatorsv1.MultiClusterHub) *unstructured.Unstructured {
+		sub := GRC(m, map[string]string{})
+		AddSubscriptionAnnotations(m, sub)
+		return sub
+	}
+
+	if bundled := newSub(mch); len(bundled.GetAnnotations()) != 0 {
+		t.Errorf("AddSubscriptionAnnotations() = %v for the bundled repo, want none", bundled.GetAnnotations())
+	}
+	git := newSub(gitMCH)
+	if got := git.GetAnnotations()[channel.AnnotationGitPath]; got != "charts/grc" {
+		t.Errorf("AddSubscriptionAnnotations() git path = %s, want charts/grc", got)
+	}
+
+	got, needsUpdate := Validate(newSub(mch), git)
+	if !needsUpdate || !reflect.DeepEqual(got.GetAnnotations(), git.GetAnnotations()) {
+		t.Errorf("Validate() = %v, %v, want the Git annotations", got.GetAnnotations(), needsUpdate)
+	}
+	got, needsUpdate = Validate(newSub(gitMCH), newSub(mch))
+	if !needsUpdate || len(got.GetAnnotations()) != 0 {
+		t.Errorf("Validate() = %v, %v, want the Git annotations removed", got.GetAnnotations(), needsUpdate)
+	}
+}
+
 func TestSubscriptions(t *testing.T) {
 	mch := &operatorsv1.MultiClusterHub{
 		ObjectMeta: metav1.ObjectMeta{Namespace: "test"},
@@ -203,3 +238,69 @@ func TestOADPAnnotation(t *testing.T) {
 		t.Error(fmt.Sprintf("Cluster Backup should not have OADP overrides. Got: %s", spec))
 	}
 }
+
+func TestAddComponentValues(t *testing.T) {
+	mch := &operatorsv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Namespace: "test"}}
+	sub := GRC(mch, map[string]string{"grc_policy_propagator": "quay.io/grc@sha256:abc"})
+	AddComponentValues(sub, map[string]interface{}{
+		"hubconfig": map[string]interface{}{
+			"replicaCount": 5,
+			"logLevel":     "debug",
+		},
+		"global": map[string]interface{}{
+			"imageOverrides": map[string]interface{}{"grc_policy_propagator": "quay.io/evil:latest"},
+		},
+		"pullSecret": "other",
+		"metrics":    map[string]interface{}{"enabled": true},
+	})
+
+	values := sub.Object["spec"].(map[string]interface{})["packageOverrides"].([]map[string]interface{})[0]["packageOverrides"].([]map[string]interface{})[0]["value"].(map[string]interface{})
+	hubconfig := values["hubconfig"].(map[string]interface{})
+	if hubconfig["logLevel"] != "debug" || hubconfig["replicaCount"] != utils.DefaultReplicaCount(mch) {
+		t.Errorf("AddComponentValues() hubconfig = %v, want logLevel merged and replicaCount kept", hubconfig)
+	}
+	if hubconfig["tolerations"] == nil {
+		t.Errorf("AddComponentValues() dropped the operator tolerations")
+	}
+	global := values["global"].(map[string]interface{})
+	if global["imageOverrides"].(map[string]string)["grc_policy_propagator"] != "quay.io/grc@sha256:abc" {
+		t.Errorf("AddComponentValues() replaced the image overrides: %v", global["imageOverrides"])
+	}
+	if values["pullSecret"] != "" || !reflect.DeepEqual(values["metrics"], map[string]interface{}{"enabled": true}) {
+		t.Errorf("AddComponentValues() pullSecret = %v, metrics = %v", values["pullSecret"], values["metrics"])
+	}
+}
+
+func TestProtectedValueConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   []string
+	}{
+		{
+			name:   "unprotected values",
+			values: map[string]interface{}{"hubconfig": map[string]interface{}{"logLevel": "debug"}, "metrics": true},
+			want:   []string{},
+		},
+		{
+			name: "protected values and values beneath them",
+			values: map[string]interface{}{
+				"pullSecret": "other",
+				"global":     map[string]interface{}{"imageOverrides": map[string]interface{}{"grc": "quay.io/grc:latest"}},
+			},
+			want: []string{"global.imageOverrides", "pullSecret"},
+		},
+		{
+			name:   "parent of protected values replaced",
+			values: map[string]interface{}{"hubconfig": nil, "config": "none"},
+			want:   []string{"config", "hubconfig"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProtectedValueConflicts(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProtectedValueConflicts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package subscription
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ProtectedValues are the chart values the operator sets from the MultiClusterHub spec, the cluster or its
+// own configuration, as dot separated paths. Component values cannot set them or anything beneath them.
+var ProtectedValues = []string{
+	"cfcRouterUrl",
+	"cluster_basedomain",
+	"clusterImageSets.subscriptionPause",
+	"config.ssl-ciphers",
+	"global.imageOverrides",
+	"global.imagePullPolicy",
+	"global.imagePullSecret",
+	"global.imageRepository",
+	"global.pullPolicy",
+	"global.pullSecret",
+	"hubconfig.customCAConfigmap",
+	"hubconfig.name",
+	"hubconfig.namespace",
+	"hubconfig.nodeSelector",
+	"hubconfig.ocpVersion",
+	"hubconfig.replicaCount",
+	"hubconfig.tolerations",
+	"oadpOperator",
+	"ocpingress",
+	"pullSecret",
+}
+
+// ProtectedValueConflicts returns, sorted, the paths of the values that would set a protected value:
+// either a protected path or one beneath it, or a parent of a protected path set to something other than
+// an object
+func ProtectedValueConflicts(values map[string]interface{}) []string {
+	conflicts := protectedValueConflicts(values, "")
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+func protectedValueConflicts(values map[string]interface{}, prefix string) []string {
+	conflicts := []string{}
+	for key, v := range values {
+		path := prefix + key
+		if isProtected(path) {
+			conflicts = append(conflicts, path)
+			continue
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			conflicts = append(conflicts, protectedValueConflicts(nested, path+".")...)
+		} else if holdsProtected(path) {
+			conflicts = append(conflicts, path)
+		}
+	}
+	return conflicts
+}
+
+// isProtected returns true if the path is a protected value or lies beneath one
+func isProtected(path string) bool {
+	for _, p := range ProtectedValues {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsProtected returns true if a protected value lies beneath the path
+func holdsProtected(path string) bool {
+	for _, p := range ProtectedValues {
+		if strings.HasPrefix(p, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// AddComponentValues deep-merges component values into the values the appsub passes to its chart. Objects
+// are merged key by key and other values replace those of the operator. Values that conflict with a
+// protected value are dropped; the admission webhook rejects them.
+func AddComponentValues(u *unstructured.Unstructured, values map[string]interface{}) {
+	if len(values) == 0 {
+		return
+	}
+	chart, _, _ := unstructured.NestedString(u.Object, "spec", "name")
+	spec, _ := u.Object["spec"].(map[string]interface{})
+	pkgs, _ := spec["packageOverrides"].([]map[string]interface{})
+	for _, pkg := range pkgs {
+		if pkg["packageName"] != chart {
+			continue
+		}
+		overrides, _ := pkg["packageOverrides"].([]map[string]interface{})
+		for _, o := range overrides {
+			if o["path"] != "spec" {
+				continue
+			}
+			dst, ok := o["value"].(map[string]interface{})
+			if !ok {
+				dst = map[string]interface{}{}
+				o["value"] = dst
+			}
+			mergeValues(dst, values, "")
+		}
+	}
+}
+
+func mergeValues(dst, src map[string]interface{}, prefix string) {
+	for key, v := range src {
+		path := prefix + key
+		if isProtected(path) {
+			continue
+		}
+		nested, ok := v.(map[string]interface{})
+		if !ok {
+			if !holdsProtected(path) {
+				dst[key] = v
+			}
+			continue
+		}
+		existing, ok := dst[key].(map[string]interface{})
+		if !ok {
+			existing = map[string]interface{}{}
+			dst[key] = existing
+		}
+		mergeValues(existing, nested, path+".")
+	}
+}
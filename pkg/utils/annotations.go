@@ -15,7 +15,7 @@ var (
 	AnnotationMCHPause = "mch-pause"
 	// AnnotationImageRepo sits in multiclusterhub annotations to identify a custom image repository to use
 	AnnotationImageRepo = "mch-imageRepository"
-	// AnnotationImageOverridesCM sits in multiclusterhub annotations to identify a custom configmap containing image overrides
+	// AnnotationImageOverridesCM sits in multiclusterhub annotations to identify custom configmaps containing image overrides
 	AnnotationImageOverridesCM = "mch-imageOverridesCM"
 	// AnnotationConfiguration sits in a resource's annotations to identify the configuration last used to create it
 	AnnotationConfiguration = "installer.open-cluster-management.io/last-applied-configuration"
@@ -23,6 +23,17 @@ var (
 	AnnotationMCESubscriptionSpec = "installer.open-cluster-management.io/mce-subscription-spec"
 	// AnnotationOADPSubscriptionSpec overrides the OADP subscription used in cluster-backup
 	AnnotationOADPSubscriptionSpec = "installer.open-cluster-management.io/oadp-subscription-spec"
+	// AnnotationAllowOrphanedResources sits in multiclusterhub annotations to let a component be disabled
+	// while custom resources it manages still exist
+	AnnotationAllowOrphanedResources = "installer.open-cluster-management.io/allow-orphaned-resources"
+	// AnnotationImageProvenance sits in the image manifest configmap to record where each image reference came from
+	AnnotationImageProvenance = "installer.open-cluster-management.io/image-provenance"
+	// AnnotationImagePreflight sits in multiclusterhub annotations to check that component images exist in
+	// their registry before the components are rolled out
+	AnnotationImagePreflight = "installer.open-cluster-management.io/image-preflight"
+	// AnnotationHelmRepoTLSSecret sits in multiclusterhub annotations to identify a secret holding the serving
+	// certificate of the helm repo in place of one issued by the OpenShift service CA
+	AnnotationHelmRepoTLSSecret = "installer.open-cluster-management.io/helm-repo-tls-secret"
 )
 
 // IsPaused returns true if the multiclusterhub instance is labeled as paused, and false otherwise
@@ -39,13 +50,27 @@ func IsPaused(instance *operatorsv1.MultiClusterHub) bool {
 	return false
 }
 
+// AllowOrphanedResources returns true if the multiclusterhub permits disabling components whose
+// custom resources are still in use
+func AllowOrphanedResources(instance *operatorsv1.MultiClusterHub) bool {
+	return strings.EqualFold(getAnnotation(instance, AnnotationAllowOrphanedResources), "true")
+}
+
+// ImagePreflightEnabled returns true if the multiclusterhub asks for images to be looked up in their
+// registry before components are rolled out
+func ImagePreflightEnabled(instance *operatorsv1.MultiClusterHub) bool {
+	return strings.EqualFold(getAnnotation(instance, AnnotationImagePreflight), "true")
+}
+
 // AnnotationsMatch returns true if all annotation values used by the operator match
 func AnnotationsMatch(old, new map[string]string) bool {
 	return old[AnnotationMCHPause] == new[AnnotationMCHPause] &&
 		old[AnnotationImageRepo] == new[AnnotationImageRepo] &&
 		old[AnnotationImageOverridesCM] == new[AnnotationImageOverridesCM] &&
 		old[AnnotationMCESubscriptionSpec] == new[AnnotationMCESubscriptionSpec] &&
-		old[AnnotationOADPSubscriptionSpec] == new[AnnotationOADPSubscriptionSpec]
+		old[AnnotationOADPSubscriptionSpec] == new[AnnotationOADPSubscriptionSpec] &&
+		old[AnnotationImagePreflight] == new[AnnotationImagePreflight] &&
+		old[AnnotationHelmRepoTLSSecret] == new[AnnotationHelmRepoTLSSecret]
 }
 
 // getAnnotation returns the annotation value for a given key, or an empty string if not set
@@ -57,16 +82,38 @@ func getAnnotation(instance *operatorsv1.MultiClusterHub, key string) string {
 	return a[key]
 }
 
-// GetImageRepository returns the image repo annotation, or an empty string if not set
+// GetImageRepository returns the image repo annotation, or an empty string if not set. The
+// annotation is ignored when spec.registryMirrors is set.
 func GetImageRepository(instance *operatorsv1.MultiClusterHub) string {
+	if len(instance.Spec.RegistryMirrors) > 0 {
+		return ""
+	}
 	return getAnnotation(instance, AnnotationImageRepo)
 }
 
+// GetHelmRepoTLSSecret returns the name of the secret holding a self-managed helm repo certificate, or
+// an empty string if not set
+func GetHelmRepoTLSSecret(instance *operatorsv1.MultiClusterHub) string {
+	return getAnnotation(instance, AnnotationHelmRepoTLSSecret)
+}
+
 // GetImageOverridesConfigmap returns the images override configmap annotation, or an empty string if not set
 func GetImageOverridesConfigmap(instance *operatorsv1.MultiClusterHub) string {
 	return getAnnotation(instance, AnnotationImageOverridesCM)
 }
 
+// GetImageOverridesConfigmaps returns the names of the image override configmaps in the order they
+// are applied. The annotation holds a comma separated list of names.
+func GetImageOverridesConfigmaps(instance *operatorsv1.MultiClusterHub) []string {
+	names := []string{}
+	for _, name := range strings.Split(GetImageOverridesConfigmap(instance), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func OverrideImageRepository(imageOverrides map[string]string, imageRepo string) map[string]string {
 	for imageKey, imageRef := range imageOverrides {
 		image := strings.LastIndex(imageRef, "/")
@@ -123,3 +123,26 @@ func TestOverrideImageRepository(t *testing.T) {
 		}
 	}
 }
+
+func TestGetImageOverridesConfigmaps(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       []string
+	}{
+		{name: "Not set", want: []string{}},
+		{name: "Single configmap", annotation: "overrides", want: []string{"overrides"}},
+		{name: "Ordered list", annotation: "base, fixes,,", want: []string{"base", "fixes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mch := &operatorsv1.MultiClusterHub{}
+			if tt.annotation != "" {
+				mch.SetAnnotations(map[string]string{AnnotationImageOverridesCM: tt.annotation})
+			}
+			if got := GetImageOverridesConfigmaps(mch); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetImageOverridesConfigmaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
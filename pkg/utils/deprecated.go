@@ -0,0 +1,77 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+)
+
+// Deprecation describes a deprecated field or annotation found on a multiclusterhub and what
+// should be used instead
+type Deprecation struct {
+	// Field is the path of the field, or the annotation key
+	Field string
+	// Replacement names the field, annotation or resource that supersedes it
+	Replacement string
+}
+
+// String returns a user facing warning for the deprecation
+func (d Deprecation) String() string {
+	return fmt.Sprintf("%s is deprecated: %s", d.Field, d.Replacement)
+}
+
+// deprecatedAnnotations maps deprecated annotation keys to their replacement. Keys listed here
+// still take effect until they are removed from the operator.
+var deprecatedAnnotations = map[string]string{
+	AnnotationImageRepo: "set spec.registryMirrors instead",
+}
+
+// DeprecatedFieldsInUse returns the deprecated spec fields and annotations set on the multiclusterhub
+func DeprecatedFieldsInUse(m *operatorsv1.MultiClusterHub) []Deprecation {
+	deprecations := []Deprecation{}
+	if m.Spec.Hive != nil {
+		deprecations = append(deprecations, Deprecation{
+			Field:       "spec.hive",
+			Replacement: "configure the HiveConfig resource managed by the multicluster engine instead",
+		})
+	}
+	if m.Spec.SeparateCertificateManagement {
+		deprecations = append(deprecations, Deprecation{
+			Field:       "spec.separateCertificateManagement",
+			Replacement: "cert-manager is no longer installed by the hub and certificates are issued by the OpenShift service CA; remove the field",
+		})
+	}
+	if m.Spec.EnableClusterProxyAddon {
+		deprecations = append(deprecations, Deprecation{
+			Field:       "spec.enableClusterProxyAddon",
+			Replacement: fmt.Sprintf("set spec.overrides.components[name=%s].enabled instead", operatorsv1.ClusterProxyAddon),
+		})
+	}
+	if m.Spec.EnableClusterBackup {
+		deprecations = append(deprecations, Deprecation{
+			Field:       "spec.enableClusterBackup",
+			Replacement: fmt.Sprintf("set spec.overrides.components[name=%s].enabled instead", operatorsv1.ClusterBackup),
+		})
+	}
+	for _, key := range sortedKeys(deprecatedAnnotations) {
+		if _, ok := m.GetAnnotations()[key]; ok {
+			deprecations = append(deprecations, Deprecation{
+				Field:       fmt.Sprintf("metadata.annotations[%s]", key),
+				Replacement: deprecatedAnnotations[key],
+			})
+		}
+	}
+	return deprecations
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
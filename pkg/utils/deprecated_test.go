@@ -0,0 +1,61 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+)
+
+func TestDeprecatedFieldsInUse(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        operatorsv1.MultiClusterHubSpec
+		annotations map[string]string
+		want        []string
+	}{
+		{
+			name: "No deprecated fields",
+			spec: operatorsv1.MultiClusterHubSpec{},
+			want: []string{},
+		},
+		{
+			name: "All deprecated fields",
+			spec: operatorsv1.MultiClusterHubSpec{
+				Hive:                          &operatorsv1.HiveConfigSpec{},
+				SeparateCertificateManagement: true,
+				EnableClusterProxyAddon:       true,
+				EnableClusterBackup:           true,
+			},
+			want: []string{
+				"spec.hive",
+				"spec.separateCertificateManagement",
+				"spec.enableClusterProxyAddon",
+				"spec.enableClusterBackup",
+			},
+		},
+		{
+			name:        "Deprecated annotation",
+			annotations: map[string]string{AnnotationImageRepo: "quay.io/stolostron", AnnotationImageOverridesCM: "overrides"},
+			want:        []string{"metadata.annotations[mch-imageRepository]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			m := &operatorsv1.MultiClusterHub{Spec: tt.spec}
+			m.SetAnnotations(tt.annotations)
+			for _, d := range DeprecatedFieldsInUse(m) {
+				if d.Replacement == "" {
+					t.Errorf("DeprecatedFieldsInUse() %s has no replacement", d.Field)
+				}
+				got = append(got, d.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeprecatedFieldsInUse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package utils
+
+import (
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// PodDisruptionBudget lets voluntary disruptions such as node drains evict one of the selected pods at a
+// time. It is labeled with the installer so the operator can find the budgets it created, and owned by
+// the multiclusterhub when both share a namespace.
+func PodDisruptionBudget(m *operatorsv1.MultiClusterHub, name, namespace string, selector *metav1.LabelSelector) *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	pdb := &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "policy/v1",
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"installer.name":      m.Name,
+				"installer.namespace": m.Namespace,
+			},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector:       selector,
+		},
+	}
+	if namespace == m.Namespace {
+		pdb.SetOwnerReferences([]metav1.OwnerReference{
+			*metav1.NewControllerRef(m, m.GetObjectKind().GroupVersionKind()),
+		})
+	}
+	return pdb
+}
@@ -0,0 +1,107 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+)
+
+// GetRegistryMirrors returns the registry mirror rules configured on the multiclusterhub
+func GetRegistryMirrors(instance *operatorsv1.MultiClusterHub) []operatorsv1.RegistryMirror {
+	return instance.Spec.RegistryMirrors
+}
+
+// MirrorImageReference rewrites an image reference using the mirror rule with the longest source
+// matching the start of the reference. A source matches the reference exactly or when followed by
+// '/', ':' or '@', so quay.io/stolostron never matches quay.io/stolostron-dev. The reference is
+// returned unchanged when no rule matches.
+func MirrorImageReference(ref string, mirrors []operatorsv1.RegistryMirror) string {
+	best := -1
+	for i, m := range mirrors {
+		if !sourceMatches(ref, m.Source) {
+			continue
+		}
+		if best == -1 || len(m.Source) > len(mirrors[best].Source) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return ref
+	}
+	return mirrors[best].Mirror + strings.TrimPrefix(ref, mirrors[best].Source)
+}
+
+// MirrorImageReferences applies the mirror rules to every reference of an image map in place
+func MirrorImageReferences(images map[string]string, mirrors []operatorsv1.RegistryMirror) map[string]string {
+	if len(mirrors) == 0 {
+		return images
+	}
+	for key, ref := range images {
+		images[key] = MirrorImageReference(ref, mirrors)
+	}
+	return images
+}
+
+// RewriteImageReferences applies the registry mirror rules of the multiclusterhub, or its image repository
+// annotation when it sets no rules, to every reference of an image map in place
+func RewriteImageReferences(m *operatorsv1.MultiClusterHub, images map[string]string) map[string]string {
+	if mirrors := GetRegistryMirrors(m); len(mirrors) > 0 {
+		return MirrorImageReferences(images, mirrors)
+	}
+	if imageRepo := GetImageRepository(m); imageRepo != "" {
+		return OverrideImageRepository(images, imageRepo)
+	}
+	return images
+}
+
+func sourceMatches(ref, source string) bool {
+	if source == "" || !strings.HasPrefix(ref, source) {
+		return false
+	}
+	if len(ref) == len(source) {
+		return true
+	}
+	switch ref[len(source)] {
+	case '/', ':', '@':
+		return true
+	}
+	return false
+}
+
+// ImageHasDigest returns true if the image reference is pinned by a digest
+func ImageHasDigest(ref string) bool {
+	i := strings.LastIndex(ref, "@")
+	if i < 0 {
+		return false
+	}
+	parts := strings.SplitN(ref[i+1:], ":", 2)
+	return len(parts) == 2 && parts[0] != "" && parts[1] != ""
+}
+
+// TagReferences returns the sorted keys of the image references that are not pinned by digest. Keys
+// without a reference (e.g. an unset oauth proxy image) are not deployed and are skipped.
+func TagReferences(images map[string]string) []string {
+	tagged := []string{}
+	for key, ref := range images {
+		if ref != "" && !ImageHasDigest(ref) {
+			tagged = append(tagged, key)
+		}
+	}
+	sort.Strings(tagged)
+	return tagged
+}
+
+// SplitImageReference splits an image reference into its repository, tag and digest
+func SplitImageReference(ref string) (repository, tag, digest string) {
+	repository = ref
+	if i := strings.LastIndex(repository, "@"); i >= 0 {
+		repository, digest = repository[:i], repository[i+1:]
+	}
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
@@ -0,0 +1,114 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package utils
+
+import (
+	"testing"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+)
+
+func TestMirrorImageReference(t *testing.T) {
+	mirrors := []operatorsv1.RegistryMirror{
+		{Source: "quay.io", Mirror: "mirror.example.com/quay"},
+		{Source: "quay.io/stolostron", Mirror: "mirror.example.com/acm"},
+		{Source: "registry.redhat.io/rhacm2/console-rhel8", Mirror: "mirror.example.com/console"},
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{
+			name: "Longest source wins",
+			ref:  "quay.io/stolostron/console@sha256:abc",
+			want: "mirror.example.com/acm/console@sha256:abc",
+		},
+		{
+			name: "Shorter source matches other repositories",
+			ref:  "quay.io/open-cluster-management/registration:latest",
+			want: "mirror.example.com/quay/open-cluster-management/registration:latest",
+		},
+		{
+			name: "Source is matched on a path boundary",
+			ref:  "quay.io/stolostron-dev/console:latest",
+			want: "mirror.example.com/quay/stolostron-dev/console:latest",
+		},
+		{
+			name: "Full image source followed by a digest",
+			ref:  "registry.redhat.io/rhacm2/console-rhel8@sha256:abc",
+			want: "mirror.example.com/console@sha256:abc",
+		},
+		{
+			name: "No matching rule",
+			ref:  "registry.redhat.io/rhacm2/search-rhel8@sha256:abc",
+			want: "registry.redhat.io/rhacm2/search-rhel8@sha256:abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MirrorImageReference(tt.ref, mirrors); got != tt.want {
+				t.Errorf("MirrorImageReference() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageHasDigest(t *testing.T) {
+	tests := map[string]bool{
+		"quay.io/stolostron/console@sha256:abc": true,
+		"quay.io/stolostron/console:2.5":        false,
+		"localhost:5000/console":                false,
+		"quay.io/stolostron/console@":           false,
+	}
+	for ref, want := range tests {
+		if got := ImageHasDigest(ref); got != want {
+			t.Errorf("ImageHasDigest(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func TestSplitImageReference(t *testing.T) {
+	tests := []struct {
+		ref, repository, tag, digest string
+	}{
+		{"quay.io/stolostron/console@sha256:abc", "quay.io/stolostron/console", "", "sha256:abc"},
+		{"localhost:5000/console:2.5", "localhost:5000/console", "2.5", ""},
+		{"localhost:5000/console", "localhost:5000/console", "", ""},
+		{"quay.io/console:2.5@sha256:abc", "quay.io/console", "2.5", "sha256:abc"},
+	}
+	for _, tt := range tests {
+		repository, tag, digest := SplitImageReference(tt.ref)
+		if repository != tt.repository || tag != tt.tag || digest != tt.digest {
+			t.Errorf("SplitImageReference(%q) = %q, %q, %q", tt.ref, repository, tag, digest)
+		}
+	}
+}
+
+func TestRewriteImageReferences(t *testing.T) {
+	mirrored := &operatorsv1.MultiClusterHub{Spec: operatorsv1.MultiClusterHubSpec{
+		RegistryMirrors: []operatorsv1.RegistryMirror{{Source: "quay.io/stolostron", Mirror: "mirror.example.com/acm"}},
+	}}
+	mirrored.SetAnnotations(map[string]string{AnnotationImageRepo: "ignored.example.com/acm"})
+	repo := &operatorsv1.MultiClusterHub{}
+	repo.SetAnnotations(map[string]string{AnnotationImageRepo: "repo.example.com/acm"})
+
+	tests := []struct {
+		name string
+		mch  *operatorsv1.MultiClusterHub
+		want string
+	}{
+		{name: "Registry mirrors", mch: mirrored, want: "mirror.example.com/acm/console@sha256:abc"},
+		{name: "Image repository", mch: repo, want: "repo.example.com/acm/console@sha256:abc"},
+		{name: "No rewrite", mch: &operatorsv1.MultiClusterHub{}, want: "quay.io/stolostron/console@sha256:abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RewriteImageReferences(tt.mch, map[string]string{"console": "quay.io/stolostron/console@sha256:abc"})
+			if got["console"] != tt.want {
+				t.Errorf("RewriteImageReferences() = %v, want %v", got["console"], tt.want)
+			}
+		})
+	}
+}
@@ -59,6 +59,22 @@ var (
 		"ECDHE-ECDSA-AES128-GCM-SHA256",
 		"ECDHE-RSA-AES128-GCM-SHA256",
 	}
+
+	// SupportedSSLCiphers is the allow-list of ciphers that may be set for management ingress.
+	// Only forward-secret AEAD ciphers are accepted.
+	SupportedSSLCiphers = []string{
+		"ECDHE-ECDSA-AES256-GCM-SHA384",
+		"ECDHE-RSA-AES256-GCM-SHA384",
+		"ECDHE-ECDSA-AES128-GCM-SHA256",
+		"ECDHE-RSA-AES128-GCM-SHA256",
+		"ECDHE-ECDSA-CHACHA20-POLY1305",
+		"ECDHE-RSA-CHACHA20-POLY1305",
+		"DHE-RSA-AES256-GCM-SHA384",
+		"DHE-RSA-AES128-GCM-SHA256",
+		"TLS_AES_128_GCM_SHA256",
+		"TLS_AES_256_GCM_SHA384",
+		"TLS_CHACHA20_POLY1305_SHA256",
+	}
 )
 
 // CertManagerNS returns the namespace to deploy cert manager objects
@@ -175,6 +191,16 @@ func AvailabilityConfigIsValid(config operatorsv1.AvailabilityType) bool {
 	}
 }
 
+// SSLCipherIsSupported returns true if the cipher is in the SupportedSSLCiphers allow-list
+func SSLCipherIsSupported(cipher string) bool {
+	for _, c := range SupportedSSLCiphers {
+		if c == cipher {
+			return true
+		}
+	}
+	return false
+}
+
 // DistributePods returns a anti-affinity rule that specifies a preference for pod replicas with
 // the matching key-value label to run across different nodes and zones
 func DistributePods(key string, value string) *corev1.Affinity {
@@ -341,7 +367,7 @@ func GetCustomResources(m *operatorsv1.MultiClusterHub) []types.NamespacedName {
 
 func GetDeploymentsForStatus(m *operatorsv1.MultiClusterHub) []types.NamespacedName {
 	nn := []types.NamespacedName{}
-	if m.Enabled("multiclusterhub-repo") {
+	if statusTracked(m, operatorsv1.Repo) {
 		nn = append(nn, types.NamespacedName{Name: "multiclusterhub-repo", Namespace: m.Namespace})
 	}
 	return nn
@@ -349,38 +375,44 @@ func GetDeploymentsForStatus(m *operatorsv1.MultiClusterHub) []types.NamespacedN
 
 func GetAppsubsForStatus(m *operatorsv1.MultiClusterHub) []types.NamespacedName {
 	nn := []types.NamespacedName{}
-	if m.Enabled(operatorsv1.Console) {
+	if statusTracked(m, operatorsv1.Console) {
 		nn = append(nn, types.NamespacedName{Name: "console-chart-sub", Namespace: m.Namespace})
 	}
-	if m.Enabled(operatorsv1.Insights) {
+	if statusTracked(m, operatorsv1.Insights) {
 		nn = append(nn, types.NamespacedName{Name: "policyreport-sub", Namespace: m.Namespace})
 	}
-	if m.Enabled(operatorsv1.GRC) {
+	if statusTracked(m, operatorsv1.GRC) {
 		nn = append(nn, types.NamespacedName{Name: "grc-sub", Namespace: m.Namespace})
 	}
-	if m.Enabled(operatorsv1.ManagementIngress) {
+	if statusTracked(m, operatorsv1.ManagementIngress) {
 		nn = append(nn, types.NamespacedName{Name: "management-ingress-sub", Namespace: m.Namespace})
 	}
-	if m.Enabled(operatorsv1.ClusterLifecycle) {
+	if statusTracked(m, operatorsv1.ClusterLifecycle) {
 		nn = append(nn, types.NamespacedName{Name: "cluster-lifecycle-sub", Namespace: m.Namespace})
 	}
-	if m.Enabled(operatorsv1.Search) {
+	if statusTracked(m, operatorsv1.Search) {
 		nn = append(nn, types.NamespacedName{Name: "search-prod-sub", Namespace: m.Namespace})
 	}
-	if m.Enabled(operatorsv1.ClusterBackup) {
+	if statusTracked(m, operatorsv1.ClusterBackup) {
 		nn = append(nn, types.NamespacedName{Name: "cluster-backup-chart-sub", Namespace: ClusterSubscriptionNamespace})
 	}
-	if m.Enabled(operatorsv1.ClusterProxyAddon) {
+	if statusTracked(m, operatorsv1.ClusterProxyAddon) {
 		nn = append(nn, types.NamespacedName{Name: "cluster-proxy-addon-sub", Namespace: m.Namespace})
 	}
-	if m.Enabled(operatorsv1.Volsync) {
+	if statusTracked(m, operatorsv1.Volsync) {
 		nn = append(nn, types.NamespacedName{Name: "volsync-addon-controller-sub", Namespace: m.Namespace})
 	}
 	return nn
 }
 
+// statusTracked returns true if the status of a component is read from its resources, which is the case
+// when it is enabled and managed by the operator
+func statusTracked(m *operatorsv1.MultiClusterHub, component string) bool {
+	return m.Enabled(component) && !m.Unmanaged(component)
+}
+
 func GetCustomResourcesForStatus(m *operatorsv1.MultiClusterHub) []types.NamespacedName {
-	if m.Enabled(operatorsv1.MultiClusterEngine) {
+	if statusTracked(m, operatorsv1.MultiClusterEngine) {
 		return []types.NamespacedName{
 			{Name: "multicluster-engine-sub", Namespace: MCESubscriptionNamespace},
 			{Name: "multicluster-engine-csv", Namespace: MCESubscriptionNamespace},
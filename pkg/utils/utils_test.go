@@ -249,6 +249,19 @@ func TestFormatSSLCiphers(t *testing.T) {
 	}
 }
 
+func TestSSLCipherIsSupported(t *testing.T) {
+	for _, c := range DefaultSSLCiphers {
+		if !SSLCipherIsSupported(c) {
+			t.Errorf("SSLCipherIsSupported(%s) = false, default ciphers must be supported", c)
+		}
+	}
+	for _, c := range []string{"DES-CBC3-SHA", "ECDHE-RSA-AES128-SHA", "RC4-MD5", ""} {
+		if SSLCipherIsSupported(c) {
+			t.Errorf("SSLCipherIsSupported(%s) = true, want false", c)
+		}
+	}
+}
+
 func TestTrackedNamespaces(t *testing.T) {
 	tests := []struct {
 		name string
@@ -2,14 +2,24 @@
 
 package version
 
-import "os"
+import (
+	"errors"
+	"os"
+)
+
+const versionEnvVar = "OPERATOR_VERSION"
 
 var Version string
 
 func init() {
-	if value, exists := os.LookupEnv("OPERATOR_VERSION"); exists {
-		Version = value
-	} else {
-		panic("OPERATOR_VERSION not defined")
+	Version = os.Getenv(versionEnvVar)
+}
+
+// Validate returns an error if the operator version is not defined. The operator must not start
+// without it; tools that only use the manifest libraries do not need it.
+func Validate() error {
+	if Version == "" {
+		return errors.New("OPERATOR_VERSION not defined")
 	}
+	return nil
 }
@@ -0,0 +1,110 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// certWatcher keeps the webhook serving certificate in memory and swaps it whenever
+// the backing secret changes. It is fed by the manager's secret informer, so no
+// certificate or key material is ever written to disk.
+type certWatcher struct {
+	sync.RWMutex
+
+	namespace string
+	name      string
+
+	currentCert     *tls.Certificate
+	resourceVersion string
+}
+
+func newCertWatcher(namespace, name string) *certWatcher {
+	return &certWatcher{namespace: namespace, name: name}
+}
+
+// GetCertificate returns the current certificate. It is used as the tls.Config GetCertificate callback.
+func (w *certWatcher) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	w.RLock()
+	defer w.RUnlock()
+	if w.currentCert == nil {
+		return nil, fmt.Errorf("webhook certificate from secret %s/%s has not been loaded yet", w.namespace, w.name)
+	}
+	return w.currentCert, nil
+}
+
+// Ready returns true once a certificate has been loaded
+func (w *certWatcher) Ready() bool {
+	w.RLock()
+	defer w.RUnlock()
+	return w.currentCert != nil
+}
+
+// update loads the certificate from the secret if its resourceVersion differs from the last one loaded
+func (w *certWatcher) update(secret *corev1.Secret) error {
+	if !w.matches(secret) {
+		return nil
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	if w.currentCert != nil && secret.ResourceVersion == w.resourceVersion {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate from secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	w.currentCert = &cert
+	w.resourceVersion = secret.ResourceVersion
+	log.Info(fmt.Sprintf("Loaded webhook certificate from secret %s/%s", secret.Namespace, secret.Name), "resourceVersion", secret.ResourceVersion)
+	return nil
+}
+
+// matches returns true if the object is the secret being watched
+func (w *certWatcher) matches(obj interface{}) bool {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return false
+	}
+	return secret.Namespace == w.namespace && secret.Name == w.name
+}
+
+// onUpdate is the informer callback for new and changed secrets
+func (w *certWatcher) onUpdate(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+	if err := w.update(secret); err != nil {
+		log.Error(err, "Failed to reload webhook certificate")
+	}
+}
+
+// Watch registers the certificate watcher with the secret informer from the given cache. The
+// previously loaded certificate keeps being served if the secret is deleted, until it is recreated.
+func (w *certWatcher) Watch(ctx context.Context, c cache.Cache) error {
+	informer, err := c.GetInformer(ctx, &corev1.Secret{})
+	if err != nil {
+		return fmt.Errorf("failed to get secret informer: %w", err)
+	}
+	informer.AddEventHandler(toolscache.FilteringResourceEventHandler{
+		FilterFunc: w.matches,
+		Handler: toolscache.ResourceEventHandlerFuncs{
+			AddFunc:    w.onUpdate,
+			UpdateFunc: func(_, newObj interface{}) { w.onUpdate(newObj) },
+			DeleteFunc: func(_ interface{}) {
+				log.Info(fmt.Sprintf("Webhook secret %s/%s was deleted. Serving last loaded certificate", w.namespace, w.name))
+			},
+		},
+	})
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testCertSecret(t *testing.T, namespace, name, resourceVersion string) *corev1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "multiclusterhub-operator-webhook"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, ResourceVersion: resourceVersion},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		},
+	}
+}
+
+func TestCertWatcher(t *testing.T) {
+	w := newCertWatcher("open-cluster-management", webhookSecretName)
+
+	if w.Ready() {
+		t.Fatal("certWatcher should not be ready before a certificate is loaded")
+	}
+	if _, err := w.GetCertificate(nil); err == nil {
+		t.Fatal("GetCertificate() should error before a certificate is loaded")
+	}
+
+	// Secrets with a different name or namespace are ignored
+	if err := w.update(testCertSecret(t, "default", webhookSecretName, "1")); err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	if w.Ready() {
+		t.Fatal("certWatcher should ignore secrets in other namespaces")
+	}
+
+	if err := w.update(testCertSecret(t, "open-cluster-management", webhookSecretName, "1")); err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	first, err := w.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+
+	// Same resourceVersion is not reloaded
+	if err := w.update(testCertSecret(t, "open-cluster-management", webhookSecretName, "1")); err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	if same, _ := w.GetCertificate(nil); same != first {
+		t.Error("certificate should not be reloaded for an unchanged resourceVersion")
+	}
+
+	// Rotated secret replaces the served certificate
+	if err := w.update(testCertSecret(t, "open-cluster-management", webhookSecretName, "2")); err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	if rotated, _ := w.GetCertificate(nil); rotated == first {
+		t.Error("certificate should be reloaded when the secret changes")
+	}
+
+	// Invalid data keeps the last good certificate
+	bad := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: webhookSecretName, Namespace: "open-cluster-management", ResourceVersion: "3"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("bad"), corev1.TLSPrivateKeyKey: []byte("bad")},
+	}
+	if err := w.update(bad); err == nil {
+		t.Error("update() should error on an invalid key pair")
+	}
+	if !w.Ready() {
+		t.Error("certWatcher should keep serving the last good certificate")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// webhookServer serves the registered webhooks over TLS using certificates held in memory
+// by a certWatcher. It runs as a manager Runnable on every replica and stops when the
+// manager's context is cancelled.
+type webhookServer struct {
+	port  int
+	cache cache.Cache
+	certs *certWatcher
+
+	// hooks holds the webhook mux and handles dependency injection for registered webhooks
+	hooks *webhook.Server
+}
+
+func newWebhookServer(port int, c cache.Cache, certs *certWatcher) *webhookServer {
+	return &webhookServer{
+		port:  port,
+		cache: c,
+		certs: certs,
+		hooks: &webhook.Server{Port: port},
+	}
+}
+
+// Register marks the given webhook as being served at the given path
+func (s *webhookServer) Register(path string, hook http.Handler) {
+	s.hooks.Register(path, hook)
+}
+
+// InjectFunc implements inject.Injector so the manager injects its client and decoder into
+// registered webhooks
+func (s *webhookServer) InjectFunc(f inject.Func) error {
+	return s.hooks.InjectFunc(f)
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface. Every replica serves webhooks.
+func (s *webhookServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start loads the serving certificate and serves webhooks until the context is cancelled
+func (s *webhookServer) Start(ctx context.Context) error {
+	if err := s.certs.Watch(ctx, s.cache); err != nil {
+		return err
+	}
+
+	log.Info("Waiting for webhook certificate")
+	err := wait.PollImmediateUntilWithContext(ctx, time.Second, func(context.Context) (bool, error) {
+		return s.certs.Ready(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("webhook certificate was never loaded: %w", err)
+	}
+
+	cfg := &tls.Config{
+		NextProtos:     []string{"h2"},
+		GetCertificate: s.certs.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	listener, err := tls.Listen("tcp", net.JoinHostPort("", strconv.Itoa(s.port)), cfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:           s.hooks.WebhookMux,
+		ReadHeaderTimeout: 32 * time.Second,
+	}
+
+	idleConnsClosed := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		log.Info("Shutting down webhook server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error(err, "Error shutting down the webhook server")
+		}
+		close(idleConnsClosed)
+	}()
+
+	log.Info("Serving webhook server", "port", s.port)
+	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	<-idleConnsClosed
+	return nil
+}
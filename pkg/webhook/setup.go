@@ -6,9 +6,6 @@ package webhook
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"time"
 
 	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -19,7 +16,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
-	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -42,119 +39,91 @@ const (
 )
 
 var (
-	certDir        = filepath.Join("/tmp", "webhookcert")
 	validatingPath = "/validate-v1-multiclusterhub"
+
+	// webhookPort is the port the webhook server listens on
+	webhookPort = 8443
+
+	// setupRetryInterval is how long to wait before retrying a failed webhook resource update
+	setupRetryInterval = 10 * time.Second
 )
 
+// Setup adds the webhook server and the webhook resource reconciliation to the manager. Both run
+// as manager Runnables and stop when the manager's context is cancelled.
 func Setup(mgr manager.Manager) error {
 	ns, err := utils.FindNamespace()
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		log.Info("calling createOrUpdateWebhookService")
-		createOrUpdateWebhookService(mgr.GetClient(), ns)
-		log.Info("calling updateCertDir")
-		lastResourceVersion := updateCertDir(mgr.GetClient(), ns, "")
-		log.Info("calling registerWebhook")
-		registerWebhook(mgr, ns)
-		log.Info("calling createOrUpdateValidatingWebhook")
-		createOrUpdateValidatingWebhook(mgr.GetClient(), ns, validatingPath, certDir)
-
-		// Check for changes to the webhook secret every minute
-		ticker := time.NewTicker(time.Minute)
-		for {
-			select {
-			case <-ticker.C:
-				lastResourceVersion = updateCertDir(mgr.GetClient(), ns, lastResourceVersion)
-			}
-		}
-	}()
-
-	return nil
-}
-
-// registerWebhook adds the webhook server to the manager
-func registerWebhook(mgr manager.Manager, ns string) {
-	hookServer := &webhook.Server{
-		Port:    8443,
-		CertDir: certDir,
-	}
+	hookServer := newWebhookServer(webhookPort, mgr.GetCache(), newCertWatcher(ns, webhookSecretName))
+	hookServer.Register(validatingPath, &webhook.Admission{Handler: &multiClusterHubValidator{}})
 
 	log.Info("Add the webhook server.")
 	if err := mgr.Add(hookServer); err != nil {
-		// Failure to add the webhook should cause the container to fail
-		log.Error(err, "failed to add the webhook server to the manager")
-		os.Exit(1)
+		return fmt.Errorf("failed to add the webhook server to the manager: %w", err)
 	}
 
-	log.Info("Registering webhooks to the webhook server.")
-	hookServer.Register(validatingPath, &webhook.Admission{Handler: &multiClusterHubValidator{}})
+	err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		return wait.PollImmediateUntilWithContext(ctx, setupRetryInterval, func(ctx context.Context) (bool, error) {
+			if err := createOrUpdateWebhookService(ctx, mgr.GetClient(), ns); err != nil {
+				log.Error(err, "Failed to reconcile webhook service. Retrying.")
+				return false, nil
+			}
+			if err := createOrUpdateValidatingWebhook(ctx, mgr.GetClient(), ns, validatingPath); err != nil {
+				log.Error(err, "Failed to reconcile validating webhook configuration. Retrying.")
+				return false, nil
+			}
+			return true, nil
+		})
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to add webhook setup to the manager: %w", err)
+	}
+
+	return nil
 }
 
 // createOrUpdateWebhookService creates or updates a service with the Openshift self-serving-cert
-func createOrUpdateWebhookService(c client.Client, namespace string) {
+func createOrUpdateWebhookService(ctx context.Context, c client.Client, namespace string) error {
 	service := &corev1.Service{}
 	key := types.NamespacedName{Name: utils.WebhookServiceName, Namespace: namespace}
-	for {
-		if err := c.Get(context.TODO(), key, service); err != nil {
-			if errors.IsNotFound(err) {
-
-				service := newWebhookService(namespace)
-				setOwnerReferences(c, namespace, service)
-				if err := c.Create(context.TODO(), service); err != nil {
-					log.Error(err, fmt.Sprintf("Failed to create %s/%s service", namespace, utils.WebhookServiceName))
-					return
-				}
-				log.Info(fmt.Sprintf("Create %s/%s service", namespace, utils.WebhookServiceName))
-
-				return
-			}
-			switch err.(type) {
-			case *cache.ErrCacheNotStarted:
-				time.Sleep(time.Second)
-				continue
-			default:
-				log.Error(err, fmt.Sprintf("Failed to get %s/%s service", namespace, utils.WebhookServiceName))
-				return
-			}
+	if err := c.Get(ctx, key, service); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get %s/%s service: %w", namespace, utils.WebhookServiceName, err)
 		}
-		metav1.SetMetaDataAnnotation(&service.ObjectMeta, "service.beta.openshift.io/serving-cert-secret-name", "multiclusterhub-operator-webhook")
-		if err := c.Update(context.TODO(), service); err != nil {
-			log.Error(err, fmt.Sprintf("Failed to update service %s", utils.WebhookServiceName))
-			return
+		service := newWebhookService(namespace)
+		setOwnerReferences(ctx, c, service)
+		if err := c.Create(ctx, service); err != nil {
+			return fmt.Errorf("failed to create %s/%s service: %w", namespace, utils.WebhookServiceName, err)
 		}
-		return
+		log.Info(fmt.Sprintf("Create %s/%s service", namespace, utils.WebhookServiceName))
+		return nil
+	}
+
+	metav1.SetMetaDataAnnotation(&service.ObjectMeta, "service.beta.openshift.io/serving-cert-secret-name", webhookSecretName)
+	if err := c.Update(ctx, service); err != nil {
+		return fmt.Errorf("failed to update service %s: %w", utils.WebhookServiceName, err)
 	}
+	return nil
 }
 
-func createOrUpdateValidatingWebhook(c client.Client, namespace, path string, certDir string) {
-	ctx := context.Background()
+func createOrUpdateValidatingWebhook(ctx context.Context, c client.Client, namespace, path string) error {
 	cfg := newValidatingWebhookCfg(namespace, path)
-	setOwnerReferences(c, namespace, cfg)
+	setOwnerReferences(ctx, c, cfg)
 	force := true
 
-	for {
-		if err := c.Patch(ctx, cfg, client.Apply, &client.PatchOptions{Force: &force, FieldManager: "multiclusterhub-operator"}); err != nil {
-			switch err.(type) {
-			case *cache.ErrCacheNotStarted:
-				time.Sleep(time.Second)
-				continue
-			default:
-				log.Error(err, fmt.Sprintf("Failed to get validating webhook %s", validatingCfgName))
-				return
-			}
-		}
-		log.Info(fmt.Sprintf("Update validating webhook %s", validatingCfgName))
-		return
+	if err := c.Patch(ctx, cfg, client.Apply, &client.PatchOptions{Force: &force, FieldManager: "multiclusterhub-operator"}); err != nil {
+		return fmt.Errorf("failed to apply validating webhook %s: %w", validatingCfgName, err)
 	}
+	log.Info(fmt.Sprintf("Update validating webhook %s", validatingCfgName))
+	return nil
 }
 
-func setOwnerReferences(c client.Client, namespace string, obj metav1.Object) {
+func setOwnerReferences(ctx context.Context, c client.Client, obj metav1.Object) {
 	key := types.NamespacedName{Name: crdName}
 	owner := &apixv1.CustomResourceDefinition{}
-	if err := c.Get(context.TODO(), key, owner); err != nil {
+	if err := c.Get(ctx, key, owner); err != nil {
 		log.Error(err, fmt.Sprintf("Failed to set owner references for %s", obj.GetName()))
 		return
 	}
@@ -169,55 +138,15 @@ func setOwnerReferences(c client.Client, namespace string, obj metav1.Object) {
 	})
 }
 
-// updateCertDir reads the webhook secret and saves the cert info to the cert directory if the resourceVersion is
-// different from the one provided. It will retry on error until successful and returns the last resource version written.
-func updateCertDir(c client.Client, namespace, lastResourceVersion string) string {
-	secret := &corev1.Secret{}
-	nn := types.NamespacedName{Name: webhookSecretName, Namespace: namespace}
-
-	for {
-		if err := c.Get(context.TODO(), nn, secret); err != nil {
-			switch err.(type) {
-			case *cache.ErrCacheNotStarted:
-				time.Sleep(time.Second)
-				continue
-			default:
-				time.Sleep(time.Second)
-				log.Error(err, fmt.Sprintf("Fails to return secret"))
-				continue
-			}
-		}
-
-		if secret.ResourceVersion == lastResourceVersion {
-			return lastResourceVersion
-		}
-		log.Info(fmt.Sprintf("resourceVersion of secret %s has changed. Updating certs.", webhookSecretName))
-
-		if err := os.MkdirAll(certDir, os.ModePerm); err != nil {
-			log.Error(err, fmt.Sprintf("trouble creating directory"))
-			return ""
-		}
-		if err := ioutil.WriteFile(filepath.Join(certDir, "tls.crt"), secret.Data["tls.crt"], os.FileMode(0644)); err != nil {
-			log.Error(err, fmt.Sprintf("trouble writing crt"))
-			return ""
-		}
-		if err := ioutil.WriteFile(filepath.Join(certDir, "tls.key"), secret.Data["tls.key"], os.FileMode(0644)); err != nil {
-			log.Error(err, fmt.Sprintf("trouble writing key"))
-			return ""
-		}
-		return secret.ResourceVersion
-	}
-}
-
 func newWebhookService(namespace string) *corev1.Service {
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        utils.WebhookServiceName,
 			Namespace:   namespace,
-			Annotations: map[string]string{"service.beta.openshift.io/serving-cert-secret-name": "multiclusterhub-operator-webhook"},
+			Annotations: map[string]string{"service.beta.openshift.io/serving-cert-secret-name": webhookSecretName},
 		},
 		Spec: corev1.ServiceSpec{
-			Ports:    []corev1.ServicePort{{Port: 443, TargetPort: intstr.FromInt(8443)}},
+			Ports:    []corev1.ServicePort{{Port: 443, TargetPort: intstr.FromInt(webhookPort)}},
 			Selector: map[string]string{"name": operatorName},
 		},
 	}
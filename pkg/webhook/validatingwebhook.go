@@ -9,9 +9,11 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"strings"
 
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
@@ -85,6 +87,60 @@ var (
 			Exceptions:     []string{},
 		},
 	}
+	// blockDisableResources lists, per component, the custom resources that are orphaned if the
+	// component's controllers are removed while they exist
+	blockDisableResources = map[string][]struct {
+		Name string
+		GVK  schema.GroupVersionKind
+	}{
+		operatorsv1.GRC: {
+			{
+				Name: "Policy",
+				GVK: schema.GroupVersionKind{
+					Group:   "policy.open-cluster-management.io",
+					Version: "v1",
+					Kind:    "PolicyList",
+				},
+			},
+			{
+				Name: "PolicySet",
+				GVK: schema.GroupVersionKind{
+					Group:   "policy.open-cluster-management.io",
+					Version: "v1beta1",
+					Kind:    "PolicySetList",
+				},
+			},
+			{
+				Name: "PolicyAutomation",
+				GVK: schema.GroupVersionKind{
+					Group:   "policy.open-cluster-management.io",
+					Version: "v1beta1",
+					Kind:    "PolicyAutomationList",
+				},
+			},
+		},
+		operatorsv1.ClusterBackup: {
+			{
+				Name: "BackupSchedule",
+				GVK: schema.GroupVersionKind{
+					Group:   "cluster.open-cluster-management.io",
+					Version: "v1beta1",
+					Kind:    "BackupScheduleList",
+				},
+			},
+			{
+				Name: "Restore",
+				GVK: schema.GroupVersionKind{
+					Group:   "cluster.open-cluster-management.io",
+					Version: "v1beta1",
+					Kind:    "RestoreList",
+				},
+			},
+		},
+	}
+
+	// maxBlockingObjects caps the number of blocking objects named in a denial message
+	maxBlockingObjects = 10
 )
 
 // Handle set the default values to every incoming MultiClusterHub cr.
@@ -97,25 +153,25 @@ func (m *multiClusterHubValidator) Handle(ctx context.Context, req admission.Req
 
 	if req.Operation == "CREATE" {
 		if len(multiClusterHubs.Items) == 0 {
-			err := m.validateCreate(req)
+			err := m.validateCreate(ctx, req)
 			if err != nil {
 				log.Info("Create denied")
 				return admission.Denied(err.Error())
 			}
 			log.Info("Create successful")
-			return admission.Allowed("")
+			return admission.Allowed("").WithWarnings(m.deprecationWarnings(req)...)
 		}
 		return admission.Denied("The MultiClusterHub CR already exists")
 	}
 	//If not create update
 	if req.Operation == "UPDATE" {
-		err := m.validateUpdate(req)
+		err := m.validateUpdate(ctx, req)
 		if err != nil {
 			log.Info("Update denied")
 			return admission.Denied(err.Error())
 		}
 		log.Info("Update successful")
-		return admission.Allowed("")
+		return admission.Allowed("").WithWarnings(m.deprecationWarnings(req)...)
 	}
 
 	if req.Operation == "DELETE" {
@@ -131,7 +187,20 @@ func (m *multiClusterHubValidator) Handle(ctx context.Context, req admission.Req
 	return admission.Denied("Operation not allowed on MultiClusterHub CR")
 }
 
-func (m *multiClusterHubValidator) validateCreate(req admission.Request) error {
+// deprecationWarnings returns an admission warning for each deprecated field or annotation set in the request
+func (m *multiClusterHubValidator) deprecationWarnings(req admission.Request) []string {
+	mch := &operatorsv1.MultiClusterHub{}
+	if err := m.decoder.DecodeRaw(req.Object, mch); err != nil {
+		return nil
+	}
+	warnings := []string{}
+	for _, d := range utils.DeprecatedFieldsInUse(mch) {
+		warnings = append(warnings, d.String())
+	}
+	return warnings
+}
+
+func (m *multiClusterHubValidator) validateCreate(ctx context.Context, req admission.Request) error {
 	mch := &operatorsv1.MultiClusterHub{}
 	err := m.decoder.DecodeRaw(req.Object, mch)
 	if err != nil {
@@ -147,10 +216,10 @@ func (m *multiClusterHubValidator) validateCreate(req admission.Request) error {
 		}
 	}
 
-	return nil
+	return validateSpec(ctx, m.client, nil, mch)
 }
 
-func (m *multiClusterHubValidator) validateUpdate(req admission.Request) error {
+func (m *multiClusterHubValidator) validateUpdate(ctx context.Context, req admission.Request) error {
 
 	// Parse existing and new MultiClusterHub resources
 	existingMCH := &operatorsv1.MultiClusterHub{}
@@ -184,6 +253,51 @@ func (m *multiClusterHubValidator) validateUpdate(req admission.Request) error {
 		}
 	}
 
+	if err := validateSpec(ctx, m.client, existingMCH, newMCH); err != nil {
+		return err
+	}
+
+	return m.validateComponentDisable(ctx, existingMCH, newMCH)
+}
+
+// validateComponentDisable denies disabling a component while custom resources it manages still exist,
+// unless the multiclusterhub carries the allow-orphaned-resources annotation
+func (m *multiClusterHubValidator) validateComponentDisable(ctx context.Context, existingMCH, newMCH *operatorsv1.MultiClusterHub) error {
+	if utils.AllowOrphanedResources(newMCH) {
+		return nil
+	}
+
+	oldComponents, newComponents := effectiveComponents(existingMCH), effectiveComponents(newMCH)
+	for _, component := range operatorsv1.ComponentInstallOrder() {
+		resources, ok := blockDisableResources[component]
+		if !ok || !oldComponents.Enabled(component) || newComponents.Enabled(component) {
+			continue
+		}
+
+		blocking := []string{}
+		for _, resource := range resources {
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(resource.GVK)
+			if err := m.client.List(ctx, list); err != nil {
+				if meta.IsNoMatchError(err) {
+					continue
+				}
+				return fmt.Errorf("unable to list %s: %s", resource.Name, err)
+			}
+			for i := range list.Items {
+				blocking = append(blocking, fmt.Sprintf("%s %s", resource.Name, client.ObjectKeyFromObject(&list.Items[i])))
+			}
+		}
+
+		if len(blocking) > 0 {
+			if len(blocking) > maxBlockingObjects {
+				blocking = append(blocking[:maxBlockingObjects], fmt.Sprintf("and %d more", len(blocking)-maxBlockingObjects))
+			}
+			return fmt.Errorf("Cannot disable %s because its resources are in use: %s. Remove them first, or set the annotation %s=true to disable it anyway",
+				component, strings.Join(blocking, ", "), utils.AnnotationAllowOrphanedResources)
+		}
+	}
+
 	return nil
 }
 
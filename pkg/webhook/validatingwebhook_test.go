@@ -0,0 +1,107 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+)
+
+func TestValidateComponentDisable(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	policyGVK := schema.GroupVersionKind{Group: "policy.open-cluster-management.io", Version: "v1", Kind: "Policy"}
+	scheme.AddKnownTypeWithName(policyGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(policyGVK.GroupVersion().WithKind("PolicyList"), &unstructured.UnstructuredList{})
+
+	policy := &unstructured.Unstructured{}
+	policy.SetGroupVersionKind(policyGVK)
+	policy.SetName("policy-pod")
+	policy.SetNamespace("default")
+
+	m := &multiClusterHubValidator{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(policy).Build(),
+	}
+
+	tests := []struct {
+		name        string
+		disable     string
+		annotations map[string]string
+		wantErr     string
+	}{
+		{
+			name:    "Disable GRC with policies",
+			disable: operatorsv1.GRC,
+			wantErr: "Cannot disable grc because its resources are in use: Policy default/policy-pod",
+		},
+		{
+			name:        "Disable GRC with override annotation",
+			disable:     operatorsv1.GRC,
+			annotations: map[string]string{utils.AnnotationAllowOrphanedResources: "true"},
+		},
+		{
+			name:    "Disable component without tracked resources",
+			disable: operatorsv1.Search,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &operatorsv1.MultiClusterHub{ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: testNamespace}}
+			updated := existing.DeepCopy()
+			updated.SetAnnotations(tt.annotations)
+			updated.Disable(tt.disable)
+
+			err := m.validateComponentDisable(context.TODO(), existing, updated)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateComponentDisable() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateComponentDisable() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeprecationWarnings(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = operatorsv1.AddToScheme(scheme)
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatalf("failed to create decoder: %v", err)
+	}
+	m := &multiClusterHubValidator{decoder: decoder}
+
+	mch := &operatorsv1.MultiClusterHub{
+		TypeMeta:   metav1.TypeMeta{APIVersion: operatorsv1.GroupVersion.String(), Kind: "MultiClusterHub"},
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: testNamespace},
+		Spec:       operatorsv1.MultiClusterHubSpec{EnableClusterBackup: true},
+	}
+	raw, err := json.Marshal(mch)
+	if err != nil {
+		t.Fatalf("failed to marshal multiclusterhub: %v", err)
+	}
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}}
+
+	warnings := m.deprecationWarnings(req)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "spec.enableClusterBackup is deprecated") ||
+		!strings.Contains(warnings[0], operatorsv1.ClusterBackup) {
+		t.Errorf("deprecationWarnings() = %v, want a single warning naming the cluster-backup component", warnings)
+	}
+}
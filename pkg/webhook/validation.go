@@ -0,0 +1,473 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	subv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/manifest"
+	"github.com/stolostron/multiclusterhub-operator/pkg/subscription"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+)
+
+// weakCipherMarkers identify cipher suites that are known but not allowed because they are
+// broken, unauthenticated or lack forward secrecy
+var weakCipherMarkers = []string{"NULL", "EXPORT", "RC4", "DES", "MD5", "ADH", "AECDH", "PSK", "CBC", "-SHA"}
+
+// validateSpec performs the field level validation shared by create and update. When old is
+// nil every field is validated; otherwise only fields that changed are checked so that
+// unrelated updates (e.g. finalizer removal) are never blocked by pre-existing values.
+func validateSpec(ctx context.Context, c client.Client, old, mch *operatorsv1.MultiClusterHub) error {
+	allErrs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	if old == nil || !reflect.DeepEqual(old.Spec.Ingress.SSLCiphers, mch.Spec.Ingress.SSLCiphers) {
+		allErrs = append(allErrs, validateSSLCiphers(mch.Spec.Ingress.SSLCiphers, specPath.Child("ingress", "sslCiphers"))...)
+	}
+	if old == nil || !reflect.DeepEqual(old.Spec.NodeSelector, mch.Spec.NodeSelector) {
+		allErrs = append(allErrs, metav1validation.ValidateLabels(mch.Spec.NodeSelector, specPath.Child("nodeSelector"))...)
+	}
+	if old == nil || !reflect.DeepEqual(old.Spec.Tolerations, mch.Spec.Tolerations) {
+		allErrs = append(allErrs, validateTolerations(mch.Spec.Tolerations, specPath.Child("tolerations"))...)
+	}
+	if mch.Spec.ImagePullSecret != "" && (old == nil || old.Spec.ImagePullSecret != mch.Spec.ImagePullSecret) {
+		allErrs = append(allErrs, validateObjectExists(ctx, c, &corev1.Secret{}, mch.Namespace, mch.Spec.ImagePullSecret,
+			specPath.Child("imagePullSecret"))...)
+	}
+	if mch.Spec.CustomCAConfigmap != "" && (old == nil || old.Spec.CustomCAConfigmap != mch.Spec.CustomCAConfigmap) {
+		allErrs = append(allErrs, validateObjectExists(ctx, c, &corev1.ConfigMap{}, mch.Namespace, mch.Spec.CustomCAConfigmap,
+			specPath.Child("customCAConfigmap"))...)
+	}
+
+	if old == nil || !reflect.DeepEqual(old.Spec.RegistryMirrors, mch.Spec.RegistryMirrors) {
+		allErrs = append(allErrs, validateRegistryMirrors(mch.Spec.RegistryMirrors, specPath.Child("registryMirrors"))...)
+	}
+
+	if old == nil || old.Spec.ImageDigestPolicy != mch.Spec.ImageDigestPolicy {
+		switch mch.Spec.ImageDigestPolicy {
+		case "", operatorsv1.ImageDigestAllow, operatorsv1.ImageDigestReject, operatorsv1.ImageDigestResolve:
+		default:
+			allErrs = append(allErrs, field.NotSupported(specPath.Child("imageDigestPolicy"), mch.Spec.ImageDigestPolicy, []string{
+				string(operatorsv1.ImageDigestAllow), string(operatorsv1.ImageDigestReject), string(operatorsv1.ImageDigestResolve),
+			}))
+		}
+	}
+
+	if mch.Spec.ChartSource != nil && (old == nil || !reflect.DeepEqual(old.Spec.ChartSource, mch.Spec.ChartSource)) {
+		allErrs = append(allErrs, validateChartSource(ctx, c, mch, specPath.Child("chartSource"))...)
+	}
+
+	if old == nil || old.Spec.InstallEngine != mch.Spec.InstallEngine ||
+		!reflect.DeepEqual(old.Spec.ChartSource, mch.Spec.ChartSource) {
+		allErrs = append(allErrs, validateInstallEngine(old, mch, specPath)...)
+	}
+
+	if mch.Spec.ChartReconcile != nil && (old == nil || !reflect.DeepEqual(old.Spec.ChartReconcile, mch.Spec.ChartReconcile)) {
+		allErrs = append(allErrs, validateChartReconcile(mch.Spec.ChartReconcile, specPath.Child("chartReconcile"))...)
+	}
+
+	if mch.Spec.Rollout != nil && (old == nil || !reflect.DeepEqual(old.Spec.Rollout, mch.Spec.Rollout)) {
+		allErrs = append(allErrs, validateRollout(mch.Spec.Rollout, specPath.Child("rollout", "waves"))...)
+	}
+
+	if d := mch.Spec.UpgradeRollback; d != nil && d.Deadline != nil && d.Deadline.Duration <= 0 &&
+		(old == nil || !reflect.DeepEqual(old.Spec.UpgradeRollback, mch.Spec.UpgradeRollback)) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("upgradeRollback", "deadline"), d.Deadline.Duration.String(),
+			"must be a positive duration"))
+	}
+
+	if old == nil || !reflect.DeepEqual(old.Spec.Overrides, mch.Spec.Overrides) ||
+		old.Spec.EnableClusterProxyAddon != mch.Spec.EnableClusterProxyAddon {
+		allErrs = append(allErrs, validateComponentDependencies(mch, specPath.Child("overrides", "components"))...)
+		allErrs = append(allErrs, validateComponentValues(mch, specPath.Child("overrides", "components"))...)
+		allErrs = append(allErrs, validateManagementStates(mch, specPath.Child("overrides", "components"))...)
+	}
+
+	allErrs = append(allErrs, validateAnnotations(ctx, c, old, mch)...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return allErrs.ToAggregate()
+}
+
+// validateSSLCiphers checks each cipher against the supported cipher allow-list
+func validateSSLCiphers(ciphers []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, cipher := range ciphers {
+		if utils.SSLCipherIsSupported(cipher) {
+			continue
+		}
+		if isWeakCipher(cipher) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), cipher, "cipher is considered weak and is not allowed"))
+		} else {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Index(i), cipher, utils.SupportedSSLCiphers))
+		}
+	}
+	return allErrs
+}
+
+func isWeakCipher(cipher string) bool {
+	upper := strings.ToUpper(cipher)
+	for _, marker := range weakCipherMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTolerations checks tolerations follow the same rules the API server applies to pod tolerations
+func validateTolerations(tolerations []corev1.Toleration, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, t := range tolerations {
+		idxPath := fldPath.Index(i)
+
+		if t.Key != "" {
+			for _, msg := range validation.IsQualifiedName(t.Key) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("key"), t.Key, msg))
+			}
+		} else if t.Operator != corev1.TolerationOpExists {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("operator"), t.Operator,
+				"operator must be Exists when `key` is empty, which means \"match all values and all keys\""))
+		}
+
+		switch t.Operator {
+		case corev1.TolerationOpEqual, "":
+			for _, msg := range validation.IsValidLabelValue(t.Value) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("value"), t.Value, msg))
+			}
+		case corev1.TolerationOpExists:
+			if t.Value != "" {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("value"), t.Value,
+					"value must be empty when `operator` is 'Exists'"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("operator"), t.Operator,
+				[]string{string(corev1.TolerationOpEqual), string(corev1.TolerationOpExists)}))
+		}
+
+		switch t.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("effect"), t.Effect, []string{
+				string(corev1.TaintEffectNoSchedule), string(corev1.TaintEffectPreferNoSchedule), string(corev1.TaintEffectNoExecute),
+			}))
+		}
+
+		if t.TolerationSeconds != nil && t.Effect != corev1.TaintEffectNoExecute {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("effect"), t.Effect,
+				"effect must be 'NoExecute' when `tolerationSeconds` is set"))
+		}
+	}
+	return allErrs
+}
+
+// validateRegistryMirrors requires a source and mirror for every rule and at most one rule per source
+func validateRegistryMirrors(mirrors []operatorsv1.RegistryMirror, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	sources := map[string]bool{}
+	for i, m := range mirrors {
+		idxPath := fldPath.Index(i)
+		if m.Source == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("source"), "source registry or repository is required"))
+		} else if sources[m.Source] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("source"), m.Source))
+		}
+		sources[m.Source] = true
+		if m.Mirror == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("mirror"), "mirror registry or repository is required"))
+		}
+		if strings.HasSuffix(m.Source, "/") || strings.HasSuffix(m.Mirror, "/") {
+			allErrs = append(allErrs, field.Invalid(idxPath, m, "source and mirror must not end with '/'"))
+		}
+	}
+	return allErrs
+}
+
+// validateChartSource requires the location of external chart sources and checks that the objects they
+// reference exist. The bundled helm repo takes no settings.
+func validateChartSource(ctx context.Context, c client.Client, mch *operatorsv1.MultiClusterHub, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	source := mch.Spec.ChartSource
+
+	switch source.Type {
+	case "", operatorsv1.ChartSourceHelmRepo:
+		settings := []struct{ name, value string }{
+			{"url", source.URL}, {"secretRef", source.SecretRef}, {"configMapRef", source.ConfigMapRef},
+			{"branch", source.Branch}, {"path", source.Path},
+		}
+		for _, s := range settings {
+			if s.value != "" {
+				allErrs = append(allErrs, field.Forbidden(fldPath.Child(s.name), "not supported by the bundled helm repo"))
+			}
+		}
+		return allErrs
+	case operatorsv1.ChartSourceOCI:
+		if !strings.HasPrefix(source.URL, "oci://") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("url"), source.URL, "must be an oci:// reference"))
+		}
+		if source.Branch != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("branch"), "only supported by Git sources"))
+		}
+		if source.Path != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("path"), "only supported by Git sources"))
+		}
+	case operatorsv1.ChartSourceGit:
+		if source.URL == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("url"), "the Git repository holding the charts is required"))
+		}
+	default:
+		return field.ErrorList{field.NotSupported(fldPath.Child("type"), source.Type, []string{
+			string(operatorsv1.ChartSourceHelmRepo), string(operatorsv1.ChartSourceOCI), string(operatorsv1.ChartSourceGit),
+		})}
+	}
+
+	if source.SecretRef != "" {
+		allErrs = append(allErrs, validateObjectExists(ctx, c, &corev1.Secret{}, mch.Namespace, source.SecretRef, fldPath.Child("secretRef"))...)
+	}
+	if source.ConfigMapRef != "" {
+		allErrs = append(allErrs, validateObjectExists(ctx, c, &corev1.ConfigMap{}, mch.Namespace, source.ConfigMapRef, fldPath.Child("configMapRef"))...)
+	}
+	return allErrs
+}
+
+// validateInstallEngine keeps the install engine of a hub from changing after installation, and requires
+// the Helm install engine to read charts from the bundled helm repo
+func validateInstallEngine(old, mch *operatorsv1.MultiClusterHub, fldPath *field.Path) field.ErrorList {
+	switch mch.Spec.InstallEngine {
+	case "", operatorsv1.InstallEngineSubscription, operatorsv1.InstallEngineHelm:
+	default:
+		return field.ErrorList{field.NotSupported(fldPath.Child("installEngine"), mch.Spec.InstallEngine, []string{
+			string(operatorsv1.InstallEngineSubscription), string(operatorsv1.InstallEngineHelm),
+		})}
+	}
+
+	allErrs := field.ErrorList{}
+	if old != nil && old.InstallEngine() != mch.InstallEngine() {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("installEngine"), "cannot be changed after installation"))
+	}
+	if mch.InstallEngine() == operatorsv1.InstallEngineHelm && mch.ChartSourceType() != operatorsv1.ChartSourceHelmRepo {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("chartSource", "type"), "the Helm install engine only renders charts of the bundled helm repo"))
+	}
+	return allErrs
+}
+
+// validateChartReconcile accepts the reconcile rates the subscription operator knows for the channel, and
+// only off, the one it honors, for subscriptions
+func validateChartReconcile(cr *operatorsv1.ChartReconcile, fldPath *field.Path) field.ErrorList {
+	rates := []string{
+		string(operatorsv1.ReconcileRateOff), string(operatorsv1.ReconcileRateLow),
+		string(operatorsv1.ReconcileRateMedium), string(operatorsv1.ReconcileRateHigh),
+	}
+	allErrs := field.ErrorList{}
+	for _, r := range []struct {
+		name string
+		rate operatorsv1.ReconcileRate
+	}{{"steadyRate", cr.SteadyRate}, {"upgradeRate", cr.UpgradeRate}} {
+		if r.rate != "" && !utils.Contains(rates, string(r.rate)) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child(r.name), r.rate, rates))
+		}
+	}
+	if cr.SubscriptionRate != "" && cr.SubscriptionRate != operatorsv1.ReconcileRateOff {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("subscriptionRate"), cr.SubscriptionRate,
+			[]string{string(operatorsv1.ReconcileRateOff)}))
+	}
+	return allErrs
+}
+
+// validateRollout rejects rollout waves listing unknown components, a component twice, or a component
+// before one of its dependencies
+func validateRollout(rollout *operatorsv1.Rollout, fldPath *field.Path) field.ErrorList {
+	components := operatorsv1.ComponentInstallOrder()
+	allErrs := field.ErrorList{}
+	wave := map[string]int{}
+	for i, w := range rollout.Waves {
+		wavePath := fldPath.Index(i).Child("components")
+		if len(w.Components) == 0 {
+			allErrs = append(allErrs, field.Required(wavePath, "a wave needs at least one component"))
+		}
+		for j, c := range w.Components {
+			switch _, seen := wave[c]; {
+			case !utils.Contains(components, c):
+				allErrs = append(allErrs, field.NotSupported(wavePath.Index(j), c, components))
+			case seen:
+				allErrs = append(allErrs, field.Duplicate(wavePath.Index(j), c))
+			default:
+				wave[c] = i
+			}
+		}
+	}
+	for i, w := range rollout.Waves {
+		for j, c := range w.Components {
+			for _, d := range operatorsv1.ComponentDependencies[c] {
+				if dw, ok := wave[d]; ok && dw > i {
+					allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("components").Index(j), c,
+						fmt.Sprintf("component depends on %s, which rolls out in a later wave", d)))
+				}
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateComponentDependencies rejects enabled components whose dependencies are disabled. Components
+// missing from the spec are evaluated with the defaults the operator will apply.
+func validateComponentDependencies(mch *operatorsv1.MultiClusterHub, fldPath *field.Path) field.ErrorList {
+	effective := effectiveComponents(mch)
+
+	allErrs := field.ErrorList{}
+	for _, c := range operatorsv1.ComponentInstallOrder() {
+		if !effective.Enabled(c) {
+			continue
+		}
+		for _, d := range effective.DisabledDependencies(c) {
+			allErrs = append(allErrs, field.Invalid(fldPath, c,
+				fmt.Sprintf("component %s requires %s to be enabled", c, d)))
+		}
+	}
+	return allErrs
+}
+
+// validateComponentValues accepts values only for components installed from a chart, as an object that
+// leaves the values the operator sets alone
+func validateComponentValues(mch *operatorsv1.MultiClusterHub, fldPath *field.Path) field.ErrorList {
+	if mch.Spec.Overrides == nil {
+		return nil
+	}
+	allErrs := field.ErrorList{}
+	for i, c := range mch.Spec.Overrides.Components {
+		if c.Values == nil {
+			continue
+		}
+		valuesPath := fldPath.Index(i).Child("values")
+		if !utils.Contains(operatorsv1.ChartComponents, c.Name) {
+			allErrs = append(allErrs, field.Forbidden(valuesPath, fmt.Sprintf("component %s is not installed from a chart", c.Name)))
+			continue
+		}
+		values := map[string]interface{}{}
+		if err := json.Unmarshal(c.Values.Raw, &values); err != nil {
+			allErrs = append(allErrs, field.Invalid(valuesPath, string(c.Values.Raw), "must be an object"))
+			continue
+		}
+		for _, p := range subscription.ProtectedValueConflicts(values) {
+			allErrs = append(allErrs, field.Forbidden(valuesPath, fmt.Sprintf("%s is set by the operator", p)))
+		}
+	}
+	return allErrs
+}
+
+// validateManagementStates returns an error for each unknown management state, and for multicluster-engine
+// components set Unmanaged: their toggles are handed to the multicluster-engine, which manages them
+func validateManagementStates(mch *operatorsv1.MultiClusterHub, fldPath *field.Path) field.ErrorList {
+	if mch.Spec.Overrides == nil {
+		return nil
+	}
+	allErrs := field.ErrorList{}
+	for i, c := range mch.Spec.Overrides.Components {
+		statePath := fldPath.Index(i).Child("managementState")
+		switch c.ManagementState {
+		case "", operatorsv1.ManagementStateManaged, operatorsv1.ManagementStateRemoved:
+		case operatorsv1.ManagementStateUnmanaged:
+			if utils.Contains(operatorsv1.MCEComponents, c.Name) {
+				allErrs = append(allErrs, field.Forbidden(statePath,
+					fmt.Sprintf("component %s is managed by the multicluster-engine", c.Name)))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(statePath, c.ManagementState, []string{
+				string(operatorsv1.ManagementStateManaged), string(operatorsv1.ManagementStateUnmanaged),
+				string(operatorsv1.ManagementStateRemoved),
+			}))
+		}
+	}
+	return allErrs
+}
+
+// effectiveComponents returns a copy of the multiclusterhub with the component defaults and
+// migrations the operator applies during reconcile
+func effectiveComponents(mch *operatorsv1.MultiClusterHub) *operatorsv1.MultiClusterHub {
+	effective := mch.DeepCopy()
+	utils.SetDefaultComponents(effective)
+	utils.DeduplicateComponents(effective)
+	utils.MigrateToggles(effective)
+	return effective
+}
+
+// validateObjectExists returns an error if the named object is not found in the namespace
+func validateObjectExists(ctx context.Context, c client.Client, obj client.Object, namespace, name string, fldPath *field.Path) field.ErrorList {
+	err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
+	if errors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(fldPath, fmt.Sprintf("%s/%s", namespace, name))}
+	} else if err != nil {
+		return field.ErrorList{field.InternalError(fldPath, err)}
+	}
+	return nil
+}
+
+// validateAnnotations checks the content of annotations that configure the operator
+func validateAnnotations(ctx context.Context, c client.Client, old, mch *operatorsv1.MultiClusterHub) field.ErrorList {
+	allErrs := field.ErrorList{}
+	annotationsPath := field.NewPath("metadata", "annotations")
+
+	changed := func(key string) bool {
+		return old == nil || old.GetAnnotations()[key] != mch.GetAnnotations()[key]
+	}
+
+	for _, key := range []string{utils.AnnotationMCESubscriptionSpec, utils.AnnotationOADPSubscriptionSpec} {
+		value := mch.GetAnnotations()[key]
+		if value == "" || !changed(key) {
+			continue
+		}
+		if err := json.Unmarshal([]byte(value), &subv1alpha1.SubscriptionSpec{}); err != nil {
+			allErrs = append(allErrs, field.Invalid(annotationsPath.Key(key), value,
+				fmt.Sprintf("must be a JSON encoded subscription spec: %s", err)))
+		}
+	}
+
+	if changed(utils.AnnotationImageOverridesCM) {
+		for _, cmName := range utils.GetImageOverridesConfigmaps(mch) {
+			allErrs = append(allErrs, validateImageOverridesConfigmap(ctx, c, mch.Namespace, cmName,
+				annotationsPath.Key(utils.AnnotationImageOverridesCM))...)
+		}
+	}
+
+	return allErrs
+}
+
+// validateImageOverridesConfigmap checks that the image overrides configmap exists and that each of
+// its data keys is an image manifest matching its schema
+func validateImageOverridesConfigmap(ctx context.Context, c client.Client, namespace, name string, fldPath *field.Path) field.ErrorList {
+	cm := &corev1.ConfigMap{}
+	if errs := validateObjectExists(ctx, c, cm, namespace, name, fldPath); len(errs) > 0 {
+		return errs
+	}
+
+	allErrs := field.ErrorList{}
+	keys := make([]string, 0, len(cm.Data))
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, err := manifest.ParseManifest([]byte(cm.Data[k])); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath, name,
+				fmt.Sprintf("configmap %s/%s key %s does not match the image manifest schema: %s", namespace, name, k, err)))
+		}
+	}
+	return allErrs
+}
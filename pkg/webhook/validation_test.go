@@ -0,0 +1,448 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	operatorsv1 "github.com/stolostron/multiclusterhub-operator/api/v1"
+	"github.com/stolostron/multiclusterhub-operator/pkg/utils"
+)
+
+const testNamespace = "open-cluster-management"
+
+func testMCH() *operatorsv1.MultiClusterHub {
+	return &operatorsv1.MultiClusterHub{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub", Namespace: testNamespace},
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	validImages := `[{"image-key":"application_ui","image-name":"application-ui","image-remote":"quay.io/stolostron","image-tag":"latest"}]`
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: testNamespace}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "custom-ca", Namespace: testNamespace}},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "good-overrides", Namespace: testNamespace},
+			Data:       map[string]string{"overrides.json": validImages},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "multi-key-overrides", Namespace: testNamespace},
+			Data:       map[string]string{"base.json": validImages, "fixes.json": `{"schemaVersion":1,"images":` + validImages + `}`},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "duplicate-field", Namespace: testNamespace},
+			Data: map[string]string{"overrides.json": `[{"image-key":"application_ui","image-name":"application-ui",` +
+				`"image-remote":"quay.io/stolostron","image-tag":"latest","image-tag":"2.5"}]`},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "bad-schema", Namespace: testNamespace},
+			Data:       map[string]string{"overrides.json": `[{"image-key":"application_ui","image-repo":"quay.io"}]`},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "missing-fields", Namespace: testNamespace},
+			Data:       map[string]string{"overrides.json": `[{"image-key":"application_ui","image-name":"application-ui"}]`},
+		},
+	).Build()
+
+	tests := []struct {
+		name    string
+		old     *operatorsv1.MultiClusterHub
+		mutate  func(m *operatorsv1.MultiClusterHub)
+		wantErr string
+	}{
+		{
+			name:   "Empty spec",
+			mutate: func(m *operatorsv1.MultiClusterHub) {},
+		},
+		{
+			name: "Valid settings",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Ingress.SSLCiphers = utils.DefaultSSLCiphers
+				m.Spec.NodeSelector = map[string]string{"node-role.kubernetes.io/infra": ""}
+				m.Spec.Tolerations = []corev1.Toleration{{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}}
+				m.Spec.ImagePullSecret = "pull-secret"
+				m.Spec.CustomCAConfigmap = "custom-ca"
+				m.SetAnnotations(map[string]string{
+					utils.AnnotationMCESubscriptionSpec: `{"channel":"stable-2.0"}`,
+					utils.AnnotationImageOverridesCM:    "good-overrides",
+				})
+			},
+		},
+		{
+			name:    "Weak cipher",
+			mutate:  func(m *operatorsv1.MultiClusterHub) { m.Spec.Ingress.SSLCiphers = []string{"DES-CBC3-SHA"} },
+			wantErr: "spec.ingress.sslCiphers[0]",
+		},
+		{
+			name:    "Unknown cipher",
+			mutate:  func(m *operatorsv1.MultiClusterHub) { m.Spec.Ingress.SSLCiphers = []string{"NOT-A-CIPHER"} },
+			wantErr: "spec.ingress.sslCiphers[0]: Unsupported value",
+		},
+		{
+			name:    "Invalid nodeSelector",
+			mutate:  func(m *operatorsv1.MultiClusterHub) { m.Spec.NodeSelector = map[string]string{"bad key!": "value"} },
+			wantErr: "spec.nodeSelector",
+		},
+		{
+			name: "Invalid toleration operator",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Tolerations = []corev1.Toleration{{Key: "infra", Operator: "In"}}
+			},
+			wantErr: "spec.tolerations[0].operator",
+		},
+		{
+			name: "Toleration with value and Exists",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Tolerations = []corev1.Toleration{{Key: "infra", Operator: corev1.TolerationOpExists, Value: "true"}}
+			},
+			wantErr: "spec.tolerations[0].value",
+		},
+		{
+			name:    "Missing pull secret",
+			mutate:  func(m *operatorsv1.MultiClusterHub) { m.Spec.ImagePullSecret = "missing" },
+			wantErr: "spec.imagePullSecret: Not found",
+		},
+		{
+			name:    "Missing custom CA configmap",
+			mutate:  func(m *operatorsv1.MultiClusterHub) { m.Spec.CustomCAConfigmap = "missing" },
+			wantErr: "spec.customCAConfigmap: Not found",
+		},
+		{
+			name: "Malformed OADP annotation",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.SetAnnotations(map[string]string{utils.AnnotationOADPSubscriptionSpec: `{"channel":`})
+			},
+			wantErr: "metadata.annotations[installer.open-cluster-management.io/oadp-subscription-spec]",
+		},
+		{
+			name: "Image overrides configmap with unknown fields",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.SetAnnotations(map[string]string{utils.AnnotationImageOverridesCM: "bad-schema"})
+			},
+			wantErr: "does not match the image manifest schema",
+		},
+		{
+			name: "Multiple image overrides configmaps",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.SetAnnotations(map[string]string{utils.AnnotationImageOverridesCM: "good-overrides,multi-key-overrides"})
+			},
+		},
+		{
+			name: "Missing second image overrides configmap",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.SetAnnotations(map[string]string{utils.AnnotationImageOverridesCM: "good-overrides, missing"})
+			},
+			wantErr: "Not found: \"open-cluster-management/missing\"",
+		},
+		{
+			name: "Image overrides configmap missing fields",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.SetAnnotations(map[string]string{utils.AnnotationImageOverridesCM: "missing-fields"})
+			},
+			wantErr: "images[0] (application_ui): image-remote is required",
+		},
+		{
+			name: "Image overrides configmap setting a field twice",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.SetAnnotations(map[string]string{utils.AnnotationImageOverridesCM: "duplicate-field"})
+			},
+			wantErr: `images[0]: duplicate field "image-tag"`,
+		},
+		{
+			name: "Console enabled without management-ingress",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Disable(operatorsv1.ManagementIngress)
+			},
+			wantErr: "component console requires management-ingress to be enabled",
+		},
+		{
+			name: "Repo disabled with default components",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Disable(operatorsv1.Repo)
+			},
+			wantErr: "requires multiclusterhub-repo to be enabled",
+		},
+		{
+			name: "Dependent disabled along with dependency",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Disable(operatorsv1.Console)
+				m.Disable(operatorsv1.ManagementIngress)
+			},
+		},
+		{
+			name: "Valid registry mirrors",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.RegistryMirrors = []operatorsv1.RegistryMirror{
+					{Source: "quay.io/stolostron", Mirror: "mirror.example.com/acm"},
+					{Source: "registry.redhat.io", Mirror: "mirror.example.com"},
+				}
+			},
+		},
+		{
+			name: "Registry mirror without mirror",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.RegistryMirrors = []operatorsv1.RegistryMirror{{Source: "quay.io"}}
+			},
+			wantErr: "spec.registryMirrors[0].mirror: Required value",
+		},
+		{
+			name: "Duplicate registry mirror source",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.RegistryMirrors = []operatorsv1.RegistryMirror{
+					{Source: "quay.io", Mirror: "mirror.example.com"},
+					{Source: "quay.io", Mirror: "other.example.com"},
+				}
+			},
+			wantErr: "spec.registryMirrors[1].source: Duplicate value",
+		},
+		{
+			name:   "Digest policy",
+			mutate: func(m *operatorsv1.MultiClusterHub) { m.Spec.ImageDigestPolicy = operatorsv1.ImageDigestResolve },
+		},
+		{
+			name:    "Unsupported digest policy",
+			mutate:  func(m *operatorsv1.MultiClusterHub) { m.Spec.ImageDigestPolicy = "Pin" },
+			wantErr: "spec.imageDigestPolicy: Unsupported value",
+		},
+		{
+			name: "Git chart source",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.ChartSource = &operatorsv1.ChartSource{
+					Type:      operatorsv1.ChartSourceGit,
+					URL:       "https://git.example.com/acm/charts.git",
+					SecretRef: "pull-secret",
+					Branch:    "hotfix",
+					Path:      "charts",
+				}
+			},
+		},
+		{
+			name: "OCI chart source without oci reference",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.ChartSource = &operatorsv1.ChartSource{Type: operatorsv1.ChartSourceOCI, URL: "registry.example.com/charts"}
+			},
+			wantErr: "spec.chartSource.url: Invalid value",
+		},
+		{
+			name: "Chart source with missing secret",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.ChartSource = &operatorsv1.ChartSource{
+					Type:      operatorsv1.ChartSourceOCI,
+					URL:       "oci://registry.example.com/charts",
+					SecretRef: "missing",
+				}
+			},
+			wantErr: "spec.chartSource.secretRef: Not found",
+		},
+		{
+			name: "Bundled chart source with a location",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.ChartSource = &operatorsv1.ChartSource{Type: operatorsv1.ChartSourceHelmRepo, URL: "https://charts.example.com"}
+			},
+			wantErr: "spec.chartSource.url: Forbidden",
+		},
+		{
+			name: "Unsupported chart source",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.ChartSource = &operatorsv1.ChartSource{Type: "ObjectBucket", URL: "https://bucket.example.com"}
+			},
+			wantErr: "spec.chartSource.type: Unsupported value",
+		},
+		{
+			name:   "Helm install engine",
+			mutate: func(m *operatorsv1.MultiClusterHub) { m.Spec.InstallEngine = operatorsv1.InstallEngineHelm },
+		},
+		{
+			name:    "Unsupported install engine",
+			mutate:  func(m *operatorsv1.MultiClusterHub) { m.Spec.InstallEngine = "Kustomize" },
+			wantErr: "spec.installEngine: Unsupported value",
+		},
+		{
+			name:    "Install engine changed on update",
+			old:     testMCH(),
+			mutate:  func(m *operatorsv1.MultiClusterHub) { m.Spec.InstallEngine = operatorsv1.InstallEngineHelm },
+			wantErr: "spec.installEngine: Forbidden",
+		},
+		{
+			name: "Default install engine set explicitly on update",
+			old:  testMCH(),
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.InstallEngine = operatorsv1.InstallEngineSubscription
+			},
+		},
+		{
+			name: "Helm install engine with a Git chart source",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.InstallEngine = operatorsv1.InstallEngineHelm
+				m.Spec.ChartSource = &operatorsv1.ChartSource{Type: operatorsv1.ChartSourceGit, URL: "https://git.example.com/charts.git"}
+			},
+			wantErr: "spec.chartSource.type: Forbidden",
+		},
+		{
+			name: "Chart reconcile rates",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.ChartReconcile = &operatorsv1.ChartReconcile{
+					SteadyRate:       operatorsv1.ReconcileRateOff,
+					UpgradeRate:      operatorsv1.ReconcileRateMedium,
+					SubscriptionRate: operatorsv1.ReconcileRateOff,
+				}
+			},
+		},
+		{
+			name: "Unsupported channel reconcile rate",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.ChartReconcile = &operatorsv1.ChartReconcile{UpgradeRate: "fast"}
+			},
+			wantErr: "spec.chartReconcile.upgradeRate: Unsupported value",
+		},
+		{
+			name: "Subscription reconcile rate other than off",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.ChartReconcile = &operatorsv1.ChartReconcile{SubscriptionRate: operatorsv1.ReconcileRateHigh}
+			},
+			wantErr: "spec.chartReconcile.subscriptionRate: Unsupported value",
+		},
+		{
+			name: "Component values",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Overrides = &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+					{Name: operatorsv1.GRC, Enabled: true, Values: &apiextensionsv1.JSON{Raw: []byte(`{"hubconfig":{"logLevel":"debug"}}`)}},
+				}}
+			},
+		},
+		{
+			name: "Component values setting a protected value",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Overrides = &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+					{Name: operatorsv1.GRC, Enabled: true, Values: &apiextensionsv1.JSON{Raw: []byte(`{"global":{"imageOverrides":{}}}`)}},
+				}}
+			},
+			wantErr: "spec.overrides.components[0].values: Forbidden: global.imageOverrides is set by the operator",
+		},
+		{
+			name: "Component values for a component without a chart",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Overrides = &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+					{Name: operatorsv1.MCEHive, Enabled: true, Values: &apiextensionsv1.JSON{Raw: []byte(`{"replicas":2}`)}},
+				}}
+			},
+			wantErr: "spec.overrides.components[0].values: Forbidden: component hive is not installed from a chart",
+		},
+		{
+			name: "Unmanaged component",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Overrides = &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+					{Name: operatorsv1.GRC, Enabled: true, ManagementState: operatorsv1.ManagementStateUnmanaged},
+				}}
+			},
+		},
+		{
+			name: "Unknown management state",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Overrides = &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+					{Name: operatorsv1.GRC, Enabled: true, ManagementState: "Paused"},
+				}}
+			},
+			wantErr: `spec.overrides.components[0].managementState: Unsupported value: "Paused"`,
+		},
+		{
+			name: "Unmanaged multicluster-engine component",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Overrides = &operatorsv1.Overrides{Components: []operatorsv1.ComponentConfig{
+					{Name: operatorsv1.MCEHive, Enabled: true, ManagementState: operatorsv1.ManagementStateUnmanaged},
+				}}
+			},
+			wantErr: "spec.overrides.components[0].managementState: Forbidden: component hive is managed by the multicluster-engine",
+		},
+		{
+			name: "Rollout waves",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Rollout = &operatorsv1.Rollout{Waves: []operatorsv1.RolloutWave{
+					{Components: []string{operatorsv1.Repo, operatorsv1.GRC}},
+					{Components: []string{operatorsv1.Search}},
+				}}
+			},
+		},
+		{
+			name: "Rollout wave with an unknown component",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Rollout = &operatorsv1.Rollout{Waves: []operatorsv1.RolloutWave{
+					{Components: []string{operatorsv1.MCEHive}},
+				}}
+			},
+			wantErr: `spec.rollout.waves[0].components[0]: Unsupported value: "hive"`,
+		},
+		{
+			name: "Component in two rollout waves",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Rollout = &operatorsv1.Rollout{Waves: []operatorsv1.RolloutWave{
+					{Components: []string{operatorsv1.GRC}},
+					{Components: []string{operatorsv1.GRC}},
+				}}
+			},
+			wantErr: `spec.rollout.waves[1].components[0]: Duplicate value: "grc"`,
+		},
+		{
+			name: "Component rolling out before its dependency",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.Rollout = &operatorsv1.Rollout{Waves: []operatorsv1.RolloutWave{
+					{Components: []string{operatorsv1.Console}},
+					{Components: []string{operatorsv1.ManagementIngress}},
+				}}
+			},
+			wantErr: "spec.rollout.waves[0].components[0]: Invalid value: \"console\": component depends on management-ingress, which rolls out in a later wave",
+		},
+		{
+			name: "Upgrade rollback",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.UpgradeRollback = &operatorsv1.UpgradeRollback{Enabled: true, Deadline: &metav1.Duration{Duration: 30 * time.Minute}}
+			},
+		},
+		{
+			name: "Upgrade rollback without a deadline",
+			mutate: func(m *operatorsv1.MultiClusterHub) {
+				m.Spec.UpgradeRollback = &operatorsv1.UpgradeRollback{Deadline: &metav1.Duration{}}
+			},
+			wantErr: `spec.upgradeRollback.deadline: Invalid value: "0s": must be a positive duration`,
+		},
+		{
+			name: "Unchanged invalid field on update",
+			old: func() *operatorsv1.MultiClusterHub {
+				m := testMCH()
+				m.Spec.ImagePullSecret = "missing"
+				return m
+			}(),
+			mutate: func(m *operatorsv1.MultiClusterHub) { m.Spec.ImagePullSecret = "missing" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mch := testMCH()
+			tt.mutate(mch)
+			err := validateSpec(context.TODO(), c, tt.old, mch)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSpec() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSpec() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}